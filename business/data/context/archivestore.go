@@ -0,0 +1,165 @@
+package context
+
+import (
+	"encoding/json"
+	"fmt"
+	"goairmon/business/data/models"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const archiveFileFormat = "archive_%04d_%02d_%02d.json"
+
+// Stores a day's worth of sensor points per file, keyed by calendar date.
+type ArchiveStore interface {
+	ArchivedDays() ([]time.Time, error)
+	LoadDay(day time.Time) ([]*models.SensorPoint, error)
+	MergeDay(day time.Time, points []*models.SensorPoint) (added int, err error)
+}
+
+func NewFileArchiveStore(storagePath string) ArchiveStore {
+	return &fileArchiveStore{
+		storagePath: storagePath,
+	}
+}
+
+type fileArchiveStore struct {
+	storagePath string
+}
+
+// ArchivedDays returns the dates of existing archives at midnight UTC, oldest first.
+func (s *fileArchiveStore) ArchivedDays() ([]time.Time, error) {
+	files, err := filepath.Glob(filepath.Join(s.storagePath, "archive_*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	days := make([]time.Time, 0, len(files))
+	for _, file := range files {
+		var year, day int
+		var month time.Month
+		if _, err := fmt.Sscanf(filepath.Base(file), archiveFileFormat, &year, &month, &day); err != nil {
+			continue
+		}
+
+		days = append(days, time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+	}
+
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Before(days[j])
+	})
+
+	return days, nil
+}
+
+// LoadDay returns the archived points for the day's calendar date, or an empty slice if none exist.
+func (s *fileArchiveStore) LoadDay(day time.Time) ([]*models.SensorPoint, error) {
+	points := make([]*models.SensorPoint, 0)
+
+	raw, err := ioutil.ReadFile(s.dayFile(day))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return points, nil
+		}
+
+		return nil, fmt.Errorf("failed to read archive: %s", err)
+	}
+
+	if err := json.Unmarshal(raw, &points); err != nil {
+		return nil, fmt.Errorf("failed to decode archive: %s", err)
+	}
+
+	return points, nil
+}
+
+// MergeDay adds any points not already in the day's archive, writing the file only if something was added.
+func (s *fileArchiveStore) MergeDay(day time.Time, points []*models.SensorPoint) (int, error) {
+	existing, err := s.LoadDay(day)
+	if err != nil {
+		return 0, err
+	}
+
+	merged, added := MergeSensorPoints(existing, points)
+	if added == 0 {
+		return 0, nil
+	}
+
+	encoded, err := json.Marshal(merged)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal archive: %s", err)
+	}
+
+	os.MkdirAll(s.storagePath, 0700)
+	if err := writeFileAtomic(s.dayFile(day), encoded); err != nil {
+		return 0, fmt.Errorf("failed to write archive: %s", err)
+	}
+
+	return added, nil
+}
+
+// Writes to a temp file renamed over path, so readers never see a partly written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+
+	return err
+}
+
+func (s *fileArchiveStore) dayFile(day time.Time) string {
+	return filepath.Join(s.storagePath, fmt.Sprintf(archiveFileFormat, day.Year(), day.Month(), day.Day()))
+}
+
+// MergeSensorPoints combines the points de-duplicated by unix second, sorted oldest first.
+// Existing points win over new points with the same timestamp.
+func MergeSensorPoints(existing []*models.SensorPoint, points []*models.SensorPoint) (merged []*models.SensorPoint, added int) {
+	seen := make(map[int64]bool, len(existing)+len(points))
+	merged = make([]*models.SensorPoint, 0, len(existing)+len(points))
+
+	for _, p := range existing {
+		if p == nil || seen[p.Time.Unix()] {
+			continue
+		}
+
+		seen[p.Time.Unix()] = true
+		merged = append(merged, p)
+	}
+
+	for _, p := range points {
+		if p == nil || seen[p.Time.Unix()] {
+			continue
+		}
+
+		seen[p.Time.Unix()] = true
+		merged = append(merged, p)
+		added++
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Time.Before(merged[j].Time)
+	})
+
+	return merged, added
+}
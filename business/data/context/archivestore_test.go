@@ -0,0 +1,133 @@
+package context
+
+import (
+	"goairmon/business/data/models"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func _setupArchiveStore(t *testing.T) *fileArchiveStore {
+	dir, err := ioutil.TempDir("", "goairmon_archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewFileArchiveStore(dir).(*fileArchiveStore)
+}
+
+func TestMergeDay(t *testing.T) {
+	store := _setupArchiveStore(t)
+	defer os.RemoveAll(store.storagePath)
+
+	day := time.Date(2010, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	points, err := store.LoadDay(day)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(points) != 0 {
+		t.Error("expected no points", len(points))
+	}
+
+	first := []*models.SensorPoint{
+		{Time: day.Add(2 * time.Minute), Co2Value: 3},
+		{Time: day, Co2Value: 1},
+	}
+
+	added, err := store.MergeDay(day, first)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if added != 2 {
+		t.Error("unexpected added count", 2, added)
+	}
+
+	second := []*models.SensorPoint{
+		{Time: day.Add(time.Minute), Co2Value: 2},
+		{Time: day.Add(2 * time.Minute), Co2Value: 99},
+		nil,
+	}
+
+	added, err = store.MergeDay(day, second)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if added != 1 {
+		t.Error("unexpected added count", 1, added)
+	}
+
+	added, err = store.MergeDay(day, second)
+	if err != nil || added != 0 {
+		t.Error("expected nothing to merge", added, err)
+	}
+
+	points, err = store.LoadDay(day)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(points) != 3 {
+		t.Fatal("unexpected point count", 3, len(points))
+	}
+
+	for i, p := range points {
+		if p.Co2Value != float64(i+1) {
+			t.Error("unexpected point order or value", i, p)
+		}
+	}
+
+	if files, _ := filepath.Glob(filepath.Join(store.storagePath, "*")); len(files) != 1 {
+		t.Error("expected only the archive to be left behind", files)
+	}
+}
+
+func TestArchivedDays(t *testing.T) {
+	store := _setupArchiveStore(t)
+	defer os.RemoveAll(store.storagePath)
+
+	dayA := time.Date(2010, 1, 3, 0, 0, 0, 0, time.UTC)
+	dayB := time.Date(2009, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	for _, day := range []time.Time{dayA, dayB} {
+		if _, err := store.MergeDay(day, []*models.SensorPoint{{Time: day}}); err != nil {
+			t.Error(err)
+		}
+	}
+
+	ioutil.WriteFile(store.storagePath+"/archive_garbage.json", []byte("[]"), 0644)
+
+	days, err := store.ArchivedDays()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(days) != 2 {
+		t.Fatal("unexpected day count", 2, len(days))
+	}
+
+	if !days[0].Equal(dayB) || !days[1].Equal(dayA) {
+		t.Error("unexpected days", days)
+	}
+}
+
+func TestLoadInvalidArchive(t *testing.T) {
+	store := _setupArchiveStore(t)
+	defer os.RemoveAll(store.storagePath)
+
+	day := time.Date(2010, 1, 2, 0, 0, 0, 0, time.UTC)
+	ioutil.WriteFile(store.dayFile(day), []byte("garbagedata"), 0644)
+
+	if _, err := store.LoadDay(day); err == nil {
+		t.Error("expected error")
+	}
+
+	if _, err := store.MergeDay(day, []*models.SensorPoint{{Time: day}}); err == nil {
+		t.Error("expected error")
+	}
+}
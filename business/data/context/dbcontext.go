@@ -2,6 +2,7 @@ package context
 
 import (
 	"goairmon/business/data/models"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -11,11 +12,20 @@ type DbContext interface {
 	CreateOrUpdateUser(user *models.User) error
 	FindUser(id uuid.UUID) (*models.User, error)
 	FindUserByName(username string) (*models.User, error)
+	GetUsers() ([]*models.User, error)
 	DeleteUser(id uuid.UUID) error
+	CreateInvite(invite *models.Invite) error
+	FindInvite(id uuid.UUID) (*models.Invite, error)
+	GetInvites() ([]*models.Invite, error)
+	DeleteInvite(id uuid.UUID) error
 	PushSensorPoint(point *models.SensorPoint) error
+	MergeSensorPoints(points []*models.SensorPoint) (added int, err error)
+	MergeArchivedDay(day time.Time, points []*models.SensorPoint) (added int, err error)
+	Archives() ArchiveStore
 	GetSensorPoints(count int) ([]*models.SensorPoint, error)
 	ClearSensorPoints() error
 	GetSensorBaseline() (eCO2 uint16, TVOC uint16, err error)
 	SetSensorBaseline(eCO2 uint16, TVOC uint16) error
 	Save() error
+	Snapshot(fn func() error) error
 }
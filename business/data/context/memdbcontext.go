@@ -7,8 +7,10 @@ import (
 	"goairmon/business/data/models"
 	"io/ioutil"
 	"os"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo"
@@ -22,6 +24,7 @@ func NewMemDbContext(cfg *MemDbConfig) DbContext {
 	ctx := &memDbContext{
 		cfg:          cfg,
 		sensorPoints: NewSensorPointStack(cfg.SensorPointCount),
+		archives:     NewFileArchiveStore(cfg.StoragePath),
 	}
 
 	ctx.lock.Lock()
@@ -29,7 +32,8 @@ func NewMemDbContext(cfg *MemDbConfig) DbContext {
 
 	if err := ctx.loadStoredConfig(); err != nil {
 		ctx.storedConfig = &StoredConfig{
-			Users: make(map[uuid.UUID]*models.User),
+			Version: StoredConfigVersion,
+			Users:   make(map[uuid.UUID]*models.User),
 		}
 	}
 
@@ -41,9 +45,11 @@ func NewMemDbContext(cfg *MemDbConfig) DbContext {
 }
 
 type StoredConfig struct {
+	Version      int    `json:"version"`
 	ECO2Baseline uint16 `json:"eco2"`
 	TVOCBaseline uint16 `json:"tvoc"`
 	Users        map[uuid.UUID]*models.User
+	Invites      map[uuid.UUID]*models.Invite `json:",omitempty"`
 }
 
 type MemDbConfig struct {
@@ -57,6 +63,7 @@ type memDbContext struct {
 	cfg          *MemDbConfig
 	sensorPoints PointStack
 	storedConfig *StoredConfig
+	archives     ArchiveStore
 	lock         sync.Mutex
 }
 
@@ -118,13 +125,29 @@ func (m *memDbContext) FindUserByName(userName string) (*models.User, error) {
 
 	for _, user := range m.storedConfig.Users {
 		if user.Username == userName {
-			return user, nil
+			return user.CopyTo(&models.User{}), nil
 		}
 	}
 
 	return nil, fmt.Errorf("failed to find user")
 }
 
+func (m *memDbContext) GetUsers() ([]*models.User, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	users := make([]*models.User, 0, len(m.storedConfig.Users))
+	for _, user := range m.storedConfig.Users {
+		users = append(users, user.CopyTo(&models.User{}))
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+
+	return users, nil
+}
+
 func (m *memDbContext) DeleteUser(id uuid.UUID) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
@@ -138,6 +161,61 @@ func (m *memDbContext) DeleteUser(id uuid.UUID) error {
 	return fmt.Errorf("id not found")
 }
 
+func (m *memDbContext) CreateInvite(invite *models.Invite) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.storedConfig.Invites == nil {
+		m.storedConfig.Invites = make(map[uuid.UUID]*models.Invite)
+	}
+
+	invite.ID = uuid.New()
+	m.storedConfig.Invites[invite.ID] = invite.CopyTo(&models.Invite{})
+
+	return nil
+}
+
+func (m *memDbContext) FindInvite(id uuid.UUID) (*models.Invite, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	existing, ok := m.storedConfig.Invites[id]
+	if ok {
+		return existing.CopyTo(&models.Invite{}), nil
+	}
+
+	return nil, fmt.Errorf("invite not found")
+}
+
+// GetInvites returns the invites newest first.
+func (m *memDbContext) GetInvites() ([]*models.Invite, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	invites := make([]*models.Invite, 0, len(m.storedConfig.Invites))
+	for _, invite := range m.storedConfig.Invites {
+		invites = append(invites, invite.CopyTo(&models.Invite{}))
+	}
+
+	sort.Slice(invites, func(i, j int) bool {
+		return invites[i].CreatedAt.After(invites[j].CreatedAt)
+	})
+
+	return invites, nil
+}
+
+func (m *memDbContext) DeleteInvite(id uuid.UUID) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.storedConfig.Invites[id]; ok {
+		delete(m.storedConfig.Invites, id)
+		return nil
+	}
+
+	return fmt.Errorf("invite not found")
+}
+
 func (m *memDbContext) loadPoints() error {
 	raw, err := ioutil.ReadFile(m.pointFile())
 	if err != nil {
@@ -165,6 +243,11 @@ func (m *memDbContext) loadStoredConfig() error {
 		return fmt.Errorf("failed to decode stored config: %s", err)
 	}
 
+	// Newer configs are kept as is rather than dropped and overwritten
+	if _, err := MigrateStoredConfig(m.storedConfig); err != nil && m.cfg.Logger != nil {
+		m.cfg.Logger.Error(err)
+	}
+
 	return nil
 }
 
@@ -208,57 +291,44 @@ func (m *memDbContext) PushSensorPoint(point *models.SensorPoint) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
-	lastPoint := m.sensorPoints.Peak(0)
-	if lastPoint != nil && lastPoint.Time.Day() != point.Time.Day() {
-		if err := m.archiveLastDay(); err != nil {
-			m.cfg.Logger.Error(err)
-		}
-	}
-
 	m.sensorPoints.Push(point)
 
 	return nil
 }
 
-func (m *memDbContext) archiveLastDay() error {
-	daysPoints, err := m.sensorPoints.PeakNLatest(60 * 24)
-	if err != nil {
-		return err
-	}
+// MergeSensorPoints adds points missing from the stack by timestamp, keeping the newest that fit.
+func (m *memDbContext) MergeSensorPoints(points []*models.SensorPoint) (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 
-	if len(daysPoints) == 0 || daysPoints[0] == nil {
-		return fmt.Errorf("not enough points saved")
+	existing, err := m.sensorPoints.PeakNLatest(0)
+	if err != nil {
+		return 0, err
 	}
 
-	year := daysPoints[0].Time.Year()
-	day := daysPoints[0].Time.YearDay()
-
-	for i := len(daysPoints) - 1; i >= 0; i-- {
-		point := daysPoints[i]
-		if point == nil || point.Time.YearDay() != day || point.Time.Year() != year {
-			continue
+	existingTimes := make(map[int64]bool, len(existing))
+	for _, p := range existing {
+		if p != nil {
+			existingTimes[p.Time.Unix()] = true
 		}
-
-		daysPoints = daysPoints[:i]
-		break
 	}
 
-	if len(daysPoints) == 0 {
-		return fmt.Errorf("not enough valid points")
+	merged, _ := MergeSensorPoints(existing, points)
+	if len(merged) > m.sensorPoints.Size() {
+		merged = merged[len(merged)-m.sensorPoints.Size():]
 	}
 
-	encoded, err := json.Marshal(daysPoints)
-	if err != nil {
-		return err
-	}
+	m.sensorPoints.Clear()
+	added := 0
+	for _, p := range merged {
+		if !existingTimes[p.Time.Unix()] {
+			added++
+		}
 
-	fileName := fmt.Sprintf("/archive_%d_%02d_%02d.json", daysPoints[0].Time.Year(), daysPoints[0].Time.Month(), daysPoints[0].Time.Day())
-	os.MkdirAll(m.cfg.StoragePath, 0700)
-	if err := ioutil.WriteFile(m.cfg.StoragePath+fileName, encoded, 0644); err != nil {
-		return fmt.Errorf("failed to write archive: %s", err)
+		m.sensorPoints.Push(p)
 	}
 
-	return nil
+	return added, nil
 }
 
 func (m *memDbContext) GetSensorPoints(count int) ([]*models.SensorPoint, error) {
@@ -282,6 +352,9 @@ func (m *memDbContext) GetSensorPoints(count int) ([]*models.SensorPoint, error)
 }
 
 func (m *memDbContext) ClearSensorPoints() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
 	m.sensorPoints.Clear()
 
 	return m.savePoints()
@@ -307,3 +380,33 @@ func (m *memDbContext) Save() error {
 	m.saveStoredConfig()
 	return m.savePoints()
 }
+
+// MergeArchivedDay adds points to the day's archive in storage, locked like the rest of the context
+// so it can't land in the middle of a snapshot.
+func (m *memDbContext) MergeArchivedDay(day time.Time, points []*models.SensorPoint) (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.archives.MergeDay(day, points)
+}
+
+// Archives reads the archived days, writes go through MergeArchivedDay.
+func (m *memDbContext) Archives() ArchiveStore {
+	return m.archives
+}
+
+// Snapshot saves all state to storage then runs fn before any other changes are accepted.
+func (m *memDbContext) Snapshot(fn func() error) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if err := m.saveStoredConfig(); err != nil {
+		return err
+	}
+
+	if err := m.savePoints(); err != nil {
+		return err
+	}
+
+	return fn()
+}
@@ -1,7 +1,7 @@
 package context
 
 import (
-	"encoding/json"
+	"fmt"
 	"goairmon/business/data/models"
 	"goairmon/site/helper"
 	"io/ioutil"
@@ -19,7 +19,7 @@ func _setupMemDbContext(t *testing.T) *memDbContext {
 	}
 
 	ctx := NewMemDbContext(&MemDbConfig{
-		StoragePath:      helper.MustGetEnv("STORAGE_PATH"),
+		StoragePath:      os.Getenv("STORAGE_PATH"),
 		SensorPointCount: 10,
 		EncodeReadible:   true,
 	})
@@ -227,6 +227,44 @@ func TestDeleteUser(t *testing.T) {
 	}
 }
 
+func TestInvites(t *testing.T) {
+	ctx := _setupMemDbContext(t)
+	older := &models.Invite{Role: models.RoleViewer, CreatedAt: time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)}
+	newer := &models.Invite{Role: models.RoleAdmin, CreatedAt: time.Date(2019, 10, 2, 0, 0, 0, 0, time.UTC)}
+
+	for _, invite := range []*models.Invite{older, newer} {
+		if err := ctx.CreateInvite(invite); err != nil || invite.ID == uuid.Nil {
+			t.Fatal("expected invite to get an id", err)
+		}
+	}
+
+	if err := ctx.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx.storedConfig.Invites = nil
+	if err := ctx.loadStoredConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	invites, err := ctx.GetInvites()
+	if err != nil || len(invites) != 2 || invites[0].ID != newer.ID || invites[1].Role != models.RoleViewer {
+		t.Error("expected saved invites newest first", invites, err)
+	}
+
+	if err := ctx.DeleteInvite(newer.ID); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := ctx.FindInvite(newer.ID); err == nil {
+		t.Error("expected invite to be deleted")
+	}
+
+	if err := ctx.DeleteInvite(newer.ID); err == nil {
+		t.Error("expected error")
+	}
+}
+
 func TestLoadInvalidFile(t *testing.T) {
 	ctx := _setupMemDbContext(t)
 
@@ -351,47 +389,112 @@ func TestSaveSensorBaseline(t *testing.T) {
 	}
 }
 
-func TestArchiveLastDay(t *testing.T) {
+func TestMergeSensorPoints(t *testing.T) {
 	ctx := _setupMemDbContext(t)
-	ctx.cfg.SensorPointCount = 24 * 60 * 2
+	start := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
 
-	ctx.sensorPoints = NewSensorPointStack(ctx.cfg.SensorPointCount)
-	err := ctx.archiveLastDay()
-	if err == nil {
-		t.Error("expected error")
+	for i := 0; i < 5; i++ {
+		ctx.PushSensorPoint(&models.SensorPoint{Time: start.Add(time.Minute * time.Duration(i*2)), Co2Value: float64(i * 2)})
 	}
 
-	for i := 0; i < 24*60*2; i++ {
-		ctx.sensorPoints.Push(&models.SensorPoint{
-			Time:     time.Date(2010, 01, 01, 00, 00, 00, 00, time.UTC).Add(time.Minute * time.Duration(i)),
-			Co2Value: 23,
-		})
+	imported := make([]*models.SensorPoint, 0)
+	for i := -4; i < 10; i++ {
+		imported = append(imported, &models.SensorPoint{Time: start.Add(time.Minute * time.Duration(i)), Co2Value: 100})
 	}
 
-	if err := ctx.PushSensorPoint(&models.SensorPoint{
-		Time:     time.Date(2010, 1, 3, 0, 0, 0, 0, time.UTC),
-		Co2Value: 23,
-	}); err != nil {
-		t.Error("unexpected error", err)
+	added, err := ctx.MergeSensorPoints(imported)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if added != 5 {
+		t.Error("unexpected added count", 5, added)
 	}
 
-	loaded, err := ioutil.ReadFile(ctx.cfg.StoragePath + "/archive_2010_01_02.json")
+	points, err := ctx.GetSensorPoints(0)
 	if err != nil {
-		t.Error("unexpected error", err)
+		t.Error(err)
 	}
 
-	var decoded []*models.SensorPoint
-	if err := json.Unmarshal(loaded, &decoded); err != nil {
-		t.Error("unexpected error", err)
+	if len(points) != 10 {
+		t.Fatal("unexpected point count", 10, len(points))
 	}
 
-	if len(decoded) != 24*60-1 {
-		t.Error("unexpected count", 24*60-1, len(decoded))
+	for i, p := range points {
+		minute := 9 - i
+		if !p.Time.Equal(start.Add(time.Minute * time.Duration(minute))) {
+			t.Error("unexpected point time", minute, p.Time)
+		}
+
+		if minute%2 == 0 && minute < 10 && p.Co2Value != float64(minute) {
+			t.Error("existing points should not be replaced", p)
+		}
 	}
 
-	for _, p := range decoded {
-		if p.Time.YearDay() != 2 {
-			t.Error("values should be from Jan 2", p)
+	if added, _ := ctx.MergeSensorPoints(imported); added != 0 {
+		t.Error("expected no duplicates to be added", added)
+	}
+}
+
+func TestMergeArchivedDay(t *testing.T) {
+	ctx := _setupMemDbContext(t)
+	day := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := []*models.SensorPoint{
+		&models.SensorPoint{Time: day.Add(time.Hour), Co2Value: 1.0},
+		&models.SensorPoint{Time: day.Add(2 * time.Hour), Co2Value: 2.0},
+	}
+
+	if added, err := ctx.MergeArchivedDay(day, points); err != nil || added != 2 {
+		t.Fatal("unexpected archived points", added, err)
+	}
+
+	archived, err := NewFileArchiveStore(ctx.cfg.StoragePath).LoadDay(day)
+	if err != nil || len(archived) != 2 {
+		t.Error("expected points in the day's archive", archived, err)
+	}
+
+	if added, _ := ctx.MergeArchivedDay(day, points); added != 0 {
+		t.Error("expected no duplicates to be archived", added)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx := _setupMemDbContext(t)
+	ctx.PushSensorPoint(&models.SensorPoint{Time: time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC), Co2Value: 1.0})
+
+	err := ctx.Snapshot(func() error {
+		if _, err := os.Stat(ctx.pointFile()); err != nil {
+			t.Error("expected points to be saved before snapshot")
 		}
+
+		if _, err := os.Stat(ctx.configFile()); err != nil {
+			t.Error("expected config to be saved before snapshot")
+		}
+
+		return fmt.Errorf("snapshot error")
+	})
+
+	if err == nil || err.Error() != "snapshot error" {
+		t.Error("expected snapshot error", err)
+	}
+}
+
+func TestGetUsers(t *testing.T) {
+	ctx := _setupMemDbContext(t)
+	ctx.CreateOrUpdateUser(&models.User{Username: "second-user"})
+	ctx.CreateOrUpdateUser(&models.User{Username: "first-user"})
+
+	users, err := ctx.GetUsers()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(users) != 2 || users[0].Username != "first-user" || users[1].Username != "second-user" {
+		t.Error("unexpected users", users)
+	}
+
+	users[0].Username = "changed"
+	if _, err := ctx.FindUserByName("first-user"); err != nil {
+		t.Error("stored user should not be changed")
 	}
 }
@@ -0,0 +1,85 @@
+package context
+
+import (
+	"encoding/json"
+	"fmt"
+	"goairmon/business/data/models"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// StoredConfigVersion is the stored config layout written by this build.
+const StoredConfigVersion = 2
+
+// Each migration upgrades a stored config from its index to index+1.
+var storedConfigMigrations = []func(cfg *StoredConfig) error{
+	// Configs from before versioning share the same layout, they only gain the version.
+	func(cfg *StoredConfig) error { return nil },
+	// Users from before roles keep full access.
+	func(cfg *StoredConfig) error {
+		for _, user := range cfg.Users {
+			if user.Role == "" {
+				user.Role = models.RoleAdmin
+			}
+		}
+
+		return nil
+	},
+}
+
+// MigrateStoredConfig upgrades cfg to StoredConfigVersion, returning the version it started at.
+func MigrateStoredConfig(cfg *StoredConfig) (int, error) {
+	from := cfg.Version
+	if from > StoredConfigVersion {
+		return from, fmt.Errorf("stored config version %d is newer than supported version %d", from, StoredConfigVersion)
+	}
+
+	for version := from; version < StoredConfigVersion; version++ {
+		if err := storedConfigMigrations[version](cfg); err != nil {
+			return from, fmt.Errorf("failed to migrate stored config to version %d: %s", version+1, err)
+		}
+
+		cfg.Version = version + 1
+	}
+
+	return from, nil
+}
+
+// MigrateStorage upgrades the stored config file in storagePath in place.
+func MigrateStorage(storagePath string) (from int, to int, err error) {
+	path := filepath.Join(storagePath, "goairmon_config.json")
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read stored config: %s", err)
+	}
+
+	cfg := &StoredConfig{}
+	if err := json.Unmarshal(raw, cfg); err != nil {
+		return 0, 0, fmt.Errorf("failed to decode stored config: %s", err)
+	}
+
+	if from, err = MigrateStoredConfig(cfg); err != nil {
+		return from, from, err
+	}
+
+	if from == cfg.Version {
+		return from, from, nil
+	}
+
+	if raw, err = json.Marshal(cfg); err != nil {
+		return from, from, fmt.Errorf("failed to marshal stored config: %s", err)
+	}
+
+	tempPath := path + ".migrating"
+	if err := ioutil.WriteFile(tempPath, raw, 0644); err != nil {
+		return from, from, fmt.Errorf("failed to write stored config: %s", err)
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return from, from, fmt.Errorf("failed to replace stored config: %s", err)
+	}
+
+	return from, cfg.Version, nil
+}
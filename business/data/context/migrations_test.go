@@ -0,0 +1,73 @@
+package context
+
+import (
+	"fmt"
+	"goairmon/business/data/models"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMigrateStoredConfig(t *testing.T) {
+	existing := &models.User{ID: uuid.New(), Username: "existing-user"}
+	cfg := &StoredConfig{Users: map[uuid.UUID]*models.User{existing.ID: existing}}
+	from, err := MigrateStoredConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if from != 0 || cfg.Version != StoredConfigVersion {
+		t.Error("unexpected versions", from, cfg.Version)
+	}
+
+	if existing.Role != models.RoleAdmin {
+		t.Error("expected existing user to be made admin", existing.Role)
+	}
+
+	viewer := &models.User{ID: uuid.New(), Username: "viewer-user", Role: models.RoleViewer}
+	cfg = &StoredConfig{Version: 1, Users: map[uuid.UUID]*models.User{viewer.ID: viewer}}
+	if _, err := MigrateStoredConfig(cfg); err != nil || viewer.Role != models.RoleViewer {
+		t.Error("expected role to be kept", viewer.Role, err)
+	}
+
+	cfg.Version = StoredConfigVersion + 1
+	if _, err := MigrateStoredConfig(cfg); err == nil {
+		t.Error("expected error for newer config")
+	}
+}
+
+func TestMigrateStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goairmon_migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, _, err := MigrateStorage(dir); err == nil {
+		t.Error("expected error without stored config")
+	}
+
+	path := filepath.Join(dir, "goairmon_config.json")
+	if err := ioutil.WriteFile(path, []byte(`{"eco2":12,"tvoc":34,"Users":{}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	from, to, err := MigrateStorage(dir)
+	if err != nil || from != 0 || to != StoredConfigVersion {
+		t.Error("unexpected migration", from, to, err)
+	}
+
+	raw, _ := ioutil.ReadFile(path)
+	if !strings.Contains(string(raw), fmt.Sprintf(`"version":%d`, StoredConfigVersion)) || !strings.Contains(string(raw), `"eco2":12`) {
+		t.Error("unexpected migrated config", string(raw))
+	}
+
+	from, to, err = MigrateStorage(dir)
+	if err != nil || from != to {
+		t.Error("expected no changes", from, to, err)
+	}
+}
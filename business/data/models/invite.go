@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Invite lets whoever has its link create one account with Role before ExpiresAt.
+type Invite struct {
+	ID        uuid.UUID `col:"id"`
+	Role      string    `col:"role"`
+	CreatedBy string    `col:"createdby"`
+	CreatedAt time.Time `col:"createdat"`
+	ExpiresAt time.Time `col:"expiresat"`
+}
+
+func (i *Invite) CopyTo(other *Invite) *Invite {
+	*other = *i
+
+	return other
+}
+
+func (i *Invite) Expired(now time.Time) bool {
+	return !now.Before(i.ExpiresAt)
+}
@@ -8,8 +8,9 @@ import (
 )
 
 type SensorPoint struct {
-	Time     time.Time
-	Co2Value float64
+	Time      time.Time
+	Co2Value  float64
+	TVOCValue float64
 }
 
 type JsonTime time.Time
@@ -33,11 +34,13 @@ func (t *JsonTime) UnmarshalJSON(raw []byte) error {
 
 func (p *SensorPoint) MarshalJSON() ([]byte, error) {
 	jsonStruct := struct {
-		JTime    JsonTime `json:"t"`
-		Co2Value float64  `json:"v"`
+		JTime     JsonTime `json:"t"`
+		Co2Value  float64  `json:"v"`
+		TVOCValue float64  `json:"tv,omitempty"`
 	}{
 		JsonTime(p.Time),
 		p.Co2Value,
+		p.TVOCValue,
 	}
 
 	return json.Marshal(jsonStruct)
@@ -45,8 +48,9 @@ func (p *SensorPoint) MarshalJSON() ([]byte, error) {
 
 func (p *SensorPoint) UnmarshalJSON(raw []byte) error {
 	jsonStruct := struct {
-		JTime    JsonTime `json:"t"`
-		Co2Value float64  `json:"v"`
+		JTime     JsonTime `json:"t"`
+		Co2Value  float64  `json:"v"`
+		TVOCValue float64  `json:"tv,omitempty"`
 	}{}
 
 	err := json.Unmarshal(raw, &jsonStruct)
@@ -56,6 +60,7 @@ func (p *SensorPoint) UnmarshalJSON(raw []byte) error {
 
 	p.Time = time.Time(jsonStruct.JTime)
 	p.Co2Value = jsonStruct.Co2Value
+	p.TVOCValue = jsonStruct.TVOCValue
 
 	return nil
 }
@@ -67,6 +72,7 @@ func (p *SensorPoint) CopyTo(other *SensorPoint) *SensorPoint {
 
 	other.Time = p.Time
 	other.Co2Value = p.Co2Value
+	other.TVOCValue = p.TVOCValue
 
 	return other
 }
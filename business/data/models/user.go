@@ -7,18 +7,43 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+// Roles in order of access, each role can do everything the ones before it can.
+var Roles = []string{RoleViewer, RoleOperator, RoleAdmin}
+
 type User struct {
 	ID           uuid.UUID `col:"id"`
 	Username     string    `col:"username"`
 	PasswordHash []byte    `col:"passwordhash"`
-	LastLogin    time.Time `col:"lastlogin"`
+	// Previous hashes, newest first, so recent passwords aren't reused
+	PasswordHistory [][]byte  `col:"passwordhistory"`
+	LastLogin       time.Time `col:"lastlogin"`
+	Timezone        string    `col:"timezone"`
+	Locked          bool      `col:"locked"`
+	Role            string    `col:"role"`
+	// Two factor is enabled when TotpSecret is set
+	TotpSecret    string   `col:"totpsecret"`
+	TotpLastStep  int64    `col:"totplaststep"`
+	RecoveryCodes []string `col:"recoverycodes"`
 }
 
 func (u *User) CopyTo(other *User) *User {
 	other.ID = u.ID
 	other.Username = u.Username
 	other.PasswordHash = u.PasswordHash
+	other.PasswordHistory = append([][]byte(nil), u.PasswordHistory...)
 	other.LastLogin = u.LastLogin
+	other.Timezone = u.Timezone
+	other.Locked = u.Locked
+	other.Role = u.Role
+	other.TotpSecret = u.TotpSecret
+	other.TotpLastStep = u.TotpLastStep
+	other.RecoveryCodes = append([]string(nil), u.RecoveryCodes...)
 
 	return other
 }
@@ -27,11 +52,39 @@ func (u *User) CheckPassword(password string) bool {
 	return bcrypt.CompareHashAndPassword(u.PasswordHash, []byte(password)) == nil
 }
 
-func (u *User) SetPassword(password string) error {
-	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err == nil {
-		u.PasswordHash = hashed
+// Location returns the user's preferred timezone, or the fallback if none is set or it fails to load.
+func (u *User) Location(fallback *time.Location) *time.Location {
+	if u.Timezone == "" {
+		return fallback
+	}
+
+	loc, err := time.LoadLocation(u.Timezone)
+	if err != nil {
+		return fallback
 	}
 
-	return err
+	return loc
+}
+
+// HasRole checks the user's role is at least the given role.
+func (u *User) HasRole(role string) bool {
+	return roleRank(u.Role) >= roleRank(role) && roleRank(role) >= 0
+}
+
+func ValidRole(role string) bool {
+	return roleRank(role) >= 0
+}
+
+func roleRank(role string) int {
+	for i, r := range Roles {
+		if r == role {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (u *User) TwoFactorEnabled() bool {
+	return u.TotpSecret != ""
 }
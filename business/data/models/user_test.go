@@ -0,0 +1,27 @@
+package models
+
+import "testing"
+
+func TestHasRole(t *testing.T) {
+	rows := []struct {
+		userRole string
+		role     string
+		expected bool
+	}{
+		{RoleViewer, RoleViewer, true},
+		{RoleViewer, RoleOperator, false},
+		{RoleOperator, RoleViewer, true},
+		{RoleOperator, RoleAdmin, false},
+		{RoleAdmin, RoleOperator, true},
+		{RoleAdmin, RoleAdmin, true},
+		{"", RoleViewer, false},
+		{RoleAdmin, "superuser", false},
+	}
+
+	for _, row := range rows {
+		user := &User{Role: row.userRole}
+		if user.HasRole(row.role) != row.expected {
+			t.Error("unexpected result", row.userRole, row.role)
+		}
+	}
+}
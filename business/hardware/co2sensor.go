@@ -17,6 +17,9 @@ type SGP30 interface {
 	Measure() (eCO2 uint16, TVOC uint16, err error)
 	GetBaseline() (eCO2 uint16, TVOC uint16, err error)
 	SetBaseline(eCO2 uint16, TVOC uint16) error
+	GetSerialID() (uint64, error)
+	GetFeatureSet() (uint16, error)
+	MeasureTest() error
 }
 
 type Co2SensorCfg struct {
@@ -26,32 +29,62 @@ type Co2SensorCfg struct {
 }
 
 func NewPiCo2Sensor(cfg *Co2SensorCfg, dbContext context.DbContext) *Co2Sensor {
-	co2Sensor := &Co2Sensor{
+	return &Co2Sensor{
 		cfg:       cfg,
 		dbContext: dbContext,
+		sgp30:     NewSGP30(cfg.Logger),
 	}
+}
 
+// NewSGP30 returns the i2c sensor on arm and a fake sensor everywhere else.
+func NewSGP30(logger echo.Logger) SGP30 {
 	if runtime.GOARCH == "arm" {
-		cfg.Logger.Info("Detected arm, starting i2c sensor")
+		logger.Info("Detected arm, starting i2c sensor")
 		sensorCfg := sensor.DefaultConfig()
-		sensorCfg.Logger = NewLoggingAdaptor(cfg.Logger)
-		co2Sensor.sgp30 = sensor.NewSensor(sensorCfg)
-	} else {
-		cfg.Logger.Info("Detected non-arm, starting fake sensor values")
-		co2Sensor.sgp30 = NewFakeSgp30Sensor()
+		sensorCfg.Logger = NewLoggingAdaptor(logger)
+		return newI2CSgp30(sensorCfg)
 	}
 
-	return co2Sensor
+	logger.Info("Detected non-arm, starting fake sensor values")
+	return NewFakeSgp30Sensor()
 }
 
 type Co2Sensor struct {
-	cfg       *Co2SensorCfg
-	sgp30     SGP30
-	stopChan  chan int
-	lock      sync.Mutex
-	ECO2      uint16
-	TVOC      uint16
-	dbContext context.DbContext
+	cfg        *Co2SensorCfg
+	sgp30      SGP30
+	stopChan   chan int
+	lock       sync.Mutex
+	ECO2       uint16
+	TVOC       uint16
+	dbContext  context.DbContext
+	status     SensorStatus
+	statusLock sync.Mutex
+}
+
+type SensorStatus struct {
+	Running   bool
+	StartedAt time.Time
+	LastRead  time.Time
+	LastError string
+	ReadDelay time.Duration
+}
+
+// Status reports when the sensor was last read successfully and the last error if it failed since.
+func (s *Co2Sensor) Status() SensorStatus {
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+
+	status := s.status
+	status.ReadDelay = time.Millisecond * time.Duration(s.cfg.ReadDelayMillis)
+
+	return status
+}
+
+func (s *Co2Sensor) setStatus(update func(status *SensorStatus)) {
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+
+	update(&s.status)
 }
 
 func (s *Co2Sensor) Start() error {
@@ -63,9 +96,16 @@ func (s *Co2Sensor) Start() error {
 	}
 
 	if err := s.sgp30.Init(); err != nil {
+		s.setStatus(func(status *SensorStatus) {
+			status.LastError = err.Error()
+		})
 		return err
 	}
 
+	s.setStatus(func(status *SensorStatus) {
+		*status = SensorStatus{Running: true, StartedAt: time.Now()}
+	})
+
 	s.applySavedSensorBaseline()
 
 	s.stopChan = make(chan int)
@@ -89,6 +129,18 @@ func (s *Co2Sensor) applySavedSensorBaseline() {
 	}
 }
 
+func (s *Co2Sensor) saveSensorBaseline() {
+	eCO2, TVOC, err := s.sgp30.GetBaseline()
+	if err != nil {
+		s.cfg.Logger.Error("failed to get baseline", err)
+		return
+	}
+
+	if err := s.dbContext.SetSensorBaseline(eCO2, TVOC); err != nil {
+		s.cfg.Logger.Error(err)
+	}
+}
+
 func (s *Co2Sensor) loopRoutine(readTicker *time.Ticker, baseLineTicker *time.Ticker) {
 	defer func() {
 		readTicker.Stop()
@@ -106,19 +158,21 @@ func (s *Co2Sensor) loopRoutine(readTicker *time.Ticker, baseLineTicker *time.Ti
 			}
 			s.ECO2 = eCO2
 			s.TVOC = TVOC
-		case <-baseLineTicker.C:
-			eCO2, TVOC, err := s.sgp30.GetBaseline()
-			if err != nil {
-				s.cfg.Logger.Error("failed to get baseline", err)
-			} else {
-				if err := s.dbContext.SetSensorBaseline(eCO2, TVOC); err != nil {
-					s.cfg.Logger.Error(err)
+			s.setStatus(func(status *SensorStatus) {
+				if err != nil {
+					status.LastError = err.Error()
+				} else {
+					status.LastRead = time.Now()
+					status.LastError = ""
 				}
-			}
+			})
+		case <-baseLineTicker.C:
+			s.saveSensorBaseline()
 		}
 	}
 }
 
+// Close stops reading and saves the sensor's latest baseline so it survives a restart.
 func (s *Co2Sensor) Close() error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
@@ -136,6 +190,10 @@ func (s *Co2Sensor) Close() error {
 	}
 
 	s.stopChan = nil
+	s.saveSensorBaseline()
+	s.setStatus(func(status *SensorStatus) {
+		status.Running = false
+	})
 
 	return s.sgp30.Close()
 }
@@ -1,6 +1,7 @@
 package hardware
 
 import (
+	"goairmon/business/data/context"
 	"goairmon/business/data/models"
 	"runtime"
 	"testing"
@@ -163,14 +164,46 @@ func (f *_fakeDbContext) FindUserByName(username string) (*models.User, error) {
 	panic("not implemented")
 }
 
+func (f *_fakeDbContext) GetUsers() ([]*models.User, error) {
+	panic("not implemented")
+}
+
 func (f *_fakeDbContext) DeleteUser(id uuid.UUID) error {
 	panic("not implemented")
 }
 
+func (f *_fakeDbContext) CreateInvite(invite *models.Invite) error {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) FindInvite(id uuid.UUID) (*models.Invite, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) GetInvites() ([]*models.Invite, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) DeleteInvite(id uuid.UUID) error {
+	panic("not implemented")
+}
+
 func (f *_fakeDbContext) PushSensorPoint(point *models.SensorPoint) error {
 	panic("not implemented")
 }
 
+func (f *_fakeDbContext) MergeSensorPoints(points []*models.SensorPoint) (int, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) MergeArchivedDay(day time.Time, points []*models.SensorPoint) (int, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) Archives() context.ArchiveStore {
+	panic("not implemented")
+}
+
 func (f *_fakeDbContext) GetSensorPoints(count int) ([]*models.SensorPoint, error) {
 	panic("not implemented")
 }
@@ -190,3 +223,7 @@ func (f *_fakeDbContext) Save() error {
 func (f *_fakeDbContext) ClearSensorPoints() error {
 	panic("not implemented")
 }
+
+func (f *_fakeDbContext) Snapshot(fn func() error) error {
+	panic("not implemented")
+}
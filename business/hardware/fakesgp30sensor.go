@@ -4,6 +4,8 @@ import (
 	"math"
 	"math/rand"
 	"time"
+
+	"github.com/ataboo/sgp30go/sensor"
 )
 
 func init() {
@@ -20,6 +22,8 @@ func NewFakeSgp30Sensor() SGP30 {
 		min:         min,
 		max:         max,
 		variance:    0.1,
+		serialID:    0x12345678,
+		featureSet:  sensor.ExpectedFeatureSet,
 	}
 }
 
@@ -31,6 +35,11 @@ type fakeSgp30 struct {
 	variance          float64
 	staticECO2        uint16
 	staticTVOC        uint16
+	baselineECO2      uint16
+	baselineTVOC      uint16
+	serialID          uint64
+	featureSet        uint16
+	failure           error
 }
 
 // Init() error
@@ -44,7 +53,7 @@ func (s *fakeSgp30) Init() error {
 		time.Sleep(time.Duration(s.actionDelayMillis) * time.Millisecond)
 	}
 
-	return nil
+	return s.failure
 }
 
 func (s *fakeSgp30) GetBaseline() (eCO2 uint16, TVOC uint16, err error) {
@@ -52,6 +61,14 @@ func (s *fakeSgp30) GetBaseline() (eCO2 uint16, TVOC uint16, err error) {
 		time.Sleep(time.Duration(s.actionDelayMillis) * time.Millisecond)
 	}
 
+	if s.failure != nil {
+		return 0, 0, s.failure
+	}
+
+	if s.baselineECO2 != 0 || s.baselineTVOC != 0 {
+		return s.baselineECO2, s.baselineTVOC, nil
+	}
+
 	if s.staticECO2 != 0 || s.staticTVOC != 0 {
 		return s.staticECO2, s.staticTVOC, nil
 	}
@@ -64,7 +81,12 @@ func (s *fakeSgp30) SetBaseline(eCO2 uint16, TVOC uint16) error {
 		time.Sleep(time.Duration(s.actionDelayMillis) * time.Millisecond)
 	}
 
-	return nil
+	if s.failure == nil {
+		s.baselineECO2 = eCO2
+		s.baselineTVOC = TVOC
+	}
+
+	return s.failure
 }
 
 func (s *fakeSgp30) Measure() (eCO2 uint16, TVOC uint16, err error) {
@@ -72,6 +94,10 @@ func (s *fakeSgp30) Measure() (eCO2 uint16, TVOC uint16, err error) {
 		time.Sleep(time.Duration(s.actionDelayMillis) * time.Millisecond)
 	}
 
+	if s.failure != nil {
+		return 0, 0, s.failure
+	}
+
 	if s.staticECO2 != 0 || s.staticTVOC != 0 {
 		return s.staticECO2, s.staticTVOC, nil
 	}
@@ -91,3 +117,15 @@ func (s *fakeSgp30) Close() error {
 
 	return nil
 }
+
+func (s *fakeSgp30) GetSerialID() (uint64, error) {
+	return s.serialID, s.failure
+}
+
+func (s *fakeSgp30) GetFeatureSet() (uint16, error) {
+	return s.featureSet, s.failure
+}
+
+func (s *fakeSgp30) MeasureTest() error {
+	return s.failure
+}
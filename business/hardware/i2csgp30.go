@@ -0,0 +1,123 @@
+package hardware
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ataboo/sgp30go/sensor"
+	"golang.org/x/exp/io/i2c"
+)
+
+const (
+	selfTestPassed      uint16 = 0xd400
+	selfTestDelayMillis        = 220
+)
+
+func newI2CSgp30(cfg *sensor.Config) SGP30 {
+	return &i2cSgp30{
+		SGP30Sensor: sensor.NewSensor(cfg),
+		cfg:         cfg,
+	}
+}
+
+// Adds the diagnostic commands the sgp30go driver doesn't expose, each on its own connection.
+type i2cSgp30 struct {
+	*sensor.SGP30Sensor
+	cfg *sensor.Config
+}
+
+func (s *i2cSgp30) GetSerialID() (uint64, error) {
+	words, err := s.command(sensor.GetSerialID, 3, s.cfg.DelayMillis)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read serial: %s", err)
+	}
+
+	return uint64(words[0])<<32 | uint64(words[1])<<16 | uint64(words[2]), nil
+}
+
+func (s *i2cSgp30) GetFeatureSet() (uint16, error) {
+	words, err := s.command(sensor.GetFeatureSetVersion, 1, s.cfg.DelayMillis)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read feature set: %s", err)
+	}
+
+	return words[0], nil
+}
+
+// MeasureTest runs the on-chip self-test, then restarts air quality measurement as the datasheet requires.
+func (s *i2cSgp30) MeasureTest() error {
+	words, err := s.command(sensor.MeasureTest, 1, selfTestDelayMillis)
+	if err != nil {
+		return fmt.Errorf("failed to run self-test: %s", err)
+	}
+
+	if words[0] != selfTestPassed {
+		return fmt.Errorf("self-test failed with result %#04x", words[0])
+	}
+
+	if _, err := s.command(sensor.InitAirQuality, 0, s.cfg.DelayMillis); err != nil {
+		return fmt.Errorf("failed to restart air quality measurement: %s", err)
+	}
+
+	return nil
+}
+
+func (s *i2cSgp30) command(command uint16, replyWords int, delayMillis int) ([]uint16, error) {
+	if _, err := os.Stat(s.cfg.I2CFsPath); err != nil {
+		return nil, fmt.Errorf("i2c FS path not found")
+	}
+
+	device, err := i2c.Open(&i2c.Devfs{Dev: s.cfg.I2CFsPath}, int(s.cfg.I2CAddr))
+	if err != nil {
+		return nil, err
+	}
+	defer device.Close()
+
+	buffer := make([]byte, 2)
+	binary.BigEndian.PutUint16(buffer, command)
+	if err := device.Write(buffer); err != nil {
+		return nil, err
+	}
+
+	time.Sleep(time.Duration(delayMillis) * time.Millisecond)
+
+	if replyWords == 0 {
+		return nil, nil
+	}
+
+	reply := make([]byte, replyWords*3)
+	if err := device.Read(reply); err != nil {
+		return nil, err
+	}
+
+	words := make([]uint16, replyWords)
+	for i := range words {
+		word := reply[i*3 : i*3+2]
+		if crc := sgp30Crc(word); crc != reply[i*3+2] {
+			return nil, fmt.Errorf("crc mismatch %x, %x", reply[i*3+2], crc)
+		}
+
+		words[i] = binary.BigEndian.Uint16(word)
+	}
+
+	return words, nil
+}
+
+// CRC-8 with polynomial 0x31 and init 0xff, from the SGP30 datasheet.
+func sgp30Crc(data []byte) byte {
+	crc := byte(0xff)
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x31
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
@@ -0,0 +1,175 @@
+package hardware
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/ataboo/sgp30go/sensor"
+)
+
+type SensorCheckCfg struct {
+	Measurements int
+	DelayMillis  int
+	// Progress is written here as the check runs
+	Out io.Writer
+}
+
+type SensorCheckResult struct {
+	SerialID     uint64
+	FeatureSet   uint16
+	InitTime     time.Duration
+	Measurements int
+	Failures     int
+	ECO2         ReadingStats
+	TVOC         ReadingStats
+	MeasureTime  TimingStats
+	BaselineECO2 uint16
+	BaselineTVOC uint16
+}
+
+type ReadingStats struct {
+	Min  uint16
+	Max  uint16
+	Mean float64
+}
+
+type TimingStats struct {
+	Min  time.Duration
+	Max  time.Duration
+	Mean time.Duration
+}
+
+// CheckSensor inits the sensor, checks its identity and self-test, then times a run of measurements.
+// The result is returned with the error when only some measurements failed.
+func CheckSensor(sgp30 SGP30, cfg *SensorCheckCfg) (*SensorCheckResult, error) {
+	out := cfg.Out
+	if out == nil {
+		out = ioutil.Discard
+	}
+
+	result := &SensorCheckResult{}
+
+	start := time.Now()
+	if err := sgp30.Init(); err != nil {
+		return nil, sensorError("init sensor", err)
+	}
+	defer sgp30.Close()
+	result.InitTime = time.Since(start)
+
+	var err error
+	if result.SerialID, err = sgp30.GetSerialID(); err != nil {
+		return nil, sensorError("read serial", err)
+	}
+
+	if result.FeatureSet, err = sgp30.GetFeatureSet(); err != nil {
+		return nil, sensorError("read feature set", err)
+	}
+
+	if result.FeatureSet != sensor.ExpectedFeatureSet {
+		return nil, fmt.Errorf("unexpected feature set %#04x, expected %#04x for an SGP30", result.FeatureSet, sensor.ExpectedFeatureSet)
+	}
+
+	if err := sgp30.MeasureTest(); err != nil {
+		return nil, sensorError("run self-test", err)
+	}
+	fmt.Fprintf(out, "Sensor %#012x passed self-test\n", result.SerialID)
+
+	var lastErr error
+	var successes int
+	var eCO2Total, TVOCTotal float64
+	var timeTotal time.Duration
+	for i := 0; i < cfg.Measurements; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(cfg.DelayMillis) * time.Millisecond)
+		}
+
+		start := time.Now()
+		eCO2, TVOC, err := sgp30.Measure()
+		elapsed := time.Since(start)
+		result.Measurements++
+
+		if err != nil {
+			result.Failures++
+			lastErr = err
+			fmt.Fprintf(out, "%d/%d failed: %s\n", i+1, cfg.Measurements, err)
+			continue
+		}
+
+		fmt.Fprintf(out, "%d/%d eCO2: %d ppm, TVOC: %d ppb in %s\n", i+1, cfg.Measurements, eCO2, TVOC, elapsed)
+
+		successes++
+		if successes == 1 {
+			result.ECO2 = ReadingStats{Min: eCO2, Max: eCO2}
+			result.TVOC = ReadingStats{Min: TVOC, Max: TVOC}
+			result.MeasureTime = TimingStats{Min: elapsed, Max: elapsed}
+		}
+		result.ECO2.add(eCO2)
+		result.TVOC.add(TVOC)
+		result.MeasureTime.add(elapsed)
+
+		eCO2Total += float64(eCO2)
+		TVOCTotal += float64(TVOC)
+		timeTotal += elapsed
+	}
+
+	if successes > 0 {
+		result.ECO2.Mean = eCO2Total / float64(successes)
+		result.TVOC.Mean = TVOCTotal / float64(successes)
+		result.MeasureTime.Mean = timeTotal / time.Duration(successes)
+	}
+
+	if result.BaselineECO2, result.BaselineTVOC, err = sgp30.GetBaseline(); err != nil {
+		return result, sensorError("read baseline", err)
+	}
+
+	if lastErr != nil {
+		return result, sensorError(fmt.Sprintf("take %d of %d measurements", result.Failures, result.Measurements), lastErr)
+	}
+
+	return result, nil
+}
+
+func (s *ReadingStats) add(value uint16) {
+	if value < s.Min {
+		s.Min = value
+	}
+
+	if value > s.Max {
+		s.Max = value
+	}
+}
+
+func (s *TimingStats) add(value time.Duration) {
+	if value < s.Min {
+		s.Min = value
+	}
+
+	if value > s.Max {
+		s.Max = value
+	}
+}
+
+var sensorErrorHints = []struct {
+	match string
+	hint  string
+}{
+	{"remote I/O error", "the sensor didn't answer on the i2c bus. Check the wiring and that `sudo i2cdetect -y 1` shows 0x58. If it only happens sometimes, try slowing the i2c baudrate with `dtparam=i2c_arm_baudrate=10000` in /boot/config.txt"},
+	{"i2c FS path not found", "/dev/i2c-1 doesn't exist. Enable I2C with `sudo raspi-config` and reboot"},
+	{"permission denied", "the i2c device can't be opened. Run as root or add the user to the i2c group"},
+	{"crc mismatch", "reads from the sensor are corrupted. Check the wiring and try slowing the i2c baudrate"},
+	{"sensor not found", "a device answered but doesn't look like an SGP30. Check the i2c address with `sudo i2cdetect -y 1`"},
+}
+
+// Adds a troubleshooting hint to common i2c failures.
+func sensorError(action string, err error) error {
+	for _, h := range sensorErrorHints {
+		if strings.Contains(err.Error(), h.match) {
+			return fmt.Errorf("failed to %s: %s\n%s", action, err, h.hint)
+		}
+	}
+
+	return fmt.Errorf("failed to %s: %s", action, err)
+}
@@ -0,0 +1,60 @@
+package hardware
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCheckSensor(t *testing.T) {
+	sgp30 := NewFakeSgp30Sensor().(*fakeSgp30)
+	sgp30.staticECO2 = 450
+	sgp30.staticTVOC = 12
+
+	out := &bytes.Buffer{}
+	result, err := CheckSensor(sgp30, &SensorCheckCfg{Measurements: 3, Out: out})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.SerialID != sgp30.serialID || result.Measurements != 3 || result.Failures != 0 {
+		t.Error("unexpected result", result)
+	}
+
+	if result.ECO2.Min != 450 || result.ECO2.Max != 450 || result.ECO2.Mean != 450 || result.TVOC.Mean != 12 {
+		t.Error("unexpected stats", result.ECO2, result.TVOC)
+	}
+
+	if result.BaselineECO2 != 450 || result.BaselineTVOC != 12 {
+		t.Error("unexpected baseline", result.BaselineECO2, result.BaselineTVOC)
+	}
+
+	if strings.Count(out.String(), "eCO2: 450 ppm") != 3 {
+		t.Error("unexpected output", out.String())
+	}
+}
+
+func TestCheckSensorFailures(t *testing.T) {
+	sgp30 := NewFakeSgp30Sensor().(*fakeSgp30)
+	sgp30.failure = fmt.Errorf("write /dev/i2c-1: remote I/O error")
+
+	result, err := CheckSensor(sgp30, &SensorCheckCfg{Measurements: 3})
+	if result != nil || err == nil {
+		t.Fatal("expected init to fail")
+	}
+
+	if !strings.Contains(err.Error(), "failed to init sensor") || !strings.Contains(err.Error(), "i2cdetect") {
+		t.Error("expected remote I/O hint", err)
+	}
+
+	sgp30.failure = nil
+	sgp30.featureSet = 0x0042
+	if _, err := CheckSensor(sgp30, &SensorCheckCfg{Measurements: 3}); err == nil || !strings.Contains(err.Error(), "feature set") {
+		t.Error("expected feature set error", err)
+	}
+
+	if err := sensorError("measure", fmt.Errorf("bus exploded")); err.Error() != "failed to measure: bus exploded" {
+		t.Error("unexpected error", err)
+	}
+}
@@ -0,0 +1,354 @@
+package applog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	stdlog "log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Levels by name, "off" turns logging off.
+var levels = map[string]log.Lvl{
+	"debug": log.DEBUG,
+	"info":  log.INFO,
+	"warn":  log.WARN,
+	"error": log.ERROR,
+	"off":   log.OFF,
+}
+
+var levelNames = map[log.Lvl]string{
+	log.DEBUG: "debug",
+	log.INFO:  "info",
+	log.WARN:  "warn",
+	log.ERROR: "error",
+}
+
+type Config struct {
+	Level  string
+	Format string
+	// Written to stdout when empty, otherwise rotated to File.1, File.2... once it would grow past MaxBytes
+	File     string
+	MaxBytes int64
+	MaxFiles int
+}
+
+// NewLogger builds the logger from the config, opening the log file if there is one.
+func NewLogger(cfg *Config) (*Logger, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	format := strings.ToLower(strings.TrimSpace(cfg.Format))
+	if format == "" {
+		format = FormatText
+	}
+	if format != FormatText && format != FormatJSON {
+		return nil, fmt.Errorf("log format must be %s or %s", FormatText, FormatJSON)
+	}
+
+	var out io.Writer = os.Stdout
+	if cfg.File != "" {
+		file, err := NewRotatingFile(cfg.File, cfg.MaxBytes, cfg.MaxFiles)
+		if err != nil {
+			return nil, err
+		}
+		out = file
+	}
+
+	return &Logger{
+		core: &core{out: out, level: level, format: format, now: time.Now},
+	}, nil
+}
+
+// Default logs info and up as text to stdout, for when logging isn't configured, e.g. in tests.
+func Default() *Logger {
+	return &Logger{
+		core: &core{out: os.Stdout, level: log.INFO, format: FormatText, now: time.Now},
+	}
+}
+
+func ParseLevel(name string) (log.Lvl, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return log.INFO, nil
+	}
+
+	level, ok := levels[name]
+	if !ok {
+		return 0, fmt.Errorf("log level must be debug, info, warn, error or off")
+	}
+
+	return level, nil
+}
+
+// The output and level shared by a logger and its subsystems.
+type core struct {
+	out    io.Writer
+	level  log.Lvl
+	format string
+	lock   sync.Mutex
+	now    func() time.Time
+}
+
+// Writes leveled entries as text or JSON lines, tagged with the subsystem they came from.
+// It implements echo.Logger so it can be handed to echo and the services.
+type Logger struct {
+	core      *core
+	subsystem string
+}
+
+// Subsystem returns a logger sharing this one's output that tags entries with name, e.g. sensor or auth.
+func (l *Logger) Subsystem(name string) *Logger {
+	return &Logger{core: l.core, subsystem: name}
+}
+
+func (l *Logger) Output() io.Writer {
+	l.core.lock.Lock()
+	defer l.core.lock.Unlock()
+
+	return l.core.out
+}
+
+func (l *Logger) SetOutput(w io.Writer) {
+	l.core.lock.Lock()
+	defer l.core.lock.Unlock()
+
+	l.core.out = w
+}
+
+// Prefix is the subsystem.
+func (l *Logger) Prefix() string {
+	return l.subsystem
+}
+
+func (l *Logger) SetPrefix(p string) {
+	l.subsystem = p
+}
+
+func (l *Logger) Level() log.Lvl {
+	l.core.lock.Lock()
+	defer l.core.lock.Unlock()
+
+	return l.core.level
+}
+
+func (l *Logger) SetLevel(v log.Lvl) {
+	l.core.lock.Lock()
+	defer l.core.lock.Unlock()
+
+	l.core.level = v
+}
+
+// SetHeader is ignored, the format is set by Config.Format.
+func (l *Logger) SetHeader(h string) {}
+
+func (l *Logger) Print(i ...interface{}) {
+	l.write(log.INFO, message(i), nil)
+}
+
+func (l *Logger) Printf(format string, args ...interface{}) {
+	l.write(log.INFO, fmt.Sprintf(format, args...), nil)
+}
+
+func (l *Logger) Printj(j log.JSON) {
+	l.write(log.INFO, "", j)
+}
+
+func (l *Logger) Debug(i ...interface{}) {
+	l.write(log.DEBUG, message(i), nil)
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.write(log.DEBUG, fmt.Sprintf(format, args...), nil)
+}
+
+func (l *Logger) Debugj(j log.JSON) {
+	l.write(log.DEBUG, "", j)
+}
+
+func (l *Logger) Info(i ...interface{}) {
+	l.write(log.INFO, message(i), nil)
+}
+
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.write(log.INFO, fmt.Sprintf(format, args...), nil)
+}
+
+func (l *Logger) Infoj(j log.JSON) {
+	l.write(log.INFO, "", j)
+}
+
+func (l *Logger) Warn(i ...interface{}) {
+	l.write(log.WARN, message(i), nil)
+}
+
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.write(log.WARN, fmt.Sprintf(format, args...), nil)
+}
+
+func (l *Logger) Warnj(j log.JSON) {
+	l.write(log.WARN, "", j)
+}
+
+func (l *Logger) Error(i ...interface{}) {
+	l.write(log.ERROR, message(i), nil)
+}
+
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.write(log.ERROR, fmt.Sprintf(format, args...), nil)
+}
+
+func (l *Logger) Errorj(j log.JSON) {
+	l.write(log.ERROR, "", j)
+}
+
+func (l *Logger) Fatal(i ...interface{}) {
+	l.write(fatal, message(i), nil)
+	os.Exit(1)
+}
+
+func (l *Logger) Fatalj(j log.JSON) {
+	l.write(fatal, "", j)
+	os.Exit(1)
+}
+
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+	l.write(fatal, fmt.Sprintf(format, args...), nil)
+	os.Exit(1)
+}
+
+func (l *Logger) Panic(i ...interface{}) {
+	msg := message(i)
+	l.write(fatal, msg, nil)
+	panic(msg)
+}
+
+func (l *Logger) Panicj(j log.JSON) {
+	l.write(fatal, "", j)
+	panic(j)
+}
+
+func (l *Logger) Panicf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	l.write(fatal, msg, nil)
+	panic(msg)
+}
+
+// Fatal and panic entries are always written, unless logging is off.
+const fatal = log.OFF + 1
+
+// Writes the entry if its level is enabled, fields are added to it as key values.
+func (l *Logger) write(level log.Lvl, msg string, fields map[string]interface{}) {
+	c := l.core
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if level < c.level || c.level == log.OFF {
+		return
+	}
+
+	name, ok := levelNames[level]
+	if !ok {
+		name = "fatal"
+	}
+
+	var line []byte
+	if c.format == FormatJSON {
+		line = jsonLine(c.now(), name, l.subsystem, msg, fields)
+	} else {
+		line = textLine(c.now(), name, l.subsystem, msg, fields)
+	}
+
+	c.out.Write(line)
+}
+
+// StdLogger writes errors from the standard library, like http.Server's, as error entries.
+func (l *Logger) StdLogger() *stdlog.Logger {
+	return stdlog.New(errorWriter{l}, "", 0)
+}
+
+type errorWriter struct {
+	logger *Logger
+}
+
+func (w errorWriter) Write(p []byte) (int, error) {
+	w.logger.write(log.ERROR, strings.TrimSuffix(string(p), "\n"), nil)
+
+	return len(p), nil
+}
+
+// Writes the entry with fields, e.g. for request logs.
+func (l *Logger) Fields(level log.Lvl, msg string, fields map[string]interface{}) {
+	l.write(level, msg, fields)
+}
+
+func jsonLine(now time.Time, level string, subsystem string, msg string, fields map[string]interface{}) []byte {
+	entry := make(map[string]interface{}, len(fields)+4)
+	for key, val := range fields {
+		entry[key] = val
+	}
+
+	entry["time"] = now.Format(time.RFC3339)
+	entry["level"] = level
+	if subsystem != "" {
+		entry["subsystem"] = subsystem
+	}
+	if msg != "" {
+		entry["message"] = msg
+	}
+
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		raw, _ = json.Marshal(map[string]string{"time": now.Format(time.RFC3339), "level": level, "message": msg})
+	}
+
+	return append(raw, '\n')
+}
+
+func textLine(now time.Time, level string, subsystem string, msg string, fields map[string]interface{}) []byte {
+	var b strings.Builder
+	b.WriteString(now.Format(time.RFC3339))
+	b.WriteString(" ")
+	b.WriteString(strings.ToUpper(level))
+	if subsystem != "" {
+		b.WriteString(" [" + subsystem + "]")
+	}
+	if msg != "" {
+		b.WriteString(" " + msg)
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		val := fmt.Sprint(fields[key])
+		if strings.ContainsAny(val, " \"=") {
+			val = fmt.Sprintf("%q", val)
+		}
+		b.WriteString(" " + key + "=" + val)
+	}
+	b.WriteString("\n")
+
+	return []byte(b.String())
+}
+
+// Joins the values with spaces, unlike fmt.Sprint which runs strings together.
+func message(i []interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(i...), "\n")
+}
@@ -0,0 +1,138 @@
+package applog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+func _logger(t *testing.T, cfg *Config) (*Logger, *bytes.Buffer) {
+	logger, err := NewLogger(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	logger.SetOutput(buf)
+	logger.core.now = func() time.Time {
+		return time.Date(2019, 10, 1, 3, 0, 0, 0, time.UTC)
+	}
+
+	return logger, buf
+}
+
+func TestTextFormat(t *testing.T) {
+	logger, buf := _logger(t, &Config{Level: "info"})
+
+	logger.Debug("hidden")
+	logger.Subsystem("sensor").Error("failed to measure", fmt.Errorf("remote I/O error"))
+	logger.Fields(logger.Level(), "request", map[string]interface{}{"status": 200, "uri": "/auth/login?next=a b"})
+
+	expected := "2019-10-01T03:00:00Z ERROR [sensor] failed to measure remote I/O error\n" +
+		"2019-10-01T03:00:00Z INFO request status=200 uri=\"/auth/login?next=a b\"\n"
+	if buf.String() != expected {
+		t.Error("unexpected text log", buf.String())
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	logger, buf := _logger(t, &Config{Level: "warn", Format: "json"})
+
+	logger.Info("hidden")
+	logger.Subsystem("poll").Warnf("poll took %ds", 3)
+
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err, buf.String())
+	}
+
+	if entry["level"] != "warn" || entry["subsystem"] != "poll" || entry["message"] != "poll took 3s" || entry["time"] != "2019-10-01T03:00:00Z" {
+		t.Error("unexpected json log", entry)
+	}
+}
+
+func TestLevelOff(t *testing.T) {
+	logger, buf := _logger(t, &Config{Level: "off"})
+	logger.Error("hidden")
+
+	if buf.Len() != 0 {
+		t.Error("expected nothing logged", buf.String())
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	for _, cfg := range []*Config{{Level: "loud"}, {Format: "xml"}} {
+		if _, err := NewLogger(cfg); err == nil {
+			t.Error("expected invalid config to fail", cfg)
+		}
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goairmon_applog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "logs", "goairmon.log")
+	file, err := NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := map[string]string{
+		path:        "four\nfive\n",
+		path + ".1": "three\n",
+		path + ".2": "one\ntwo\n",
+	}
+	for name, content := range expected {
+		raw, _ := ioutil.ReadFile(name)
+		if string(raw) != content {
+			t.Error("unexpected content", name, string(raw))
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("expected only two rotated files to be kept")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	logger, buf := _logger(t, &Config{Format: "json"})
+
+	server := echo.New()
+	server.Use(logger.Subsystem("http").Middleware())
+	server.GET("/missing", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusNotFound)
+	})
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", "/missing", nil))
+
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err, buf.String())
+	}
+
+	if rec.Code != http.StatusNotFound || entry["level"] != "warn" || entry["subsystem"] != "http" || entry["status"] != float64(404) || !strings.Contains(entry["error"].(string), "Not Found") {
+		t.Error("unexpected request log", rec.Code, entry)
+	}
+}
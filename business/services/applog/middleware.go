@@ -0,0 +1,46 @@
+package applog
+
+import (
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/labstack/gommon/log"
+)
+
+// Middleware logs each request, at warn for client errors and error for server errors.
+func (l *Logger) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			req := c.Request()
+			res := c.Response()
+			level := log.INFO
+			if res.Status >= 500 {
+				level = log.ERROR
+			} else if res.Status >= 400 {
+				level = log.WARN
+			}
+
+			fields := map[string]interface{}{
+				"method":     req.Method,
+				"uri":        req.RequestURI,
+				"status":     res.Status,
+				"remote_ip":  c.RealIP(),
+				"latency_ms": time.Since(start).Nanoseconds() / int64(time.Millisecond),
+				"bytes_out":  res.Size,
+			}
+			if err != nil {
+				fields["error"] = err.Error()
+			}
+
+			l.Fields(level, "request", fields)
+
+			return nil
+		}
+	}
+}
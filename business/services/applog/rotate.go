@@ -0,0 +1,110 @@
+package applog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// NewRotatingFile opens path for appending, creating its directory if needed.
+func NewRotatingFile(path string, maxBytes int64, maxFiles int) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %s", err)
+	}
+
+	r := &RotatingFile{path: path, maxBytes: maxBytes, maxFiles: maxFiles}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// A log file that's moved to path.1, path.2... up to maxFiles once a write would take it past maxBytes.
+type RotatingFile struct {
+	path     string
+	maxBytes int64
+	maxFiles int
+	file     *os.File
+	size     int64
+	lock     sync.Mutex
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.maxBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxBytes {
+		if err := r.rotate(); err != nil && r.file == nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+
+	return n, err
+}
+
+func (r *RotatingFile) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.file.Close()
+}
+
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %s", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open log file: %s", err)
+	}
+
+	r.file = file
+	r.size = info.Size()
+
+	return nil
+}
+
+// Reopens the file even if moving the old ones fails, so logging carries on.
+func (r *RotatingFile) rotate() error {
+	r.file.Close()
+	r.file = nil
+
+	err := r.shift()
+	if openErr := r.open(); openErr != nil {
+		return openErr
+	}
+
+	return err
+}
+
+func (r *RotatingFile) shift() error {
+	if r.maxFiles < 1 {
+		return os.Remove(r.path)
+	}
+
+	os.Remove(RotatedName(r.path, r.maxFiles))
+	for i := r.maxFiles - 1; i >= 1; i-- {
+		if err := os.Rename(RotatedName(r.path, i), RotatedName(r.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate log: %s", err)
+		}
+	}
+
+	if err := os.Rename(r.path, RotatedName(r.path, 1)); err != nil {
+		return fmt.Errorf("failed to rotate log: %s", err)
+	}
+
+	return nil
+}
+
+// RotatedName is the nth newest rotated file, path.n.
+func RotatedName(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
@@ -0,0 +1,172 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"goairmon/business/data/context"
+	"goairmon/business/data/models"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+func NewArchiveService(cfg *Config, dbContext context.DbContext) *ArchiveService {
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
+
+	if cfg.CheckDelaySeconds == 0 {
+		cfg.CheckDelaySeconds = 60
+	}
+
+	return &ArchiveService{
+		cfg:       cfg,
+		dbContext: dbContext,
+		now:       time.Now,
+	}
+}
+
+type Config struct {
+	Location          *time.Location
+	CheckDelaySeconds int
+	Logger            echo.Logger
+}
+
+// Writes every complete day held in the DbContext to its archives on startup and at each day rollover.
+type ArchiveService struct {
+	cfg       *Config
+	dbContext context.DbContext
+	stopChan  chan int
+	lastDay   time.Time
+	lock      sync.Mutex
+	now       func() time.Time
+}
+
+func (a *ArchiveService) Start() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.stopChan != nil {
+		return fmt.Errorf("service already started")
+	}
+
+	now := a.now()
+	if _, err := a.archiveCompleteDays(now); err != nil {
+		a.cfg.Logger.Error("failed to archive complete days", err)
+	}
+	a.lastDay = a.dayStart(now)
+
+	a.stopChan = make(chan int)
+
+	ticker := time.NewTicker(time.Second * time.Duration(a.cfg.CheckDelaySeconds))
+	go a.archiveRoutine(ticker)
+
+	return nil
+}
+
+func (a *ArchiveService) Stop() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.stopChan == nil {
+		return fmt.Errorf("service already stopped")
+	}
+
+	select {
+	case a.stopChan <- 0:
+		break
+	case <-time.After(time.Millisecond * 100):
+		break
+	}
+
+	a.stopChan = nil
+
+	return nil
+}
+
+// ArchiveCompleteDays merges every day before the day of now into the archives, through the DbContext
+// so a backup snapshot never sees an archive half written.
+// Returns the number of days that had points added.
+func (a *ArchiveService) ArchiveCompleteDays(now time.Time) (int, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.archiveCompleteDays(now)
+}
+
+func (a *ArchiveService) archiveRoutine(ticker *time.Ticker) {
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stopChan:
+			return
+		case <-ticker.C:
+			if err := a.checkRollover(); err != nil {
+				a.cfg.Logger.Error("failed to archive complete days", err)
+			}
+		}
+	}
+}
+
+func (a *ArchiveService) checkRollover() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	now := a.now()
+	today := a.dayStart(now)
+	if today.Equal(a.lastDay) {
+		return nil
+	}
+
+	a.lastDay = today
+	_, err := a.archiveCompleteDays(now)
+
+	return err
+}
+
+func (a *ArchiveService) archiveCompleteDays(now time.Time) (int, error) {
+	points, err := a.dbContext.GetSensorPoints(0)
+	if err != nil {
+		return 0, err
+	}
+
+	today := a.dayStart(now)
+	days := make(map[time.Time][]*models.SensorPoint)
+	for _, p := range points {
+		day := a.dayStart(p.Time)
+		if !day.Before(today) {
+			continue
+		}
+
+		days[day] = append(days[day], p)
+	}
+
+	errs := make([]string, 0)
+	archived := 0
+	for day, dayPoints := range days {
+		added, err := a.dbContext.MergeArchivedDay(day, dayPoints)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+
+		if added > 0 {
+			archived++
+		}
+	}
+
+	if len(errs) > 0 {
+		return archived, errors.New(strings.Join(errs, ", "))
+	}
+
+	return archived, nil
+}
+
+func (a *ArchiveService) dayStart(t time.Time) time.Time {
+	local := t.In(a.cfg.Location)
+
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, a.cfg.Location)
+}
@@ -0,0 +1,215 @@
+package archive
+
+import (
+	"goairmon/business/data/context"
+	"goairmon/business/data/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo"
+)
+
+func TestArchiveCompleteDays(t *testing.T) {
+	zone := time.FixedZone("test-zone", -5*60*60)
+	start := time.Date(2010, 1, 1, 0, 0, 0, 0, zone)
+
+	points := make([]*models.SensorPoint, 0)
+	for i := 0; i < 24*60*2+30; i++ {
+		points = append(points, &models.SensorPoint{
+			Time:     start.Add(time.Minute * time.Duration(i)).In(time.UTC),
+			Co2Value: float64(i),
+		})
+	}
+
+	store := &_fakeArchiveStore{days: make(map[time.Time][]*models.SensorPoint)}
+	ctx := &_fakeDbContext{points: points, store: store}
+	service := NewArchiveService(&Config{Location: zone, Logger: echo.New().Logger}, ctx)
+
+	now := start.Add(48*time.Hour + 30*time.Minute)
+	archived, err := service.ArchiveCompleteDays(now)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if archived != 2 {
+		t.Error("unexpected archived day count", 2, archived)
+	}
+
+	for _, day := range []time.Time{start, start.Add(24 * time.Hour)} {
+		dayPoints := store.days[day]
+		if len(dayPoints) != 24*60 {
+			t.Error("unexpected point count", day, 24*60, len(dayPoints))
+		}
+
+		for _, p := range dayPoints {
+			if p.Time.In(zone).Day() != day.Day() {
+				t.Error("point outside of day boundary", day, p.Time.In(zone))
+			}
+		}
+	}
+
+	if _, ok := store.days[start.Add(48*time.Hour)]; ok {
+		t.Error("incomplete day should not be archived")
+	}
+
+	archived, err = service.ArchiveCompleteDays(now)
+	if err != nil || archived != 0 {
+		t.Error("expected days to only be written once", archived, err)
+	}
+}
+
+func TestArchiveRollover(t *testing.T) {
+	start := time.Date(2010, 1, 1, 23, 0, 0, 0, time.UTC)
+	ctx := &_fakeDbContext{points: []*models.SensorPoint{
+		{Time: start, Co2Value: 1},
+		{Time: start.Add(59 * time.Minute), Co2Value: 2},
+	}}
+	store := &_fakeArchiveStore{days: make(map[time.Time][]*models.SensorPoint)}
+	ctx.store = store
+	service := NewArchiveService(&Config{Location: time.UTC, Logger: echo.New().Logger}, ctx)
+
+	now := start.Add(30 * time.Minute)
+	service.now = func() time.Time {
+		return now
+	}
+
+	if err := service.Start(); err != nil {
+		t.Error(err)
+	}
+
+	if err := service.Start(); err == nil {
+		t.Error("expected error")
+	}
+
+	if err := service.Stop(); err != nil {
+		t.Error(err)
+	}
+
+	if len(store.days) != 0 {
+		t.Error("expected no archives before rollover", len(store.days))
+	}
+
+	if err := service.checkRollover(); err != nil || len(store.days) != 0 {
+		t.Error("expected no archives before rollover", len(store.days), err)
+	}
+
+	now = start.Add(time.Hour + time.Minute)
+	service.stopChan = make(chan int)
+	ticker := time.NewTicker(time.Hour)
+	tickChan := make(chan time.Time)
+	ticker.C = tickChan
+	go service.archiveRoutine(ticker)
+
+	tickChan <- now
+	service.stopChan <- 0
+
+	points := store.days[time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)]
+	if len(points) != 2 {
+		t.Error("unexpected archived point count", 2, len(points))
+	}
+}
+
+type _fakeArchiveStore struct {
+	days map[time.Time][]*models.SensorPoint
+}
+
+func (f *_fakeArchiveStore) ArchivedDays() ([]time.Time, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeArchiveStore) LoadDay(day time.Time) ([]*models.SensorPoint, error) {
+	return f.days[day], nil
+}
+
+func (f *_fakeArchiveStore) MergeDay(day time.Time, points []*models.SensorPoint) (int, error) {
+	merged, added := context.MergeSensorPoints(f.days[day], points)
+	f.days[day] = merged
+
+	return added, nil
+}
+
+type _fakeDbContext struct {
+	points []*models.SensorPoint
+	store  *_fakeArchiveStore
+}
+
+func (f *_fakeDbContext) Close() error {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) CreateOrUpdateUser(user *models.User) error {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) FindUser(id uuid.UUID) (*models.User, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) FindUserByName(username string) (*models.User, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) GetUsers() ([]*models.User, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) DeleteUser(id uuid.UUID) error {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) CreateInvite(invite *models.Invite) error {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) FindInvite(id uuid.UUID) (*models.Invite, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) GetInvites() ([]*models.Invite, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) DeleteInvite(id uuid.UUID) error {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) PushSensorPoint(point *models.SensorPoint) error {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) MergeSensorPoints(points []*models.SensorPoint) (int, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) MergeArchivedDay(day time.Time, points []*models.SensorPoint) (int, error) {
+	return f.store.MergeDay(day, points)
+}
+
+func (f *_fakeDbContext) Archives() context.ArchiveStore {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) GetSensorPoints(count int) ([]*models.SensorPoint, error) {
+	return f.points, nil
+}
+
+func (f *_fakeDbContext) GetSensorBaseline() (eCO2 uint16, TVOC uint16, err error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) SetSensorBaseline(eCO2 uint16, TVOC uint16) error {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) Save() error {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) ClearSensorPoints() error {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) Snapshot(fn func() error) error {
+	panic("not implemented")
+}
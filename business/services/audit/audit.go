@@ -0,0 +1,211 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"goairmon/business/services/applog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	EventLogin         = "login"
+	EventLoginFailed   = "login_failed"
+	EventLogout        = "logout"
+	EventUserAdded     = "user_added"
+	EventUserChanged   = "user_changed"
+	EventUserRemoved   = "user_removed"
+	EventInvite        = "invite"
+	EventPointsCleared = "points_cleared"
+	EventBackup        = "backup"
+	EventBackupFailed  = "backup_failed"
+	EventRestore       = "restore"
+)
+
+var Events = []string{
+	EventLogin,
+	EventLoginFailed,
+	EventLogout,
+	EventUserAdded,
+	EventUserChanged,
+	EventUserRemoved,
+	EventInvite,
+	EventPointsCleared,
+	EventBackup,
+	EventBackupFailed,
+	EventRestore,
+}
+
+func NewAuditLog(cfg *Config) *AuditLog {
+	return &AuditLog{
+		cfg: cfg,
+		now: time.Now,
+	}
+}
+
+type Config struct {
+	File string
+	// The log is rotated to File.1, File.2... once it would grow past MaxBytes
+	MaxBytes int64
+	MaxFiles int
+}
+
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	Actor   string    `json:"actor"`
+	IP      string    `json:"ip,omitempty"`
+	Details string    `json:"details,omitempty"`
+}
+
+// Filter matches entries with all of its non-empty fields, newest first up to Limit.
+type Filter struct {
+	Event string
+	Actor string
+	Text  string
+	Limit int
+}
+
+func (f *Filter) Matches(entry *Entry) bool {
+	if f.Event != "" && entry.Event != f.Event {
+		return false
+	}
+
+	if f.Actor != "" && !strings.EqualFold(entry.Actor, f.Actor) {
+		return false
+	}
+
+	if f.Text != "" {
+		text := strings.ToLower(f.Text)
+		if !strings.Contains(strings.ToLower(entry.Details), text) && !strings.Contains(entry.IP, text) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Appends security and configuration events as JSON lines, rotating the file by size.
+type AuditLog struct {
+	cfg  *Config
+	file *applog.RotatingFile
+	lock sync.Mutex
+	now  func() time.Time
+}
+
+// Record appends the entry, filling in the time if it's empty. A nil log records nothing.
+func (a *AuditLog) Record(entry Entry) error {
+	if a == nil {
+		return nil
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if entry.Time.IsZero() {
+		entry.Time = a.now()
+	}
+
+	line, err := json.Marshal(&entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %s", err)
+	}
+	line = append(line, '\n')
+
+	if a.file == nil {
+		if a.file, err = applog.NewRotatingFile(a.cfg.File, a.cfg.MaxBytes, a.cfg.MaxFiles); err != nil {
+			return fmt.Errorf("failed to open audit log: %s", err)
+		}
+	}
+
+	if _, err := a.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write audit log: %s", err)
+	}
+
+	return nil
+}
+
+// Close closes the file, the next Record reopens it.
+func (a *AuditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.file == nil {
+		return nil
+	}
+
+	err := a.file.Close()
+	a.file = nil
+
+	return err
+}
+
+// Query reads the current and rotated files, returning matching entries newest first.
+func (a *AuditLog) Query(filter Filter) ([]*Entry, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	entries := []*Entry{}
+	for _, path := range a.files() {
+		fileEntries, err := readEntries(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := len(fileEntries) - 1; i >= 0; i-- {
+			if !filter.Matches(fileEntries[i]) {
+				continue
+			}
+
+			entries = append(entries, fileEntries[i])
+			if filter.Limit > 0 && len(entries) >= filter.Limit {
+				return entries, nil
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// Newest first.
+func (a *AuditLog) files() []string {
+	paths := []string{a.cfg.File}
+	for i := 1; i <= a.cfg.MaxFiles; i++ {
+		paths = append(paths, applog.RotatedName(a.cfg.File, i))
+	}
+
+	return paths
+}
+
+// Skips lines that can't be read, like one cut short by a crash.
+func readEntries(path string) ([]*Entry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %s", err)
+	}
+	defer file.Close()
+
+	entries := []*Entry{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := &Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %s", err)
+	}
+
+	return entries, nil
+}
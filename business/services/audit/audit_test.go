@@ -0,0 +1,129 @@
+package audit
+
+import (
+	"goairmon/business/services/applog"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func _auditSetup(t *testing.T, maxBytes int64, maxFiles int) (*AuditLog, string) {
+	dir, err := ioutil.TempDir("", "goairmon_audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log := NewAuditLog(&Config{File: filepath.Join(dir, "audit.log"), MaxBytes: maxBytes, MaxFiles: maxFiles})
+	now := time.Date(2019, 10, 1, 3, 0, 0, 0, time.UTC)
+	log.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	return log, dir
+}
+
+func TestRecordAndQuery(t *testing.T) {
+	log, dir := _auditSetup(t, 0, 0)
+	defer os.RemoveAll(dir)
+
+	entries := []Entry{
+		{Event: EventLogin, Actor: "admin-user", IP: "10.0.0.1"},
+		{Event: EventLoginFailed, Actor: "someone", IP: "10.0.0.2", Details: "invalid username or password"},
+		{Event: EventUserAdded, Actor: "admin-user", IP: "10.0.0.1", Details: "Added user new-user"},
+		{Event: EventPointsCleared, Actor: "operator", IP: "10.0.0.3"},
+	}
+
+	for _, entry := range entries {
+		if err := log.Record(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows := []struct {
+		filter Filter
+		events []string
+	}{
+		{Filter{}, []string{EventPointsCleared, EventUserAdded, EventLoginFailed, EventLogin}},
+		{Filter{Limit: 2}, []string{EventPointsCleared, EventUserAdded}},
+		{Filter{Actor: "ADMIN-USER"}, []string{EventUserAdded, EventLogin}},
+		{Filter{Event: EventLoginFailed}, []string{EventLoginFailed}},
+		{Filter{Text: "new-user"}, []string{EventUserAdded}},
+		{Filter{Text: "10.0.0.3"}, []string{EventPointsCleared}},
+		{Filter{Event: EventBackup}, []string{}},
+	}
+
+	for _, row := range rows {
+		found, err := log.Query(row.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(found) != len(row.events) {
+			t.Error("unexpected entries", row.filter, found)
+			continue
+		}
+
+		for i, event := range row.events {
+			if found[i].Event != event || found[i].Time.IsZero() {
+				t.Error("unexpected entry", row.filter, i, found[i])
+			}
+		}
+	}
+
+	var nilLog *AuditLog
+	if err := nilLog.Record(entries[0]); err != nil {
+		t.Error("expected nil log to record nothing", err)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	log, dir := _auditSetup(t, 300, 2)
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 20; i++ {
+		if err := log.Record(Entry{Event: EventLogin, Actor: "admin-user", Details: "some details to pad the line"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, path := range log.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal("expected rotated file", path, err)
+		}
+
+		if info.Size() > 300 {
+			t.Error("expected file to be rotated before growing past max", path, info.Size())
+		}
+	}
+
+	if _, err := os.Stat(applog.RotatedName(log.cfg.File, 3)); !os.IsNotExist(err) {
+		t.Error("expected only MaxFiles rotated files to be kept")
+	}
+
+	found, err := log.Query(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(found) == 0 || len(found) >= 20 {
+		t.Fatal("expected oldest entries to be rotated away", len(found))
+	}
+
+	for i := 1; i < len(found); i++ {
+		if !found[i].Time.Before(found[i-1].Time) {
+			t.Error("expected entries newest first", found[i-1].Time, found[i].Time)
+		}
+	}
+
+	if err := ioutil.WriteFile(log.cfg.File, []byte("{\"event\":\"login\"}\nnot json\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if found, err := log.Query(Filter{Limit: 1}); err != nil || len(found) != 1 || found[0].Event != EventLogin {
+		t.Error("expected unreadable lines to be skipped", found, err)
+	}
+}
@@ -0,0 +1,328 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	Version      = 1
+	ManifestName = "manifest.json"
+)
+
+var storageFilePattern = regexp.MustCompile(`^(goairmon_config\.json|goairmon_points\.json|archive_\d{4}_\d{2}_\d{2}\.json)$`)
+
+type Manifest struct {
+	Version   int            `json:"version"`
+	CreatedAt time.Time      `json:"created_at"`
+	Files     []ManifestFile `json:"files"`
+}
+
+type ManifestFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// StorageFiles returns the names of the files in storagePath that belong in a backup.
+func StorageFiles(storagePath string) ([]string, error) {
+	infos, err := ioutil.ReadDir(storagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage: %s", err)
+	}
+
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		if info.Mode().IsRegular() && storageFilePattern.MatchString(info.Name()) {
+			names = append(names, info.Name())
+		}
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Create writes a gzipped tarball of the storage files to w, with the manifest as the first entry.
+func Create(w io.Writer, storagePath string) (*Manifest, error) {
+	names, err := StorageFiles(storagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{
+		Version:   Version,
+		CreatedAt: time.Now().UTC(),
+		Files:     make([]ManifestFile, 0, len(names)),
+	}
+
+	for _, name := range names {
+		file, err := hashFile(filepath.Join(storagePath, name))
+		if err != nil {
+			return nil, err
+		}
+
+		file.Name = name
+		manifest.Files = append(manifest.Files, *file)
+	}
+
+	encodedManifest, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %s", err)
+	}
+
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	if err := writeEntry(tarWriter, ManifestName, int64(len(encodedManifest)), manifest.CreatedAt, func(out io.Writer) error {
+		_, err := out.Write(encodedManifest)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	for _, file := range manifest.Files {
+		if err := writeEntry(tarWriter, file.Name, file.Size, manifest.CreatedAt, func(out io.Writer) error {
+			return copyFile(out, filepath.Join(storagePath, file.Name), file.Size)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close backup: %s", err)
+	}
+
+	if err := gzipWriter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close backup: %s", err)
+	}
+
+	return manifest, nil
+}
+
+// Restore validates the backup in r and replaces the storage files with its contents.
+// Nothing in storagePath is touched unless every file matches the manifest. Replaced files
+// are moved to a pre_restore directory in storagePath, and moved back if the restore fails
+// part way, so storage is left as it was.
+func Restore(r io.Reader, storagePath string, force bool) (*Manifest, error) {
+	os.MkdirAll(storagePath, 0700)
+	stagingPath, err := ioutil.TempDir(storagePath, "restore_")
+	if err != nil {
+		return nil, fmt.Errorf("failed to make staging directory: %s", err)
+	}
+	defer os.RemoveAll(stagingPath)
+
+	manifest, err := extract(r, stagingPath, force)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := StorageFiles(storagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	preRestorePath := filepath.Join(storagePath, "pre_restore_"+time.Now().Format("2006_01_02_150405"))
+	if len(existing) > 0 {
+		if err := os.MkdirAll(preRestorePath, 0700); err != nil {
+			return nil, fmt.Errorf("failed to make pre-restore directory: %s", err)
+		}
+	}
+
+	moved := make([]string, 0, len(existing))
+	for _, name := range existing {
+		if err := os.Rename(filepath.Join(storagePath, name), filepath.Join(preRestorePath, name)); err != nil {
+			return nil, rollback(fmt.Errorf("failed to move %s aside: %s", name, err), storagePath, preRestorePath, moved, nil)
+		}
+		moved = append(moved, name)
+	}
+
+	restored := make([]string, 0, len(manifest.Files))
+	for _, file := range manifest.Files {
+		if err := os.Rename(filepath.Join(stagingPath, file.Name), filepath.Join(storagePath, file.Name)); err != nil {
+			return nil, rollback(fmt.Errorf("failed to restore %s: %s", file.Name, err), storagePath, preRestorePath, moved, restored)
+		}
+		restored = append(restored, file.Name)
+	}
+
+	return manifest, nil
+}
+
+// Removes the files restored so far and moves the replaced ones back, noting anything
+// that couldn't be undone in the error.
+func rollback(err error, storagePath string, preRestorePath string, moved []string, restored []string) error {
+	failed := make([]string, 0)
+	for _, name := range restored {
+		if removeErr := os.Remove(filepath.Join(storagePath, name)); removeErr != nil {
+			failed = append(failed, removeErr.Error())
+		}
+	}
+
+	for _, name := range moved {
+		if renameErr := os.Rename(filepath.Join(preRestorePath, name), filepath.Join(storagePath, name)); renameErr != nil {
+			failed = append(failed, renameErr.Error())
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%s, and failed to roll back, the old files are in %s: %s", err, preRestorePath, strings.Join(failed, ", "))
+	}
+
+	os.Remove(preRestorePath)
+
+	return fmt.Errorf("%s, storage was left as it was", err)
+}
+
+// Validate checks the backup in r against its manifest without restoring anything.
+func Validate(r io.Reader, force bool) (*Manifest, error) {
+	stagingPath, err := ioutil.TempDir("", "goairmon_validate_")
+	if err != nil {
+		return nil, fmt.Errorf("failed to make staging directory: %s", err)
+	}
+	defer os.RemoveAll(stagingPath)
+
+	return extract(r, stagingPath, force)
+}
+
+func extract(r io.Reader, stagingPath string, force bool) (*Manifest, error) {
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup: %s", err)
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+
+	header, err := tarReader.Next()
+	if err != nil || header.Name != ManifestName {
+		return nil, fmt.Errorf("backup is missing %s", ManifestName)
+	}
+
+	manifest := &Manifest{}
+	if err := json.NewDecoder(tarReader).Decode(manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %s", err)
+	}
+
+	if manifest.Version != Version && !force {
+		return nil, fmt.Errorf("backup version %d does not match supported version %d", manifest.Version, Version)
+	}
+
+	expected := make(map[string]ManifestFile, len(manifest.Files))
+	for _, file := range manifest.Files {
+		if !storageFilePattern.MatchString(file.Name) {
+			return nil, fmt.Errorf("unexpected file %q in manifest", file.Name)
+		}
+
+		expected[file.Name] = file
+	}
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read backup: %s", err)
+		}
+
+		file, ok := expected[header.Name]
+		if !ok {
+			return nil, fmt.Errorf("unexpected file %q in backup", header.Name)
+		}
+
+		if err := extractEntry(tarReader, filepath.Join(stagingPath, file.Name), file); err != nil {
+			return nil, err
+		}
+
+		delete(expected, header.Name)
+	}
+
+	for name := range expected {
+		return nil, fmt.Errorf("backup is missing %s", name)
+	}
+
+	return manifest, nil
+}
+
+func extractEntry(r io.Reader, path string, file ManifestFile) error {
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %s", file.Name, err)
+	}
+	defer out.Close()
+
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(out, hash), r)
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %s", file.Name, err)
+	}
+
+	if written != file.Size || hex.EncodeToString(hash.Sum(nil)) != file.SHA256 {
+		return fmt.Errorf("checksum mismatch for %s", file.Name)
+	}
+
+	return nil
+}
+
+func writeEntry(w *tar.Writer, name string, size int64, modTime time.Time, writeBody func(io.Writer) error) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: modTime,
+	}
+
+	if err := w.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s: %s", name, err)
+	}
+
+	if err := writeBody(w); err != nil {
+		return fmt.Errorf("failed to write %s: %s", name, err)
+	}
+
+	return nil
+}
+
+func hashFile(path string) (*ManifestFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %s", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %s", path, err)
+	}
+
+	return &ManifestFile{
+		Size:   size,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// Copies exactly size bytes so a file changing after it was hashed can't corrupt the tar.
+func copyFile(w io.Writer, path string, size int64) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.CopyN(w, file, size)
+
+	return err
+}
@@ -0,0 +1,186 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func _setupStorage(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "goairmon_backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestCreateAndRestore(t *testing.T) {
+	source := _setupStorage(t, map[string]string{
+		"goairmon_config.json":    `{"eco2":1}`,
+		"goairmon_points.json":    `{"index":0}`,
+		"archive_2010_01_02.json": `[]`,
+		"unrelated.txt":           "skip me",
+	})
+	defer os.RemoveAll(source)
+
+	buffer := &bytes.Buffer{}
+	manifest, err := Create(buffer, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if manifest.Version != Version || len(manifest.Files) != 3 {
+		t.Error("unexpected manifest", manifest)
+	}
+
+	target := _setupStorage(t, map[string]string{
+		"goairmon_config.json":    `{"eco2":2}`,
+		"archive_2009_01_01.json": `[]`,
+	})
+	defer os.RemoveAll(target)
+
+	if _, err := Restore(bytes.NewReader(buffer.Bytes()), target, false); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, _ := ioutil.ReadFile(filepath.Join(target, "goairmon_config.json"))
+	if string(restored) != `{"eco2":1}` {
+		t.Error("unexpected restored config", string(restored))
+	}
+
+	files, _ := StorageFiles(target)
+	if len(files) != 3 {
+		t.Error("unexpected restored files", files)
+	}
+
+	preRestore, _ := filepath.Glob(filepath.Join(target, "pre_restore_*", "*"))
+	if len(preRestore) != 2 {
+		t.Error("expected replaced files to be kept", preRestore)
+	}
+}
+
+func TestRestoreRollsBack(t *testing.T) {
+	source := _setupStorage(t, map[string]string{
+		"goairmon_config.json":    `{"eco2":1}`,
+		"goairmon_points.json":    `{"index":0}`,
+		"archive_2010_01_02.json": `[]`,
+	})
+	defer os.RemoveAll(source)
+
+	buffer := &bytes.Buffer{}
+	if _, err := Create(buffer, source); err != nil {
+		t.Fatal(err)
+	}
+
+	target := _setupStorage(t, map[string]string{
+		"goairmon_config.json":    `{"eco2":2}`,
+		"archive_2009_01_01.json": `[]`,
+	})
+	defer os.RemoveAll(target)
+
+	// The points file is restored last and can't replace a directory
+	blocked := filepath.Join(target, "goairmon_points.json")
+	if err := os.MkdirAll(filepath.Join(blocked, "keep"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Restore(bytes.NewReader(buffer.Bytes()), target, false); err == nil {
+		t.Fatal("expected restore to fail")
+	}
+
+	config, _ := ioutil.ReadFile(filepath.Join(target, "goairmon_config.json"))
+	if string(config) != `{"eco2":2}` {
+		t.Error("expected the old config back", string(config))
+	}
+
+	files, _ := StorageFiles(target)
+	if len(files) != 2 || files[0] != "archive_2009_01_01.json" {
+		t.Error("expected only the old files", files)
+	}
+
+	if preRestore, _ := filepath.Glob(filepath.Join(target, "pre_restore_*")); len(preRestore) != 0 {
+		t.Error("expected the pre-restore directory to be removed", preRestore)
+	}
+}
+
+func TestRestoreRejectsInvalidBackups(t *testing.T) {
+	rows := []struct {
+		name     string
+		manifest *Manifest
+		files    map[string]string
+	}{
+		{
+			name:     "checksum mismatch",
+			manifest: &Manifest{Version: Version, Files: []ManifestFile{{Name: "goairmon_config.json", Size: 2, SHA256: "bad"}}},
+			files:    map[string]string{"goairmon_config.json": "{}"},
+		},
+		{
+			name:     "version mismatch",
+			manifest: &Manifest{Version: Version + 1},
+		},
+		{
+			name:     "missing file",
+			manifest: &Manifest{Version: Version, Files: []ManifestFile{{Name: "goairmon_points.json"}}},
+		},
+		{
+			name:     "unexpected file",
+			manifest: &Manifest{Version: Version},
+			files:    map[string]string{"../goairmon_config.json": "{}"},
+		},
+	}
+
+	for _, row := range rows {
+		target := _setupStorage(t, map[string]string{"goairmon_config.json": "original"})
+
+		if _, err := Restore(_buildBackup(t, row.manifest, row.files), target, false); err == nil {
+			t.Error("expected error", row.name)
+		}
+
+		original, _ := ioutil.ReadFile(filepath.Join(target, "goairmon_config.json"))
+		if string(original) != "original" {
+			t.Error("storage should be untouched", row.name)
+		}
+
+		os.RemoveAll(target)
+	}
+
+	if _, err := Validate(_buildBackup(t, &Manifest{Version: Version + 1}, nil), true); err != nil {
+		t.Error("expected force to allow version mismatch", err)
+	}
+}
+
+func _buildBackup(t *testing.T, manifest *Manifest, files map[string]string) *bytes.Buffer {
+	buffer := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	encoded, _ := json.Marshal(manifest)
+	entries := []struct{ name, body string }{{ManifestName, string(encoded)}}
+	for name, body := range files {
+		entries = append(entries, struct{ name, body string }{name, body})
+	}
+
+	for _, entry := range entries {
+		if err := tarWriter.WriteHeader(&tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.body))}); err != nil {
+			t.Fatal(err)
+		}
+		tarWriter.Write([]byte(entry.body))
+	}
+
+	tarWriter.Close()
+	gzipWriter.Close()
+
+	return buffer
+}
@@ -0,0 +1,118 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	fileNamePrefix = "goairmon_backup_"
+	fileNameLayout = "2006_01_02_150405"
+	fileNameSuffix = ".tar.gz"
+)
+
+// Retention keeps the newest KeepLast backups, plus the newest backup from each of the
+// last KeepDaily days and KeepWeekly weeks that have one. The newest backup is always kept.
+type Retention struct {
+	KeepLast   int
+	KeepDaily  int
+	KeepWeekly int
+}
+
+func FileName(t time.Time) string {
+	return fileNamePrefix + t.Format(fileNameLayout) + fileNameSuffix
+}
+
+type backupFile struct {
+	path    string
+	created time.Time
+}
+
+// ListBackups returns the paths of backups in dir, newest first.
+func ListBackups(dir string, loc *time.Location) ([]string, error) {
+	files, err := listBackupFiles(dir, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.path
+	}
+
+	return paths, nil
+}
+
+// Rotate removes backups in dir that fall outside of the retention and returns their paths.
+func Rotate(dir string, retention Retention, loc *time.Location) ([]string, error) {
+	files, err := listBackupFiles(dir, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := make(map[string]bool)
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+
+	for i, file := range files {
+		if i == 0 || i < retention.KeepLast {
+			keep[file.path] = true
+		}
+
+		day := file.created.Format("2006-01-02")
+		if !days[day] && len(days) < retention.KeepDaily {
+			days[day] = true
+			keep[file.path] = true
+		}
+
+		year, week := file.created.ISOWeek()
+		weekKey := fmt.Sprintf("%d-%d", year, week)
+		if !weeks[weekKey] && len(weeks) < retention.KeepWeekly {
+			weeks[weekKey] = true
+			keep[file.path] = true
+		}
+	}
+
+	removed := make([]string, 0)
+	for _, file := range files {
+		if keep[file.path] {
+			continue
+		}
+
+		if err := os.Remove(file.path); err != nil {
+			return removed, fmt.Errorf("failed to remove old backup: %s", err)
+		}
+
+		removed = append(removed, file.path)
+	}
+
+	return removed, nil
+}
+
+func listBackupFiles(dir string, loc *time.Location) ([]*backupFile, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, fileNamePrefix+"*"+fileNameSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*backupFile, 0, len(paths))
+	for _, path := range paths {
+		name := filepath.Base(path)
+		stamp := name[len(fileNamePrefix) : len(name)-len(fileNameSuffix)]
+		created, err := time.ParseInLocation(fileNameLayout, stamp, loc)
+		if err != nil {
+			continue
+		}
+
+		files = append(files, &backupFile{path: path, created: created})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].created.After(files[j].created)
+	})
+
+	return files, nil
+}
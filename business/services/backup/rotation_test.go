@@ -0,0 +1,78 @@
+package backup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goairmon_rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Two backups a day for 4 weeks, newest on Sunday 2019-10-27
+	start := time.Date(2019, 9, 30, 3, 0, 0, 0, time.UTC)
+	for day := 0; day < 28; day++ {
+		for _, hour := range []int{0, 12} {
+			created := start.AddDate(0, 0, day).Add(time.Duration(hour) * time.Hour)
+			ioutil.WriteFile(filepath.Join(dir, FileName(created)), []byte{}, 0600)
+		}
+	}
+	ioutil.WriteFile(filepath.Join(dir, "goairmon_backup_garbage.tar.gz"), []byte{}, 0600)
+
+	removed, err := Rotate(dir, Retention{KeepLast: 3, KeepDaily: 3, KeepWeekly: 3}, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(removed) != 56-6 {
+		t.Error("unexpected removed count", 56-6, len(removed))
+	}
+
+	remaining, _ := ListBackups(dir, time.UTC)
+	expected := []string{
+		FileName(time.Date(2019, 10, 27, 15, 0, 0, 0, time.UTC)),
+		FileName(time.Date(2019, 10, 27, 3, 0, 0, 0, time.UTC)),
+		FileName(time.Date(2019, 10, 26, 15, 0, 0, 0, time.UTC)),
+		FileName(time.Date(2019, 10, 25, 15, 0, 0, 0, time.UTC)),
+		FileName(time.Date(2019, 10, 20, 15, 0, 0, 0, time.UTC)),
+		FileName(time.Date(2019, 10, 13, 15, 0, 0, 0, time.UTC)),
+	}
+
+	if len(remaining) != len(expected) {
+		t.Fatal("unexpected remaining", remaining)
+	}
+
+	for i, path := range remaining {
+		if filepath.Base(path) != expected[i] {
+			t.Error("unexpected remaining backup", expected[i], filepath.Base(path))
+		}
+	}
+}
+
+func TestRotateKeepsNewest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goairmon_rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	newest := time.Date(2019, 10, 2, 3, 0, 0, 0, time.UTC)
+	ioutil.WriteFile(filepath.Join(dir, FileName(newest.AddDate(0, 0, -1))), []byte{}, 0600)
+	ioutil.WriteFile(filepath.Join(dir, FileName(newest)), []byte{}, 0600)
+
+	removed, err := Rotate(dir, Retention{}, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remaining, _ := ListBackups(dir, time.UTC)
+	if len(removed) != 1 || len(remaining) != 1 || filepath.Base(remaining[0]) != FileName(newest) {
+		t.Error("expected only the newest backup to be kept", removed, remaining)
+	}
+}
@@ -0,0 +1,58 @@
+package backup
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	start := time.Date(2019, 10, 1, 10, 15, 30, 0, time.UTC) // Tuesday
+
+	rows := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2019, 10, 1, 10, 16, 0, 0, time.UTC)},
+		{"*/20 * * * *", time.Date(2019, 10, 1, 10, 20, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2019, 10, 2, 3, 0, 0, 0, time.UTC)},
+		{"30 2 * * 0", time.Date(2019, 10, 6, 2, 30, 0, 0, time.UTC)},
+		{"30 2 * * 7", time.Date(2019, 10, 6, 2, 30, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 15 * 1-2", time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"5,10 9-11 * * *", time.Date(2019, 10, 1, 11, 5, 0, 0, time.UTC)},
+	}
+
+	for _, row := range rows {
+		schedule, err := ParseSchedule(row.spec)
+		if err != nil {
+			t.Error(row.spec, err)
+			continue
+		}
+
+		if next := schedule.Next(start); !next.Equal(row.expected) {
+			t.Error("unexpected next run", row.spec, row.expected, next)
+		}
+	}
+}
+
+func TestParseInvalidSchedule(t *testing.T) {
+	specs := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"0 0 30 2 *",
+		"0 0 31 4,6,9,11 *",
+	}
+
+	for _, spec := range specs {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Error("expected error", spec)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package backup
+
+import (
+	"fmt"
+	"goairmon/business/data/context"
+	"goairmon/business/services/audit"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+func NewBackupService(cfg *Config, dbContext context.DbContext) *BackupService {
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
+
+	return &BackupService{
+		cfg:       cfg,
+		dbContext: dbContext,
+		now:       time.Now,
+	}
+}
+
+type Config struct {
+	Dir         string
+	StoragePath string
+	Schedule    *Schedule
+	Retention   Retention
+	Location    *time.Location
+	Logger      echo.Logger
+	// Scheduled backups are recorded here, manual ones by whoever ran them
+	Audit *audit.AuditLog
+}
+
+type Status struct {
+	Enabled     bool
+	Dir         string
+	NextRun     time.Time
+	LastAttempt time.Time
+	LastSuccess time.Time
+	LastFile    string
+	LastError   string
+	Backups     []string
+}
+
+// Snapshots storage into Config.Dir on a schedule, rotating old backups.
+type BackupService struct {
+	cfg       *Config
+	dbContext context.DbContext
+	stopChan  chan int
+	status    Status
+	lock      sync.Mutex
+	runLock   sync.Mutex
+	now       func() time.Time
+}
+
+func (b *BackupService) Start() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.stopChan != nil {
+		return fmt.Errorf("service already started")
+	}
+
+	if b.cfg.Dir == "" || b.cfg.Schedule == nil {
+		return fmt.Errorf("backups are not configured")
+	}
+
+	if absDir, _ := filepath.Abs(b.cfg.Dir); absDir != "" {
+		if absStorage, _ := filepath.Abs(b.cfg.StoragePath); absDir == absStorage {
+			return fmt.Errorf("backup directory must not be the storage directory")
+		}
+	}
+
+	b.status.NextRun = b.cfg.Schedule.Next(b.now().In(b.cfg.Location))
+	if b.status.NextRun.IsZero() {
+		return fmt.Errorf("backup schedule never runs")
+	}
+
+	b.stopChan = make(chan int)
+
+	go b.scheduleRoutine(b.stopChan)
+
+	return nil
+}
+
+func (b *BackupService) Stop() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.stopChan == nil {
+		return fmt.Errorf("service already stopped")
+	}
+
+	close(b.stopChan)
+	b.stopChan = nil
+	b.status.NextRun = time.Time{}
+
+	return nil
+}
+
+func (b *BackupService) Status() Status {
+	b.lock.Lock()
+	status := b.status
+	status.Enabled = b.stopChan != nil
+	b.lock.Unlock()
+
+	status.Dir = b.cfg.Dir
+	if b.cfg.Dir != "" {
+		status.Backups, _ = ListBackups(b.cfg.Dir, b.cfg.Location)
+	}
+
+	return status
+}
+
+// RunNow takes a backup and rotates old ones, returning the new backup's path.
+func (b *BackupService) RunNow() (string, error) {
+	b.runLock.Lock()
+	defer b.runLock.Unlock()
+
+	if b.cfg.Dir == "" {
+		return "", fmt.Errorf("backups are not configured")
+	}
+
+	now := b.now().In(b.cfg.Location)
+	path, err := b.createBackup(now)
+
+	b.lock.Lock()
+	b.status.LastAttempt = now
+	if err != nil {
+		b.status.LastError = err.Error()
+	} else {
+		b.status.LastError = ""
+		b.status.LastSuccess = now
+		b.status.LastFile = path
+	}
+	b.lock.Unlock()
+
+	if err != nil {
+		return "", err
+	}
+
+	removed, err := Rotate(b.cfg.Dir, b.cfg.Retention, b.cfg.Location)
+	for _, old := range removed {
+		b.cfg.Logger.Info("removed old backup", old)
+	}
+
+	return path, err
+}
+
+func (b *BackupService) createBackup(now time.Time) (string, error) {
+	if err := os.MkdirAll(b.cfg.Dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to make backup directory: %s", err)
+	}
+
+	path := filepath.Join(b.cfg.Dir, FileName(now))
+	tempPath := path + ".partial"
+
+	out, err := os.OpenFile(tempPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create backup file: %s", err)
+	}
+
+	err = b.dbContext.Snapshot(func() error {
+		_, err := Create(out, b.cfg.StoragePath)
+		return err
+	})
+
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tempPath, path)
+	}
+
+	if err != nil {
+		os.Remove(tempPath)
+		return "", fmt.Errorf("failed to create backup: %s", err)
+	}
+
+	return path, nil
+}
+
+func (b *BackupService) scheduleRoutine(stopChan chan int) {
+	for {
+		b.lock.Lock()
+		nextRun := b.status.NextRun
+		wait := nextRun.Sub(b.now())
+		b.lock.Unlock()
+
+		if nextRun.IsZero() {
+			b.cfg.Logger.Error("stopping scheduled backups, the schedule never runs again")
+			return
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-stopChan:
+			timer.Stop()
+			return
+		case <-timer.C:
+			entry := audit.Entry{Event: audit.EventBackup, Actor: "schedule"}
+			if path, err := b.RunNow(); err != nil {
+				b.cfg.Logger.Error("scheduled backup failed", err)
+				entry.Event, entry.Details = audit.EventBackupFailed, err.Error()
+			} else {
+				b.cfg.Logger.Info("scheduled backup saved to", path)
+				entry.Details = path
+			}
+
+			if err := b.cfg.Audit.Record(entry); err != nil {
+				b.cfg.Logger.Error(err)
+			}
+
+			b.lock.Lock()
+			b.status.NextRun = b.cfg.Schedule.Next(b.now().In(b.cfg.Location))
+			b.lock.Unlock()
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package backup
+
+import (
+	"goairmon/business/data/context"
+	"goairmon/business/data/models"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+func TestRunNow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goairmon_backup_service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	storagePath := filepath.Join(dir, "storage")
+	dbContext := context.NewMemDbContext(&context.MemDbConfig{StoragePath: storagePath, SensorPointCount: 10})
+	dbContext.PushSensorPoint(&models.SensorPoint{Time: time.Now(), Co2Value: 400})
+
+	schedule, _ := ParseSchedule("0 3 * * *")
+	service := NewBackupService(&Config{
+		Dir:         filepath.Join(dir, "backups"),
+		StoragePath: storagePath,
+		Schedule:    schedule,
+		Retention:   Retention{KeepLast: 1},
+		Location:    time.UTC,
+		Logger:      echo.New().Logger,
+	}, dbContext)
+
+	now := time.Date(2019, 10, 1, 3, 0, 0, 0, time.UTC)
+	service.now = func() time.Time {
+		return now
+	}
+
+	if _, err := service.RunNow(); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(time.Hour)
+	path, err := service.RunNow()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	manifest, err := Validate(file, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(manifest.Files) != 2 {
+		t.Error("expected snapshot of config and points", manifest.Files)
+	}
+
+	status := service.Status()
+	if !status.LastSuccess.Equal(now) || status.LastFile != path || status.LastError != "" {
+		t.Error("unexpected status", status)
+	}
+
+	if len(status.Backups) != 1 {
+		t.Error("expected old backups to be rotated", status.Backups)
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	schedule, _ := ParseSchedule("0 3 * * *")
+	service := NewBackupService(&Config{StoragePath: "storage", Schedule: schedule, Logger: echo.New().Logger}, nil)
+
+	if err := service.Start(); err == nil {
+		t.Error("expected error without backup dir")
+	}
+
+	service.cfg.Dir = "./storage/"
+	if err := service.Start(); err == nil {
+		t.Error("expected error when backing up into storage")
+	}
+
+	service.cfg.Dir = "backups"
+	if err := service.Start(); err != nil {
+		t.Error(err)
+	}
+
+	if err := service.Start(); err == nil {
+		t.Error("expected error")
+	}
+
+	if status := service.Status(); !status.Enabled || status.NextRun.IsZero() {
+		t.Error("unexpected status", status)
+	}
+
+	if err := service.Stop(); err != nil {
+		t.Error(err)
+	}
+
+	if err := service.Stop(); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestScheduleNeverRuns(t *testing.T) {
+	// Feb 30th, which ParseSchedule refuses
+	schedule := &Schedule{
+		minutes:  map[int]bool{0: true},
+		hours:    map[int]bool{0: true},
+		days:     map[int]bool{30: true},
+		months:   map[int]bool{2: true},
+		weekdays: map[int]bool{0: true, 1: true, 2: true, 3: true, 4: true, 5: true, 6: true},
+		anyWeek:  true,
+	}
+	service := NewBackupService(&Config{Dir: "backups", StoragePath: "storage", Schedule: schedule, Logger: echo.New().Logger}, nil)
+
+	if err := service.Start(); err == nil {
+		t.Error("expected a schedule that never runs to fail")
+	}
+
+	done := make(chan int)
+	go func() {
+		service.scheduleRoutine(make(chan int))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the schedule routine to stop")
+	}
+
+	if status := service.Status(); !status.LastAttempt.IsZero() {
+		t.Error("expected no backups to run", status)
+	}
+}
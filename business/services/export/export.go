@@ -0,0 +1,342 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"goairmon/business/data/context"
+	"goairmon/business/data/models"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+func NewExporter(cfg *Config, dbContext context.DbContext) *Exporter {
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
+
+	return &Exporter{
+		cfg:       cfg,
+		dbContext: dbContext,
+		store:     dbContext.Archives(),
+	}
+}
+
+type Config struct {
+	Location *time.Location
+}
+
+type Query struct {
+	From        time.Time
+	To          time.Time
+	Interval    time.Duration
+	Format      string
+	IncludeTVOC bool
+}
+
+func (q *Query) Validate() error {
+	if q.Format != FormatCSV && q.Format != FormatNDJSON {
+		return fmt.Errorf("unsupported format %q", q.Format)
+	}
+
+	if q.Interval < 0 || q.Interval > 24*time.Hour {
+		return fmt.Errorf("interval must be between 0 and 24h")
+	}
+
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return fmt.Errorf("from must be before to")
+	}
+
+	return nil
+}
+
+// Streams points from the archives and the DbContext one day at a time so a
+// long range never has to be held in memory.
+type Exporter struct {
+	cfg       *Config
+	dbContext context.DbContext
+	store     context.ArchiveStore
+}
+
+func (e *Exporter) ContentType(format string) string {
+	if format == FormatNDJSON {
+		return "application/x-ndjson"
+	}
+
+	return "text/csv"
+}
+
+// Export writes the points in [From, To) to w. From is no earlier than the oldest
+// stored point and To no later than now, zero values mean those bounds. If w has a Flush method, it is called after each day.
+func (e *Exporter) Export(w io.Writer, query *Query) error {
+	if err := query.Validate(); err != nil {
+		return err
+	}
+
+	recent, err := e.dbContext.GetSensorPoints(0)
+	if err != nil {
+		return err
+	}
+
+	recentDays := make(map[time.Time][]*models.SensorPoint)
+	for _, p := range recent {
+		day := e.dayStart(p.Time)
+		recentDays[day] = append(recentDays[day], p)
+	}
+
+	from, to, err := e.resolveRange(query, recent)
+	if err != nil {
+		return err
+	}
+
+	rows := newRowWriter(w, query)
+	if err := rows.writeHeader(); err != nil {
+		return err
+	}
+
+	for day := e.dayStart(from); day.Before(to); day = day.AddDate(0, 0, 1) {
+		archived, err := e.store.LoadDay(day)
+		if err != nil {
+			return err
+		}
+
+		points, _ := context.MergeSensorPoints(archived, recentDays[day])
+		points = filterRange(points, from, to)
+		if query.Interval > 0 {
+			points = aggregate(points, day, query.Interval)
+		}
+
+		for _, p := range points {
+			if err := rows.writePoint(p, e.cfg.Location); err != nil {
+				return err
+			}
+		}
+
+		if err := rows.flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Clamps the range to the stored points, so a wide or open range only walks days that can have data.
+func (e *Exporter) resolveRange(query *Query, recent []*models.SensorPoint) (from time.Time, to time.Time, err error) {
+	now := time.Now()
+	from = query.From
+	to = query.To
+
+	if to.IsZero() || to.After(now) {
+		to = now
+	}
+
+	oldest := to
+	for _, p := range recent {
+		if p.Time.Before(oldest) {
+			oldest = p.Time
+		}
+	}
+
+	days, err := e.store.ArchivedDays()
+	if err != nil {
+		return from, to, err
+	}
+
+	if len(days) > 0 {
+		first := time.Date(days[0].Year(), days[0].Month(), days[0].Day(), 0, 0, 0, 0, e.cfg.Location)
+		if first.Before(oldest) {
+			oldest = first
+		}
+	}
+
+	if from.Before(oldest) {
+		from = oldest
+	}
+
+	return from, to, nil
+}
+
+func (e *Exporter) dayStart(t time.Time) time.Time {
+	local := t.In(e.cfg.Location)
+
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, e.cfg.Location)
+}
+
+// ParseTime accepts RFC3339 or a 2006-01-02 date in the given location.
+func ParseTime(value string, loc *time.Location) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+
+	parsed, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected RFC3339 or YYYY-MM-DD", value)
+	}
+
+	return parsed, nil
+}
+
+func filterRange(points []*models.SensorPoint, from time.Time, to time.Time) []*models.SensorPoint {
+	output := make([]*models.SensorPoint, 0, len(points))
+	for _, p := range points {
+		if p.Time.Before(from) || !p.Time.Before(to) {
+			continue
+		}
+
+		output = append(output, p)
+	}
+
+	return output
+}
+
+// Averages sorted points into buckets of interval counted from the start of the day.
+func aggregate(points []*models.SensorPoint, day time.Time, interval time.Duration) []*models.SensorPoint {
+	output := make([]*models.SensorPoint, 0)
+	var bucket *models.SensorPoint
+	count := 0.0
+
+	for _, p := range points {
+		bucketTime := day.Add(p.Time.Sub(day) / interval * interval)
+		if bucket == nil || !bucket.Time.Equal(bucketTime) {
+			if bucket != nil {
+				output = append(output, meanPoint(bucket, count))
+			}
+
+			bucket = &models.SensorPoint{Time: bucketTime}
+			count = 0
+		}
+
+		bucket.Co2Value += p.Co2Value
+		bucket.TVOCValue += p.TVOCValue
+		count++
+	}
+
+	if bucket != nil {
+		output = append(output, meanPoint(bucket, count))
+	}
+
+	return output
+}
+
+func meanPoint(sum *models.SensorPoint, count float64) *models.SensorPoint {
+	sum.Co2Value /= count
+	sum.TVOCValue /= count
+
+	return sum
+}
+
+type rowWriter interface {
+	writeHeader() error
+	writePoint(p *models.SensorPoint, loc *time.Location) error
+	flush() error
+}
+
+func newRowWriter(w io.Writer, query *Query) rowWriter {
+	if query.Format == FormatNDJSON {
+		return &ndjsonRowWriter{
+			out:         w,
+			encoder:     json.NewEncoder(w),
+			includeTVOC: query.IncludeTVOC,
+		}
+	}
+
+	return &csvRowWriter{
+		out:         w,
+		writer:      csv.NewWriter(w),
+		includeTVOC: query.IncludeTVOC,
+	}
+}
+
+type csvRowWriter struct {
+	out         io.Writer
+	writer      *csv.Writer
+	includeTVOC bool
+}
+
+func (c *csvRowWriter) writeHeader() error {
+	header := []string{"time", "unix", "co2"}
+	if c.includeTVOC {
+		header = append(header, "tvoc")
+	}
+
+	return c.writer.Write(header)
+}
+
+func (c *csvRowWriter) writePoint(p *models.SensorPoint, loc *time.Location) error {
+	row := []string{
+		p.Time.In(loc).Format(time.RFC3339),
+		strconv.FormatInt(p.Time.Unix(), 10),
+		strconv.FormatFloat(p.Co2Value, 'f', 2, 64),
+	}
+
+	if c.includeTVOC {
+		row = append(row, strconv.FormatFloat(p.TVOCValue, 'f', 2, 64))
+	}
+
+	return c.writer.Write(row)
+}
+
+func (c *csvRowWriter) flush() error {
+	c.writer.Flush()
+	if err := c.writer.Error(); err != nil {
+		return err
+	}
+
+	flushWriter(c.out)
+
+	return nil
+}
+
+type ndjsonRowWriter struct {
+	out         io.Writer
+	encoder     *json.Encoder
+	includeTVOC bool
+}
+
+type ndjsonRow struct {
+	Time string   `json:"time"`
+	Unix int64    `json:"unix"`
+	Co2  float64  `json:"co2"`
+	TVOC *float64 `json:"tvoc,omitempty"`
+}
+
+func (n *ndjsonRowWriter) writeHeader() error {
+	return nil
+}
+
+func (n *ndjsonRowWriter) writePoint(p *models.SensorPoint, loc *time.Location) error {
+	row := ndjsonRow{
+		Time: p.Time.In(loc).Format(time.RFC3339),
+		Unix: p.Time.Unix(),
+		Co2:  p.Co2Value,
+	}
+
+	if n.includeTVOC {
+		tvoc := p.TVOCValue
+		row.TVOC = &tvoc
+	}
+
+	return n.encoder.Encode(row)
+}
+
+func (n *ndjsonRowWriter) flush() error {
+	flushWriter(n.out)
+
+	return nil
+}
+
+func flushWriter(w io.Writer) {
+	if flusher, ok := w.(interface{ Flush() }); ok {
+		flusher.Flush()
+	}
+}
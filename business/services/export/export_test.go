@@ -0,0 +1,255 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"goairmon/business/data/context"
+	"goairmon/business/data/models"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func _setupExporter(t *testing.T, recent []*models.SensorPoint) (*Exporter, context.ArchiveStore, func()) {
+	dir, err := ioutil.TempDir("", "goairmon_export")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := context.NewFileArchiveStore(dir)
+	exporter := NewExporter(&Config{Location: time.UTC}, &_fakeDbContext{points: recent, store: store})
+
+	return exporter, store, func() { os.RemoveAll(dir) }
+}
+
+func TestExportCSVAcrossArchiveAndRecent(t *testing.T) {
+	day := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	exporter, store, cleanup := _setupExporter(t, []*models.SensorPoint{
+		{Time: day.Add(24*time.Hour + time.Minute), Co2Value: 3, TVOCValue: 30},
+		{Time: day.Add(24 * time.Hour), Co2Value: 2, TVOCValue: 20},
+	})
+	defer cleanup()
+
+	if _, err := store.MergeDay(day, []*models.SensorPoint{
+		{Time: day.Add(-time.Minute), Co2Value: 99},
+		{Time: day.Add(23 * time.Hour), Co2Value: 1, TVOCValue: 10},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := &bytes.Buffer{}
+	err := exporter.Export(buffer, &Query{
+		From:        day,
+		To:          day.Add(48 * time.Hour),
+		Format:      FormatCSV,
+		IncludeTVOC: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "time,unix,co2,tvoc\n" +
+		"2010-01-01T23:00:00Z,1262386800,1.00,10.00\n" +
+		"2010-01-02T00:00:00Z,1262390400,2.00,20.00\n" +
+		"2010-01-02T00:01:00Z,1262390460,3.00,30.00\n"
+
+	if buffer.String() != expected {
+		t.Errorf("unexpected output:\n%s", buffer.String())
+	}
+}
+
+func TestExportNDJSONAggregated(t *testing.T) {
+	day := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	recent := make([]*models.SensorPoint, 0)
+	for i := 0; i < 120; i++ {
+		recent = append(recent, &models.SensorPoint{Time: day.Add(time.Minute * time.Duration(i)), Co2Value: float64(i)})
+	}
+
+	exporter, _, cleanup := _setupExporter(t, recent)
+	defer cleanup()
+
+	buffer := &bytes.Buffer{}
+	err := exporter.Export(buffer, &Query{
+		From:     day,
+		To:       day.Add(24 * time.Hour),
+		Interval: time.Hour,
+		Format:   FormatNDJSON,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatal("unexpected line count", 2, len(lines))
+	}
+
+	row := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines[1]), &row); err != nil {
+		t.Fatal(err)
+	}
+
+	if row["co2"] != 89.5 || row["time"] != "2010-01-01T01:00:00Z" {
+		t.Error("unexpected row", row)
+	}
+
+	if _, ok := row["tvoc"]; ok {
+		t.Error("tvoc should be omitted")
+	}
+}
+
+func TestExportClampsRange(t *testing.T) {
+	day := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	exporter, store, cleanup := _setupExporter(t, []*models.SensorPoint{
+		{Time: day.Add(24 * time.Hour), Co2Value: 2},
+	})
+	defer cleanup()
+
+	if _, err := store.MergeDay(day, []*models.SensorPoint{{Time: day.Add(time.Hour), Co2Value: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	from, to, err := exporter.resolveRange(&Query{From: time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC), To: time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !from.Equal(day) || to.After(time.Now()) {
+		t.Error("expected range clamped to the oldest archive and now", from, to)
+	}
+
+	from, _, _ = exporter.resolveRange(&Query{}, exporter.dbContext.(*_fakeDbContext).points)
+	if !from.Equal(day) {
+		t.Error("expected open range to start at the oldest archive", from)
+	}
+}
+
+func TestQueryValidate(t *testing.T) {
+	now := time.Now()
+	rows := []struct {
+		query *Query
+		valid bool
+	}{
+		{&Query{Format: FormatCSV}, true},
+		{&Query{Format: "xml"}, false},
+		{&Query{Format: FormatNDJSON, Interval: 48 * time.Hour}, false},
+		{&Query{Format: FormatCSV, From: now, To: now.Add(-time.Hour)}, false},
+	}
+
+	for _, row := range rows {
+		if err := row.query.Validate(); (err == nil) != row.valid {
+			t.Error("unexpected validation result", row.query, err)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	zone := time.FixedZone("test-zone", -8*60*60)
+
+	parsed, err := ParseTime("2010-01-02", zone)
+	if err != nil || !parsed.Equal(time.Date(2010, 1, 2, 0, 0, 0, 0, zone)) {
+		t.Error("unexpected date parse", parsed, err)
+	}
+
+	parsed, err = ParseTime("2010-01-02T03:04:05Z", zone)
+	if err != nil || !parsed.Equal(time.Date(2010, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Error("unexpected RFC3339 parse", parsed, err)
+	}
+
+	if parsed, err = ParseTime("", zone); err != nil || !parsed.IsZero() {
+		t.Error("expected zero time", parsed, err)
+	}
+
+	if _, err := ParseTime("yesterday", zone); err == nil {
+		t.Error("expected error")
+	}
+}
+
+type _fakeDbContext struct {
+	points []*models.SensorPoint
+	store  context.ArchiveStore
+}
+
+func (f *_fakeDbContext) Close() error {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) CreateOrUpdateUser(user *models.User) error {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) FindUser(id uuid.UUID) (*models.User, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) FindUserByName(username string) (*models.User, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) GetUsers() ([]*models.User, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) DeleteUser(id uuid.UUID) error {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) CreateInvite(invite *models.Invite) error {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) FindInvite(id uuid.UUID) (*models.Invite, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) GetInvites() ([]*models.Invite, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) DeleteInvite(id uuid.UUID) error {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) PushSensorPoint(point *models.SensorPoint) error {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) MergeSensorPoints(points []*models.SensorPoint) (int, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) MergeArchivedDay(day time.Time, points []*models.SensorPoint) (int, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) Archives() context.ArchiveStore {
+	return f.store
+}
+
+func (f *_fakeDbContext) GetSensorPoints(count int) ([]*models.SensorPoint, error) {
+	return f.points, nil
+}
+
+func (f *_fakeDbContext) GetSensorBaseline() (eCO2 uint16, TVOC uint16, err error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) SetSensorBaseline(eCO2 uint16, TVOC uint16) error {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) Save() error {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) ClearSensorPoints() error {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) Snapshot(fn func() error) error {
+	panic("not implemented")
+}
@@ -0,0 +1,175 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"goairmon/business/data/models"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const FormatArchive = "archive"
+
+// FormatFromPath guesses the format of an import file from its extension.
+func FormatFromPath(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON, nil
+	case ".json":
+		return FormatArchive, nil
+	}
+
+	return "", fmt.Errorf("unable to detect format of %s", path)
+}
+
+// ReadPoints parses points written by Export, or an archive file.
+func ReadPoints(r io.Reader, format string) ([]*models.SensorPoint, error) {
+	switch format {
+	case FormatCSV:
+		return readCSV(r)
+	case FormatNDJSON:
+		return readNDJSON(r)
+	case FormatArchive:
+		points := make([]*models.SensorPoint, 0)
+		if err := json.NewDecoder(r).Decode(&points); err != nil {
+			return nil, fmt.Errorf("failed to decode archive: %s", err)
+		}
+
+		return points, nil
+	}
+
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+func readCSV(r io.Reader) ([]*models.SensorPoint, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %s", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	if _, ok := columns["co2"]; !ok {
+		return nil, fmt.Errorf("csv is missing a co2 column")
+	}
+
+	points := make([]*models.SensorPoint, 0)
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv line %d: %s", line, err)
+		}
+
+		value := func(name string) string {
+			if idx, ok := columns[name]; ok && idx < len(row) {
+				return strings.TrimSpace(row[idx])
+			}
+
+			return ""
+		}
+
+		point, err := parseRow(value("unix"), value("time"), value("co2"), value("tvoc"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv line %d: %s", line, err)
+		}
+
+		points = append(points, point)
+	}
+
+	return points, nil
+}
+
+func readNDJSON(r io.Reader) ([]*models.SensorPoint, error) {
+	points := make([]*models.SensorPoint, 0)
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" {
+			continue
+		}
+
+		row := struct {
+			Time string   `json:"time"`
+			Unix *int64   `json:"unix"`
+			Co2  *float64 `json:"co2"`
+			TVOC float64  `json:"tvoc"`
+		}{}
+
+		if err := json.Unmarshal([]byte(raw), &row); err != nil {
+			return nil, fmt.Errorf("invalid ndjson line %d: %s", line, err)
+		}
+
+		if row.Co2 == nil {
+			return nil, fmt.Errorf("invalid ndjson line %d: missing co2", line)
+		}
+
+		unix := ""
+		if row.Unix != nil {
+			unix = strconv.FormatInt(*row.Unix, 10)
+		}
+
+		point, err := parseRow(unix, row.Time, "0", "0")
+		if err != nil {
+			return nil, fmt.Errorf("invalid ndjson line %d: %s", line, err)
+		}
+
+		point.Co2Value = *row.Co2
+		point.TVOCValue = row.TVOC
+		points = append(points, point)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return points, nil
+}
+
+// Prefers the unix column, falling back to an RFC3339 time.
+func parseRow(unix string, timeValue string, co2 string, tvoc string) (*models.SensorPoint, error) {
+	point := &models.SensorPoint{}
+
+	if unix != "" {
+		stamp, err := strconv.ParseInt(unix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unix time %q", unix)
+		}
+
+		point.Time = time.Unix(stamp, 0).In(time.UTC)
+	} else {
+		parsed, err := time.Parse(time.RFC3339, timeValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time %q", timeValue)
+		}
+
+		point.Time = parsed.In(time.UTC)
+	}
+
+	var err error
+	if point.Co2Value, err = strconv.ParseFloat(co2, 64); err != nil {
+		return nil, fmt.Errorf("invalid co2 value %q", co2)
+	}
+
+	if tvoc != "" {
+		if point.TVOCValue, err = strconv.ParseFloat(tvoc, 64); err != nil {
+			return nil, fmt.Errorf("invalid tvoc value %q", tvoc)
+		}
+	}
+
+	return point, nil
+}
@@ -0,0 +1,90 @@
+package export
+
+import (
+	"bytes"
+	"goairmon/business/data/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadPointsRoundTrip(t *testing.T) {
+	day := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	recent := []*models.SensorPoint{
+		{Time: day.Add(time.Minute), Co2Value: 450.5, TVOCValue: 12},
+		{Time: day, Co2Value: 400, TVOCValue: 10},
+	}
+
+	for _, format := range []string{FormatCSV, FormatNDJSON} {
+		exporter, _, cleanup := _setupExporter(t, recent)
+
+		buffer := &bytes.Buffer{}
+		if err := exporter.Export(buffer, &Query{From: day, To: day.Add(time.Hour), Format: format, IncludeTVOC: true}); err != nil {
+			t.Fatal(err)
+		}
+		cleanup()
+
+		points, err := ReadPoints(buffer, format)
+		if err != nil {
+			t.Fatal(format, err)
+		}
+
+		if len(points) != 2 {
+			t.Fatal("unexpected point count", format, 2, len(points))
+		}
+
+		if !points[1].Time.Equal(recent[0].Time) || points[1].Co2Value != 450.5 || points[1].TVOCValue != 12 {
+			t.Error("unexpected point", format, points[1])
+		}
+	}
+}
+
+func TestReadPointsArchive(t *testing.T) {
+	points, err := ReadPoints(strings.NewReader(`[{"t":1262304000,"v":400},{"t":1262304060,"v":401,"tv":3}]`), FormatArchive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(points) != 2 || points[1].TVOCValue != 3 || points[1].Time.Unix() != 1262304060 {
+		t.Error("unexpected points", points)
+	}
+}
+
+func TestReadPointsInvalid(t *testing.T) {
+	rows := []struct {
+		input  string
+		format string
+	}{
+		{"time,unix\n", FormatCSV},
+		{"unix,co2\nabc,400\n", FormatCSV},
+		{"time,co2\n2010-01-01,400\n", FormatCSV},
+		{`{"unix":1262304000}`, FormatNDJSON},
+		{`garbage`, FormatNDJSON},
+		{`garbage`, FormatArchive},
+		{``, "xml"},
+	}
+
+	for _, row := range rows {
+		if _, err := ReadPoints(strings.NewReader(row.input), row.format); err == nil {
+			t.Error("expected error", row.format, row.input)
+		}
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	rows := map[string]string{
+		"readings.CSV":              FormatCSV,
+		"readings.ndjson":           FormatNDJSON,
+		"storage/archive_2010.json": FormatArchive,
+	}
+
+	for path, expected := range rows {
+		if format, err := FormatFromPath(path); err != nil || format != expected {
+			t.Error("unexpected format", path, expected, format, err)
+		}
+	}
+
+	if _, err := FormatFromPath("readings.xls"); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,144 @@
+package health
+
+import (
+	"fmt"
+	"goairmon/business/hardware"
+	"goairmon/business/services/poll"
+	"time"
+)
+
+// Readings older than this many read delays mark the sensor unhealthy.
+const sensorStaleReads = 10
+
+type PollStatus interface {
+	Status() poll.Status
+}
+
+func NewHealthService(pollStatus PollStatus) *HealthService {
+	return &HealthService{
+		poll: pollStatus,
+		now:  time.Now,
+	}
+}
+
+type Check struct {
+	Name    string     `json:"name"`
+	OK      bool       `json:"ok"`
+	Message string     `json:"message,omitempty"`
+	Last    *time.Time `json:"last,omitempty"`
+}
+
+type Report struct {
+	OK     bool      `json:"ok"`
+	Time   time.Time `json:"time"`
+	Checks []Check   `json:"checks"`
+}
+
+// Checks the web server, sensor poll loop, sensor and storage are working.
+type HealthService struct {
+	poll PollStatus
+	now  func() time.Time
+}
+
+// Liveness fails when the process is wedged and should be restarted, i.e. the poll loop is running
+// but has stalled. A poll that never started or a broken sensor won't be fixed by restarting.
+func (h *HealthService) Liveness() Report {
+	return h.report(h.httpCheck(), h.stallCheck(h.poll.Status()))
+}
+
+// Readiness fails when the poll loop has stalled or the sensor or storage aren't working.
+func (h *HealthService) Readiness() Report {
+	status := h.poll.Status()
+
+	return h.report(h.httpCheck(), h.pollCheck(status), h.sensorCheck(status.Sensor), h.storageCheck(status))
+}
+
+func (h *HealthService) report(checks ...Check) Report {
+	report := Report{OK: true, Time: h.now(), Checks: checks}
+	for _, check := range checks {
+		report.OK = report.OK && check.OK
+	}
+
+	return report
+}
+
+// The web server is up if it's answering the check.
+func (h *HealthService) httpCheck() Check {
+	return Check{Name: "http", OK: true}
+}
+
+func (h *HealthService) pollCheck(status poll.Status) Check {
+	check := Check{Name: "poll", Last: timePtr(status.LastPoll)}
+	if !status.Running {
+		check.Message = "sensor poll is not running"
+		return check
+	}
+
+	check.OK, check.Message = h.fresh(status.LastPoll, status.StartedAt, 2*status.Interval, "poll")
+
+	return check
+}
+
+// Like pollCheck, but a poll that isn't running passes.
+func (h *HealthService) stallCheck(status poll.Status) Check {
+	if !status.Running {
+		return Check{Name: "poll", OK: true, Message: "sensor poll is not running"}
+	}
+
+	return h.pollCheck(status)
+}
+
+func (h *HealthService) sensorCheck(status hardware.SensorStatus) Check {
+	check := Check{Name: "sensor", Last: timePtr(status.LastRead)}
+	if !status.Running {
+		check.Message = "sensor is not running"
+		if status.LastError != "" {
+			check.Message += ": " + status.LastError
+		}
+		return check
+	}
+
+	check.OK, check.Message = h.fresh(status.LastRead, status.StartedAt, sensorStaleReads*status.ReadDelay, "reading")
+	if !check.OK && status.LastError != "" {
+		check.Message += ": " + status.LastError
+	}
+
+	return check
+}
+
+func (h *HealthService) storageCheck(status poll.Status) Check {
+	check := Check{Name: "storage", Last: timePtr(status.LastSave)}
+	check.OK, check.Message = h.fresh(status.LastSave, status.StartedAt, 2*status.Interval, "save")
+	if !check.OK && status.LastError != "" {
+		check.Message += ": " + status.LastError
+	}
+
+	return check
+}
+
+// Whether last happened within maxAge, allowing maxAge from started for the first one.
+func (h *HealthService) fresh(last time.Time, started time.Time, maxAge time.Duration, what string) (bool, string) {
+	since := last
+	if since.IsZero() {
+		since = started
+	}
+
+	age := h.now().Sub(since)
+	if age <= maxAge {
+		return true, ""
+	}
+
+	if last.IsZero() {
+		return false, fmt.Sprintf("no %s since starting %s ago", what, age.Round(time.Second))
+	}
+
+	return false, fmt.Sprintf("last %s was %s ago", what, age.Round(time.Second))
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
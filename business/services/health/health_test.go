@@ -0,0 +1,118 @@
+package health
+
+import (
+	"goairmon/business/hardware"
+	"goairmon/business/services/poll"
+	"strings"
+	"testing"
+	"time"
+)
+
+type _fakePoll struct {
+	status poll.Status
+}
+
+func (f *_fakePoll) Status() poll.Status {
+	return f.status
+}
+
+func _healthSetup() (*HealthService, *_fakePoll, time.Time) {
+	now := time.Date(2019, 10, 1, 3, 0, 0, 0, time.UTC)
+	fake := &_fakePoll{status: poll.Status{
+		Running:   true,
+		Interval:  time.Minute,
+		StartedAt: now.Add(-time.Hour),
+		LastPoll:  now.Add(-30 * time.Second),
+		LastSave:  now.Add(-30 * time.Second),
+		Sensor: hardware.SensorStatus{
+			Running:   true,
+			StartedAt: now.Add(-time.Hour),
+			LastRead:  now.Add(-time.Second),
+			ReadDelay: time.Second,
+		},
+	}}
+
+	service := NewHealthService(fake)
+	service.now = func() time.Time {
+		return now
+	}
+
+	return service, fake, now
+}
+
+func _check(report Report, name string) Check {
+	for _, check := range report.Checks {
+		if check.Name == name {
+			return check
+		}
+	}
+
+	return Check{}
+}
+
+func TestHealthy(t *testing.T) {
+	service, _, _ := _healthSetup()
+
+	if report := service.Liveness(); !report.OK || len(report.Checks) != 2 {
+		t.Error("expected live", report)
+	}
+
+	if report := service.Readiness(); !report.OK || len(report.Checks) != 4 {
+		t.Error("expected ready", report)
+	}
+}
+
+func TestStalledPoll(t *testing.T) {
+	service, fake, now := _healthSetup()
+	fake.status.LastPoll = now.Add(-3 * time.Minute)
+
+	report := service.Readiness()
+	if check := _check(report, "poll"); report.OK || check.OK || !strings.Contains(check.Message, "3m0s ago") {
+		t.Error("expected stalled poll to fail readiness", report)
+	}
+
+	if check := _check(service.Liveness(), "poll"); check.OK || !strings.Contains(check.Message, "3m0s ago") {
+		t.Error("expected stalled poll to fail liveness", check)
+	}
+
+	fake.status.LastPoll = time.Time{}
+	fake.status.StartedAt = now.Add(-time.Minute)
+	if check := _check(service.Readiness(), "poll"); !check.OK {
+		t.Error("expected a first poll to be waited for", check)
+	}
+
+	fake.status.Running = false
+	if check := _check(service.Readiness(), "poll"); check.OK {
+		t.Error("expected stopped poll to fail", check)
+	}
+
+	if report := service.Liveness(); !report.OK {
+		t.Error("expected a poll that isn't running not to fail liveness", report)
+	}
+}
+
+func TestSensorAndStorage(t *testing.T) {
+	service, fake, now := _healthSetup()
+	fake.status.Sensor.LastRead = now.Add(-time.Minute)
+	fake.status.Sensor.LastError = "remote I/O error"
+	fake.status.LastSave = now.Add(-5 * time.Minute)
+	fake.status.LastError = "disk full"
+
+	if report := service.Liveness(); !report.OK {
+		t.Error("expected sensor and storage not to affect liveness", report)
+	}
+
+	report := service.Readiness()
+	if check := _check(report, "sensor"); report.OK || check.OK || !strings.Contains(check.Message, "remote I/O error") {
+		t.Error("expected failing sensor", check)
+	}
+
+	if check := _check(report, "storage"); check.OK || !strings.Contains(check.Message, "disk full") {
+		t.Error("expected failing storage", check)
+	}
+
+	fake.status.Sensor.Running = false
+	if check := _check(service.Readiness(), "sensor"); check.OK || check.Message != "sensor is not running: remote I/O error" {
+		t.Error("expected stopped sensor", check)
+	}
+}
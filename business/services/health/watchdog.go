@@ -0,0 +1,157 @@
+package health
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+type WatchdogConfig struct {
+	// systemd's notify socket and watchdog timeout, from NOTIFY_SOCKET and WATCHDOG_USEC
+	Socket  string
+	Timeout time.Duration
+	Logger  echo.Logger
+	// Checks the web server is answering, the watchdog isn't pinged while it fails
+	Probe func() error
+}
+
+// EnvWatchdogConfig reads the notify socket and watchdog timeout systemd passes to the service.
+func EnvWatchdogConfig(logger echo.Logger) *WatchdogConfig {
+	cfg := &WatchdogConfig{
+		Socket: os.Getenv("NOTIFY_SOCKET"),
+		Logger: logger,
+	}
+
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return cfg
+	}
+
+	if usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64); err == nil && usec > 0 {
+		cfg.Timeout = time.Duration(usec) * time.Microsecond
+	}
+
+	return cfg
+}
+
+func NewWatchdog(cfg *WatchdogConfig, health *HealthService) *Watchdog {
+	return &Watchdog{
+		cfg:    cfg,
+		health: health,
+	}
+}
+
+// Tells systemd when the service is ready and keeps its watchdog fed while the liveness checks pass
+// and the web server answers.
+type Watchdog struct {
+	cfg      *WatchdogConfig
+	health   *HealthService
+	stopChan chan int
+	lock     sync.Mutex
+}
+
+// Enabled is true when systemd is waiting to be notified.
+func (w *Watchdog) Enabled() bool {
+	return w.cfg.Socket != ""
+}
+
+func (w *Watchdog) Start() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.stopChan != nil {
+		return fmt.Errorf("service already started")
+	}
+
+	if w.cfg.Socket == "" {
+		return fmt.Errorf("not started by systemd with a notify socket")
+	}
+
+	if err := w.notify("READY=1"); err != nil {
+		return err
+	}
+
+	w.stopChan = make(chan int)
+	if w.cfg.Timeout > 0 {
+		go w.watchdogRoutine(w.stopChan)
+	}
+
+	return nil
+}
+
+func (w *Watchdog) Stop() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.stopChan == nil {
+		return fmt.Errorf("service already stopped")
+	}
+
+	close(w.stopChan)
+	w.stopChan = nil
+
+	return w.notify("STOPPING=1")
+}
+
+func (w *Watchdog) watchdogRoutine(stopChan chan int) {
+	ticker := time.NewTicker(w.cfg.Timeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-ticker.C:
+			if report := w.health.Liveness(); !report.OK {
+				w.cfg.Logger.Error("skipping watchdog, liveness check failed", failedChecks(report))
+				continue
+			}
+
+			if w.cfg.Probe != nil {
+				if err := w.cfg.Probe(); err != nil {
+					w.cfg.Logger.Error("skipping watchdog, web server isn't answering", err)
+					continue
+				}
+			}
+
+			if err := w.notify("WATCHDOG=1"); err != nil {
+				w.cfg.Logger.Error("failed to notify watchdog", err)
+			}
+		}
+	}
+}
+
+func (w *Watchdog) notify(state string) error {
+	socket := w.cfg.Socket
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to connect to notify socket: %s", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("failed to notify systemd: %s", err)
+	}
+
+	return nil
+}
+
+func failedChecks(report Report) string {
+	failed := make([]string, 0)
+	for _, check := range report.Checks {
+		if !check.OK {
+			failed = append(failed, check.Name+": "+check.Message)
+		}
+	}
+
+	return strings.Join(failed, ", ")
+}
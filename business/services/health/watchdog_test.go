@@ -0,0 +1,120 @@
+package health
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+func _notifySocket(t *testing.T) (*net.UnixConn, string, string) {
+	dir, err := ioutil.TempDir("", "goairmon_health")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return conn, path, dir
+}
+
+func _readState(t *testing.T, conn *net.UnixConn) string {
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		return ""
+	}
+
+	return string(buf[:n])
+}
+
+func _readUntil(t *testing.T, conn *net.UnixConn, expected string) {
+	for state := _readState(t, conn); state != expected; state = _readState(t, conn) {
+		if state == "" {
+			t.Fatal("expected", expected)
+		}
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	conn, path, dir := _notifySocket(t)
+	defer os.RemoveAll(dir)
+	defer conn.Close()
+
+	service, fake, now := _healthSetup()
+	probeErr := make(chan error, 1)
+	probeErr <- nil
+	cfg := &WatchdogConfig{Socket: path, Timeout: 20 * time.Millisecond, Logger: echo.New().Logger, Probe: func() error {
+		err := <-probeErr
+		probeErr <- err
+		return err
+	}}
+	watchdog := NewWatchdog(cfg, service)
+
+	if err := watchdog.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if state := _readState(t, conn); state != "READY=1" {
+		t.Error("expected ready", state)
+	}
+
+	if state := _readState(t, conn); state != "WATCHDOG=1" {
+		t.Error("expected watchdog ping", state)
+	}
+
+	if err := watchdog.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	_readUntil(t, conn, "STOPPING=1")
+
+	fake.status.LastPoll = now.Add(-time.Hour)
+	if err := watchdog.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	_readState(t, conn)
+	conn.SetReadDeadline(time.Now().Add(60 * time.Millisecond))
+	if _, err := conn.Read(make([]byte, 64)); err == nil {
+		t.Error("expected no watchdog ping while the poll is stalled")
+	}
+
+	if err := watchdog.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	_readUntil(t, conn, "STOPPING=1")
+
+	fake.status.LastPoll = now
+	<-probeErr
+	probeErr <- fmt.Errorf("connection refused")
+	if err := watchdog.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer watchdog.Stop()
+
+	_readState(t, conn)
+	conn.SetReadDeadline(time.Now().Add(60 * time.Millisecond))
+	if _, err := conn.Read(make([]byte, 64)); err == nil {
+		t.Error("expected no watchdog ping while the web server isn't answering")
+	}
+}
+
+func TestWatchdogWithoutSystemd(t *testing.T) {
+	service, _, _ := _healthSetup()
+	watchdog := NewWatchdog(&WatchdogConfig{Logger: echo.New().Logger}, service)
+
+	if err := watchdog.Start(); err == nil {
+		t.Error("expected no notify socket to fail")
+	}
+}
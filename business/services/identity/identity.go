@@ -2,9 +2,15 @@ package identity
 
 import (
 	"fmt"
+	"goairmon/business/data/context"
+	"goairmon/business/data/models"
+	"goairmon/business/services/applog"
 	"goairmon/business/services/session"
 	"goairmon/site/helper"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/sessions"
@@ -12,21 +18,41 @@ import (
 )
 
 const (
-	CookiesValueSessionKey = "session_id"
-	CtxCookieSession       = helper.CtxCookieSession
-	CtxServerSession       = helper.CtxServerSession
+	CookiesValueSessionKey     = "session_id"
+	CookiesValuePendingUserKey = "pending_user_id"
+	CookiesValuePendingTimeKey = "pending_user_time"
+	CtxCookieSession           = helper.CtxCookieSession
+	CtxServerSession           = helper.CtxServerSession
+	CtxCurrentUser             = helper.CtxCurrentUser
+	CtxDbContext               = helper.CtxDbContext
 )
 
+// How long a user has to enter their second factor after their password.
+const pendingLoginTimeout = 5 * time.Minute
+
 func NewIdentityService(cfg *IdentityConfig) *IdentityService {
 	if cfg == nil {
 		cfg = DefaultIdentityCfg()
 	}
 
 	cookieStore := sessions.NewCookieStore([]byte(cfg.CookieStoreEncryptionKey))
-	sessionStore := session.NewSessionStore(session.Config{
-		ExpirationSecs: 60 * 60 * 24 * 28,
-		GCDelaySeconds: 60 * 60,
-	})
+	cookieStore.Options.Path = cfg.CookiePath
+	if cookieStore.Options.Path == "" {
+		cookieStore.Options.Path = "/"
+	}
+	cookieStore.Options.Secure = cfg.CookieSecure
+	cookieStore.Options.HttpOnly = true
+	cookieStore.Options.SameSite = cfg.CookieSameSite
+
+	if cfg.Logger == nil {
+		cfg.Logger = applog.Default().Subsystem("auth")
+	}
+
+	sessionStore, err := OpenSessionStore(cfg)
+	if err != nil {
+		cfg.Logger.Error("failed to load sessions, starting without them:", err)
+	}
+	_ = sessionStore.StartGC()
 
 	return &IdentityService{
 		Cfg:          cfg,
@@ -35,6 +61,29 @@ func NewIdentityService(cfg *IdentityConfig) *IdentityService {
 	}
 }
 
+// OpenSessionStore loads the sessions saved in cfg.SessionFile, or keeps them in memory if it's empty.
+// The store is returned empty along with the error if the file can't be read.
+func OpenSessionStore(cfg *IdentityConfig) (*session.SessionStore, error) {
+	expiry := cfg.SessionExpiry
+	if expiry <= 0 {
+		expiry = 28 * 24 * time.Hour
+	}
+
+	gcDelay := cfg.SessionGCDelay
+	if gcDelay <= 0 {
+		gcDelay = time.Hour
+	}
+
+	sessionStore := session.NewSessionStore(session.Config{
+		ExpirationSecs: int(expiry / time.Second),
+		GCDelaySeconds: int(gcDelay / time.Second),
+		StorageFile:    cfg.SessionFile,
+		Logger:         cfg.Logger,
+	})
+
+	return sessionStore, sessionStore.Load()
+}
+
 func DefaultIdentityCfg() *IdentityConfig {
 	return &IdentityConfig{
 		CookieStoreKeySession:    "gowebapp_session",
@@ -45,6 +94,18 @@ func DefaultIdentityCfg() *IdentityConfig {
 type IdentityConfig struct {
 	CookieStoreKeySession    string
 	CookieStoreEncryptionKey string
+	// Routes wrapped in AllowPublic are open to these networks without logging in
+	PublicNetworks []*net.IPNet
+	// Server sessions are persisted here when set so restarts don't log everyone out
+	SessionFile string
+	// Unused sessions expire after SessionExpiry and are cleared every SessionGCDelay, default to 28 days and an hour
+	SessionExpiry  time.Duration
+	SessionGCDelay time.Duration
+	// Cookies are limited to the site's base path, and to HTTPS when secure
+	CookiePath     string
+	CookieSecure   bool
+	CookieSameSite http.SameSite
+	Logger         echo.Logger
 }
 
 type IdentityService struct {
@@ -79,6 +140,111 @@ func (i *IdentityService) RequireSession(onNoSession echo.HandlerFunc) echo.Midd
 	}
 }
 
+// RequireRole responds forbidden unless the logged in user has at least the role.
+func (i *IdentityService) RequireRole(role string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user, ok := c.Get(CtxCurrentUser).(*models.User)
+			if !ok || user == nil || !user.HasRole(role) {
+				return c.String(http.StatusForbidden, "You don't have permission to access this route")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// AllowPublic skips the middleware for requests from the public networks and applies it for everyone else.
+func (i *IdentityService) AllowPublic(middleware ...echo.MiddlewareFunc) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		guarded := next
+		for j := len(middleware) - 1; j >= 0; j-- {
+			guarded = middleware[j](guarded)
+		}
+
+		return func(c echo.Context) error {
+			if i.isPublic(c) {
+				return next(c)
+			}
+
+			return guarded(c)
+		}
+	}
+}
+
+func (i *IdentityService) isPublic(c echo.Context) bool {
+	ip := net.ParseIP(ClientIP(c))
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range i.Cfg.PublicNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientIP is the request's peer address, which the proxy middleware replaces with the client's
+// only for trusted proxies. Unlike echo's RealIP it never reads client supplied headers.
+func ClientIP(c echo.Context) string {
+	host, _, err := net.SplitHostPort(c.Request().RemoteAddr)
+	if err != nil {
+		return c.Request().RemoteAddr
+	}
+
+	return host
+}
+
+// ParseSameSite reads a cookie SameSite mode of lax, strict or none.
+func ParseSameSite(mode string) (http.SameSite, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	}
+
+	return 0, fmt.Errorf("invalid SameSite mode %q, expected lax, strict or none", mode)
+}
+
+// ParseNetworks reads a comma separated list of CIDR ranges or single IPs.
+func ParseNetworks(spec string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		if !strings.Contains(part, "/") {
+			ip := net.ParseIP(part)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %q", part)
+			}
+
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			part = fmt.Sprintf("%s/%d", part, bits)
+		}
+
+		_, network, err := net.ParseCIDR(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", part)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
 func (i *IdentityService) RedirectUsersWithoutSession(redirectPath string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -128,6 +294,41 @@ func (i *IdentityService) StartNewSession(c echo.Context) (*session.Session, err
 	return session, nil
 }
 
+// LoginUser ties the new session to the user so it can be listed and revoked.
+func (i *IdentityService) LoginUser(c echo.Context, sess *session.Session, userID string) error {
+	if err := i.sessionStore.SetUser(sess.Id, userID, ClientIP(c), c.Request().UserAgent()); err != nil {
+		return fmt.Errorf("failed to log in session: %s", err)
+	}
+
+	return nil
+}
+
+// UserSessions lists the user's active sessions, most recently seen first.
+func (i *IdentityService) UserSessions(userID string) []*session.Session {
+	return i.sessionStore.FindByUser(userID)
+}
+
+// RemoveUserSessions logs the user out everywhere except keepIds, returning how many sessions ended.
+func (i *IdentityService) RemoveUserSessions(userID string, keepIds ...string) int {
+	return i.sessionStore.RemoveUserSessions(userID, keepIds...)
+}
+
+// RemoveUserSession ends one of the user's sessions, failing if it belongs to someone else.
+func (i *IdentityService) RemoveUserSession(userID string, sessionID string) error {
+	for _, sess := range i.sessionStore.FindByUser(userID) {
+		if sess.Id == sessionID {
+			return i.sessionStore.Remove(sessionID)
+		}
+	}
+
+	return fmt.Errorf("session not found")
+}
+
+// SaveSessions writes any unsaved session changes, used when shutting down.
+func (i *IdentityService) SaveSessions() error {
+	return i.sessionStore.Save()
+}
+
 func (i *IdentityService) setSessionIdInCookies(c echo.Context, sessionID uuid.UUID) error {
 	cookieSession, err := i.cookieStore.Get(c.Request(), i.Cfg.CookieStoreKeySession)
 	if err != nil {
@@ -143,6 +344,27 @@ func (i *IdentityService) setSessionIdInCookies(c echo.Context, sessionID uuid.U
 	return nil
 }
 
+// SetSessionValue keeps a value for the current session on the server, where the client can't see
+// or change it.
+func (i *IdentityService) SetSessionValue(c echo.Context, key string, value string) error {
+	session, ok := c.Get(CtxServerSession).(*session.Session)
+	if !ok || session == nil {
+		return fmt.Errorf("not logged in")
+	}
+
+	return i.sessionStore.SetValue(session.Id, key, value)
+}
+
+// SessionValue returns a value set with SetSessionValue, or an empty string.
+func (i *IdentityService) SessionValue(c echo.Context, key string) string {
+	session, ok := c.Get(CtxServerSession).(*session.Session)
+	if !ok || session == nil {
+		return ""
+	}
+
+	return i.sessionStore.Value(session.Id, key)
+}
+
 func (i *IdentityService) EndSession(c echo.Context) error {
 	session, ok := c.Get(CtxServerSession).(*session.Session)
 	if !ok || session == nil {
@@ -165,6 +387,46 @@ func (i *IdentityService) EndSession(c echo.Context) error {
 	return nil
 }
 
+// StartPendingLogin remembers a user who gave the right password but still needs their second factor.
+func (i *IdentityService) StartPendingLogin(c echo.Context, userID string) error {
+	return i.setPendingLogin(c, userID, time.Now().Unix())
+}
+
+// PendingLogin returns the user waiting on their second factor, if it hasn't timed out.
+func (i *IdentityService) PendingLogin(c echo.Context) (string, bool) {
+	cookieSession, err := i.cookieStore.Get(c.Request(), i.Cfg.CookieStoreKeySession)
+	if err != nil {
+		return "", false
+	}
+
+	userID, ok := cookieSession.Values[CookiesValuePendingUserKey].(string)
+	started, _ := cookieSession.Values[CookiesValuePendingTimeKey].(int64)
+	if !ok || userID == "" || time.Since(time.Unix(started, 0)) > pendingLoginTimeout {
+		return "", false
+	}
+
+	return userID, true
+}
+
+func (i *IdentityService) ClearPendingLogin(c echo.Context) error {
+	return i.setPendingLogin(c, "", 0)
+}
+
+func (i *IdentityService) setPendingLogin(c echo.Context, userID string, started int64) error {
+	cookieSession, err := i.cookieStore.Get(c.Request(), i.Cfg.CookieStoreKeySession)
+	if err != nil {
+		return fmt.Errorf("failed to get session cookie: %s", err)
+	}
+
+	cookieSession.Values[CookiesValuePendingUserKey] = userID
+	cookieSession.Values[CookiesValuePendingTimeKey] = started
+	if err := cookieSession.Save(c.Request(), c.Response().Writer); err != nil {
+		return fmt.Errorf("failed to save session cookie: %s", err)
+	}
+
+	return nil
+}
+
 func (i *IdentityService) storeSessionsInContext(c echo.Context) error {
 	cookieSession, err := i.cookieStore.Get(c.Request(), i.Cfg.CookieStoreKeySession)
 	if err != nil {
@@ -181,8 +443,42 @@ func (i *IdentityService) storeSessionsInContext(c echo.Context) error {
 	sessionID, ok := cookieSession.Values[CookiesValueSessionKey].(string)
 	if ok {
 		sess, _ := i.sessionStore.Find(sessionID)
+		if sess != nil && !i.storeUserInContext(c, sess) {
+			_ = i.sessionStore.Remove(sess.Id)
+			sess = nil
+		}
+
+		if sess != nil {
+			i.sessionStore.SetClient(sess.Id, ClientIP(c), c.Request().UserAgent())
+		}
+
 		c.Set(CtxServerSession, sess)
 	}
 
 	return nil
 }
+
+// Looks up the session's user so roles and locks apply as soon as they change,
+// returning false if the user no longer exists and the session should end.
+func (i *IdentityService) storeUserInContext(c echo.Context, sess *session.Session) bool {
+	dbContext, ok := c.Get(CtxDbContext).(context.DbContext)
+	if !ok || dbContext == nil {
+		return true
+	}
+
+	userID, err := uuid.Parse(sess.UserId)
+	if err != nil {
+		return false
+	}
+
+	user, err := dbContext.FindUser(userID)
+	if err != nil {
+		return false
+	}
+
+	if !user.Locked {
+		c.Set(CtxCurrentUser, user)
+	}
+
+	return true
+}
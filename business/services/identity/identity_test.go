@@ -1,9 +1,14 @@
 package identity
 
 import (
+	"goairmon/business/data/context"
+	"goairmon/business/data/models"
 	"goairmon/business/services/session"
 	"goairmon/site/testhelpers"
+	"io/ioutil"
+	"os"
 	"testing"
+	"time"
 
 	"net/http"
 	"net/http/httptest"
@@ -233,3 +238,247 @@ type _fakeServiceProvider struct {
 func (p *_fakeServiceProvider) Register(key string, service interface{}) {
 	p.services[key] = service
 }
+
+func TestRequireRole(t *testing.T) {
+	service := NewIdentityService(nil)
+	ctx := &testhelpers.FakeContext{
+		Values:     make(map[string]interface{}),
+		FakeWriter: httptest.NewRecorder(),
+	}
+
+	nextHandler := func(ctx echo.Context) error {
+		return ctx.String(http.StatusOK, "here's the next response")
+	}
+
+	_ = service.RequireRole(models.RoleViewer)(nextHandler)(ctx)
+	if ctx.Response().Status != http.StatusForbidden {
+		t.Error("should be forbidden without a user")
+	}
+
+	ctx.Set(CtxCurrentUser, &models.User{Role: models.RoleOperator})
+
+	_ = service.RequireRole(models.RoleAdmin)(nextHandler)(ctx)
+	if ctx.Response().Status != http.StatusForbidden {
+		t.Error("should be forbidden for operator")
+	}
+
+	_ = service.RequireRole(models.RoleViewer)(nextHandler)(ctx)
+	if ctx.Response().Status != http.StatusOK {
+		t.Error("should be returning 200")
+	}
+}
+
+func TestStoreUserInContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goairmon_identity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dbContext := context.NewMemDbContext(&context.MemDbConfig{StoragePath: dir, SensorPointCount: 10})
+	user := &models.User{Username: "test-user", Role: models.RoleViewer}
+	dbContext.CreateOrUpdateUser(user)
+
+	service := NewIdentityService(nil)
+	ctx := &testhelpers.FakeContext{Values: map[string]interface{}{CtxDbContext: dbContext}}
+	sess := &session.Session{UserId: user.ID.String()}
+
+	if !service.storeUserInContext(ctx, sess) {
+		t.Error("expected session to be kept")
+	}
+	if current, ok := ctx.Get(CtxCurrentUser).(*models.User); !ok || current.ID != user.ID {
+		t.Error("expected user in context")
+	}
+
+	user.Locked = true
+	dbContext.CreateOrUpdateUser(user)
+	ctx.Values = map[string]interface{}{CtxDbContext: dbContext}
+
+	if !service.storeUserInContext(ctx, sess) {
+		t.Error("expected locked user's session to be kept")
+	}
+	if ctx.Get(CtxCurrentUser) != nil {
+		t.Error("expected locked user to be skipped")
+	}
+
+	dbContext.DeleteUser(user.ID)
+	if service.storeUserInContext(ctx, sess) {
+		t.Error("expected deleted user's session to end")
+	}
+
+	if service.storeUserInContext(ctx, &session.Session{}) {
+		t.Error("expected session without a user to end")
+	}
+}
+
+func TestUserSessions(t *testing.T) {
+	service := NewIdentityService(nil)
+	ctx := &testhelpers.FakeContext{
+		FakeWriter: httptest.NewRecorder(),
+		Values:     make(map[string]interface{}),
+	}
+
+	first, _ := service.sessionStore.NewOrExisting("first_id")
+	second, _ := service.sessionStore.NewOrExisting("second_id")
+	other, _ := service.sessionStore.NewOrExisting("other_id")
+	for _, sess := range []*session.Session{first, second} {
+		if err := service.LoginUser(ctx, sess, "user"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	service.LoginUser(ctx, other, "other_user")
+
+	if sessions := service.UserSessions("user"); len(sessions) != 2 {
+		t.Error("unexpected sessions", sessions)
+	}
+
+	if err := service.RemoveUserSession("user", "other_id"); err == nil {
+		t.Error("expected error removing someone else's session")
+	}
+
+	if err := service.RemoveUserSession("user", "second_id"); err != nil {
+		t.Error(err)
+	}
+
+	if removed := service.RemoveUserSessions("user", "first_id"); removed != 0 {
+		t.Error("expected current session to be kept", removed)
+	}
+
+	if removed := service.RemoveUserSessions("user"); removed != 1 {
+		t.Error("unexpected removed count", removed)
+	}
+
+	if sessions := service.UserSessions("other_user"); len(sessions) != 1 {
+		t.Error("expected other user's sessions to be kept", sessions)
+	}
+}
+
+func TestLoginUserRecordsPeerAddress(t *testing.T) {
+	service := NewIdentityService(nil)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.5:5123"
+	req.Header.Set(echo.HeaderXForwardedFor, "203.0.113.9")
+	ctx := echo.New().NewContext(req, httptest.NewRecorder())
+
+	sess, _ := service.sessionStore.NewOrExisting("session_id")
+	if err := service.LoginUser(ctx, sess, "user"); err != nil {
+		t.Fatal(err)
+	}
+
+	if sessions := service.UserSessions("user"); len(sessions) != 1 || sessions[0].RemoteAddr != "10.0.0.5" {
+		t.Error("expected the peer address, not the forwarded one", sessions)
+	}
+}
+
+func TestAllowPublic(t *testing.T) {
+	service := NewIdentityService(nil)
+	guardRan := false
+	guard := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			guardRan = true
+			return c.String(http.StatusForbidden, "guarded")
+		}
+	}
+
+	rows := []struct {
+		networks string
+		public   bool
+	}{
+		{"", false},
+		{"10.0.0.0/8", false},
+		// httptest requests come from 192.0.2.1
+		{"10.0.0.0/8, 192.0.2.0/24", true},
+		{"192.0.2.1", true},
+		{"0.0.0.0/0,::/0", true},
+	}
+
+	for _, row := range rows {
+		networks, err := ParseNetworks(row.networks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.Cfg.PublicNetworks = networks
+		guardRan = false
+
+		ctx := &testhelpers.FakeContext{Values: make(map[string]interface{}), FakeWriter: httptest.NewRecorder()}
+		_ = service.AllowPublic(guard)(testhelpers.EmptyHandler)(ctx)
+
+		if guardRan == row.public {
+			t.Error("unexpected guard result", row.networks)
+		}
+	}
+}
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := ParseNetworks("192.168.1.0/24, 10.0.0.5,fd00::/8,::1")
+	if err != nil || len(networks) != 4 {
+		t.Fatal("unexpected networks", networks, err)
+	}
+
+	if networks[1].String() != "10.0.0.5/32" || networks[3].String() != "::1/128" {
+		t.Error("expected single IPs to be full masks", networks[1], networks[3])
+	}
+
+	for _, spec := range []string{"192.168.1.0/33", "not-an-ip", "10.0.0.0/8,300.1.1.1"} {
+		if _, err := ParseNetworks(spec); err == nil {
+			t.Error("expected error for", spec)
+		}
+	}
+}
+
+func TestCookieOptions(t *testing.T) {
+	service := NewIdentityService(&IdentityConfig{CookieStoreKeySession: "test", CookiePath: "/airmon", CookieSecure: true, CookieSameSite: http.SameSiteStrictMode})
+	options := service.cookieStore.(*sessions.CookieStore).Options
+	if options.Path != "/airmon" || !options.Secure || !options.HttpOnly || options.SameSite != http.SameSiteStrictMode {
+		t.Error("unexpected cookie options", options)
+	}
+
+	if options := NewIdentityService(nil).cookieStore.(*sessions.CookieStore).Options; options.Path != "/" || options.Secure {
+		t.Error("unexpected default cookie options", options)
+	}
+
+	rows := map[string]http.SameSite{"": http.SameSiteLaxMode, "Lax": http.SameSiteLaxMode, "strict": http.SameSiteStrictMode, "none": http.SameSiteNoneMode}
+	for mode, expected := range rows {
+		if actual, err := ParseSameSite(mode); err != nil || actual != expected {
+			t.Error("unexpected SameSite", mode, actual, err)
+		}
+	}
+
+	if _, err := ParseSameSite("sometimes"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestPendingLogin(t *testing.T) {
+	service := NewIdentityService(nil)
+	service.cookieStore = &testhelpers.FakeCookieStore{Sessions: make(map[string]*sessions.Session)}
+	ctx := &testhelpers.FakeContext{Values: make(map[string]interface{}), FakeWriter: httptest.NewRecorder()}
+
+	if _, ok := service.PendingLogin(ctx); ok {
+		t.Error("expected no pending login")
+	}
+
+	if err := service.StartPendingLogin(ctx, "user_id"); err != nil {
+		t.Fatal(err)
+	}
+
+	if userID, ok := service.PendingLogin(ctx); !ok || userID != "user_id" {
+		t.Error("expected pending login", userID)
+	}
+
+	cookieSession, _ := service.cookieStore.Get(nil, service.Cfg.CookieStoreKeySession)
+	cookieSession.Values[CookiesValuePendingTimeKey] = time.Now().Add(-pendingLoginTimeout - time.Second).Unix()
+	if _, ok := service.PendingLogin(ctx); ok {
+		t.Error("expected pending login to time out")
+	}
+
+	service.StartPendingLogin(ctx, "user_id")
+	if err := service.ClearPendingLogin(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := service.PendingLogin(ctx); ok {
+		t.Error("expected pending login to be cleared")
+	}
+}
@@ -0,0 +1,235 @@
+package invite
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"goairmon/business/data/context"
+	"goairmon/business/data/models"
+	"goairmon/business/services/useradmin"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo"
+)
+
+var errInvalid = fmt.Errorf("invite is invalid or has expired")
+
+func NewInviteService(cfg *Config, dbContext context.DbContext) *InviteService {
+	if cfg.GCDelay <= 0 {
+		cfg.GCDelay = 10 * time.Minute
+	}
+
+	return &InviteService{
+		cfg:       cfg,
+		dbContext: dbContext,
+		now:       time.Now,
+	}
+}
+
+type Config struct {
+	// Secret that signs invite tokens so they can't be made up from an invite ID
+	Key     string
+	Expiry  time.Duration
+	GCDelay time.Duration
+	Logger  echo.Logger
+}
+
+// Hands out single-use links for creating accounts, removing them once they expire.
+type InviteService struct {
+	cfg        *Config
+	dbContext  context.DbContext
+	stopChan   chan int
+	lock       sync.Mutex
+	acceptLock sync.Mutex
+	now        func() time.Time
+}
+
+func (s *InviteService) Start() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stopChan != nil {
+		return fmt.Errorf("service already started")
+	}
+
+	s.stopChan = make(chan int)
+	go s.gcRoutine(s.stopChan)
+
+	return nil
+}
+
+func (s *InviteService) Stop() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stopChan == nil {
+		return fmt.Errorf("service already stopped")
+	}
+
+	close(s.stopChan)
+	s.stopChan = nil
+
+	return nil
+}
+
+// Create saves a new invite for the role, returning the token for its link.
+func (s *InviteService) Create(role string, createdBy string) (string, *models.Invite, error) {
+	if err := useradmin.ValidateRole(role); err != nil {
+		return "", nil, err
+	}
+
+	now := s.now()
+	invite := &models.Invite{
+		Role:      role,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.cfg.Expiry),
+	}
+
+	if err := s.dbContext.CreateInvite(invite); err != nil {
+		return "", nil, fmt.Errorf("failed to create invite: %s", err)
+	}
+
+	if err := s.dbContext.Save(); err != nil {
+		return "", nil, err
+	}
+
+	return s.Token(invite), invite, nil
+}
+
+// Token is the invite's ID and expiry, signed with Config.Key.
+func (s *InviteService) Token(invite *models.Invite) string {
+	payload := invite.ID.String() + "." + strconv.FormatInt(invite.ExpiresAt.Unix(), 10)
+
+	return payload + "." + s.sign(payload)
+}
+
+// Find checks the token and returns its invite if it's still pending.
+func (s *InviteService) Find(token string) (*models.Invite, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errInvalid
+	}
+
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(s.sign(payload))) {
+		return nil, errInvalid
+	}
+
+	id, err := uuid.Parse(parts[0])
+	if err != nil {
+		return nil, errInvalid
+	}
+
+	invite, err := s.dbContext.FindInvite(id)
+	if err != nil || invite.Expired(s.now()) || strconv.FormatInt(invite.ExpiresAt.Unix(), 10) != parts[1] {
+		return nil, errInvalid
+	}
+
+	return invite, nil
+}
+
+// Accept creates the user with the invite's role and uses up the invite.
+// The user's username and password should already be validated.
+func (s *InviteService) Accept(token string, user *models.User) (*models.Invite, error) {
+	s.acceptLock.Lock()
+	defer s.acceptLock.Unlock()
+
+	invite, err := s.Find(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := useradmin.ValidateUsername(s.dbContext, user.Username); err != nil {
+		return nil, err
+	}
+
+	// The invite is only used up once the user is saved
+	user.Role = invite.Role
+	if err := s.dbContext.CreateOrUpdateUser(user); err != nil {
+		return nil, fmt.Errorf("failed to create user: %s", err)
+	}
+
+	if err := s.dbContext.DeleteInvite(invite.ID); err != nil {
+		s.dbContext.DeleteUser(user.ID)
+		return nil, errInvalid
+	}
+
+	return invite, s.dbContext.Save()
+}
+
+// Pending returns the invites that haven't expired, newest first.
+func (s *InviteService) Pending() ([]*models.Invite, error) {
+	invites, err := s.dbContext.GetInvites()
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	pending := make([]*models.Invite, 0, len(invites))
+	for _, invite := range invites {
+		if !invite.Expired(now) {
+			pending = append(pending, invite)
+		}
+	}
+
+	return pending, nil
+}
+
+func (s *InviteService) Revoke(id uuid.UUID) error {
+	if err := s.dbContext.DeleteInvite(id); err != nil {
+		return err
+	}
+
+	return s.dbContext.Save()
+}
+
+// RemoveExpired deletes expired invites, returning how many were removed.
+func (s *InviteService) RemoveExpired() (int, error) {
+	invites, err := s.dbContext.GetInvites()
+	if err != nil {
+		return 0, err
+	}
+
+	now := s.now()
+	removed := 0
+	for _, invite := range invites {
+		if invite.Expired(now) && s.dbContext.DeleteInvite(invite.ID) == nil {
+			removed++
+		}
+	}
+
+	if removed == 0 {
+		return 0, nil
+	}
+
+	return removed, s.dbContext.Save()
+}
+
+func (s *InviteService) sign(payload string) string {
+	mac := hmac.New(sha256.New, []byte(s.cfg.Key))
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *InviteService) gcRoutine(stopChan chan int) {
+	ticker := time.NewTicker(s.cfg.GCDelay)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-ticker.C:
+			if _, err := s.RemoveExpired(); err != nil && s.cfg.Logger != nil {
+				s.cfg.Logger.Error("failed to remove expired invites", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package invite
+
+import (
+	"fmt"
+	"goairmon/business/data/context"
+	"goairmon/business/data/models"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func _inviteSetup(t *testing.T) (*InviteService, context.DbContext, *time.Time, string) {
+	dir, err := ioutil.TempDir("", "goairmon_invite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbContext := context.NewMemDbContext(&context.MemDbConfig{StoragePath: dir, SensorPointCount: 10})
+	service := NewInviteService(&Config{Key: "test-key", Expiry: time.Hour}, dbContext)
+
+	now := time.Date(2019, 10, 1, 3, 0, 0, 0, time.UTC)
+	service.now = func() time.Time {
+		return now
+	}
+
+	return service, dbContext, &now, dir
+}
+
+func TestAcceptInvite(t *testing.T) {
+	service, dbContext, _, dir := _inviteSetup(t)
+	defer os.RemoveAll(dir)
+
+	if _, _, err := service.Create("superuser", "admin-user"); err == nil {
+		t.Error("expected invalid role to fail")
+	}
+
+	token, invite, err := service.Create(models.RoleOperator, "admin-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found, err := service.Find(token); err != nil || found.ID != invite.ID {
+		t.Fatal("expected to find invite", found, err)
+	}
+
+	user := &models.User{Username: "invited-user", Role: models.RoleAdmin}
+	if _, err := service.Accept(token, user); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := dbContext.FindUserByName("invited-user")
+	if err != nil || saved.Role != models.RoleOperator {
+		t.Error("expected user with the invite's role", saved, err)
+	}
+
+	if _, err := service.Accept(token, &models.User{Username: "second-user"}); err == nil {
+		t.Error("expected invite to be single use")
+	}
+
+	token, _, _ = service.Create(models.RoleViewer, "admin-user")
+	if _, err := service.Accept(token, &models.User{Username: "invited-user"}); err == nil {
+		t.Error("expected taken username to fail")
+	}
+
+	if _, err := service.Find(token); err != nil {
+		t.Error("expected failed accept to leave the invite", err)
+	}
+}
+
+type _failingUserDb struct {
+	context.DbContext
+}
+
+func (d *_failingUserDb) CreateOrUpdateUser(user *models.User) error {
+	return fmt.Errorf("disk full")
+}
+
+func TestFailedAcceptKeepsInvite(t *testing.T) {
+	service, dbContext, _, dir := _inviteSetup(t)
+	defer os.RemoveAll(dir)
+
+	token, _, err := service.Create(models.RoleViewer, "admin-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service.dbContext = &_failingUserDb{dbContext}
+	if _, err := service.Accept(token, &models.User{Username: "invited-user"}); err == nil {
+		t.Fatal("expected failed save to fail")
+	}
+
+	service.dbContext = dbContext
+	if _, err := service.Accept(token, &models.User{Username: "invited-user"}); err != nil {
+		t.Error("expected invite to still be usable", err)
+	}
+}
+
+func TestFindRejectsBadTokens(t *testing.T) {
+	service, _, now, dir := _inviteSetup(t)
+	defer os.RemoveAll(dir)
+
+	token, invite, err := service.Create(models.RoleViewer, "admin-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.Split(token, ".")
+	other := NewInviteService(&Config{Key: "other-key", Expiry: time.Hour}, service.dbContext)
+
+	rows := []string{
+		"",
+		"not-a-token",
+		parts[0] + "." + parts[1] + ".bad-signature",
+		parts[0] + "." + "1" + "." + parts[2],
+		other.Token(invite),
+	}
+
+	for _, row := range rows {
+		if _, err := service.Find(row); err == nil {
+			t.Error("expected token to be rejected", row)
+		}
+	}
+
+	*now = now.Add(time.Hour)
+	if _, err := service.Find(token); err == nil {
+		t.Error("expected expired invite to be rejected")
+	}
+}
+
+func TestRemoveExpired(t *testing.T) {
+	service, dbContext, now, dir := _inviteSetup(t)
+	defer os.RemoveAll(dir)
+
+	service.Create(models.RoleViewer, "admin-user")
+	*now = now.Add(30 * time.Minute)
+	_, kept, _ := service.Create(models.RoleViewer, "admin-user")
+	*now = now.Add(45 * time.Minute)
+
+	if pending, _ := service.Pending(); len(pending) != 1 || pending[0].ID != kept.ID {
+		t.Error("expected only the unexpired invite to be pending", pending)
+	}
+
+	if removed, err := service.RemoveExpired(); err != nil || removed != 1 {
+		t.Error("expected one invite removed", removed, err)
+	}
+
+	if invites, _ := dbContext.GetInvites(); len(invites) != 1 || invites[0].ID != kept.ID {
+		t.Error("unexpected invites left", invites)
+	}
+
+	if err := service.Revoke(kept.ID); err != nil {
+		t.Error(err)
+	}
+
+	if err := service.Revoke(kept.ID); err == nil {
+		t.Error("expected revoking twice to fail")
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	service, _, _, dir := _inviteSetup(t)
+	defer os.RemoveAll(dir)
+
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.Start(); err == nil {
+		t.Error("expected error starting twice")
+	}
+
+	if err := service.Stop(); err != nil {
+		t.Error(err)
+	}
+
+	if err := service.Stop(); err == nil {
+		t.Error("expected error stopping twice")
+	}
+}
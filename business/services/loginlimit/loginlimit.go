@@ -0,0 +1,239 @@
+package loginlimit
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+func NewLoginLimiter(cfg *Config) *LoginLimiter {
+	if cfg.MaxTracked <= 0 {
+		cfg.MaxTracked = 10000
+	}
+
+	return &LoginLimiter{
+		cfg:      cfg,
+		attempts: make(map[string]*attempt),
+		now:      time.Now,
+	}
+}
+
+type Config struct {
+	// Failures allowed before an IP or username is locked out
+	MaxAttempts int
+	// The first lockout, doubling for every failure after it up to MaxLockout
+	Lockout    time.Duration
+	MaxLockout time.Duration
+	// Failures are forgotten once there have been none for this long
+	Reset time.Duration
+	// IPs and usernames tracked at once, the oldest are forgotten past this, defaults to 10000
+	MaxTracked int
+}
+
+type Status struct {
+	Failures    int
+	LockedUntil time.Time
+}
+
+func (s Status) Locked(now time.Time) bool {
+	return s.LockedUntil.After(now)
+}
+
+type attempt struct {
+	Status
+	lastFailure time.Time
+}
+
+// Tracks failed logins per IP and per username, locking either out with exponential backoff.
+type LoginLimiter struct {
+	cfg      *Config
+	attempts map[string]*attempt
+	lock     sync.Mutex
+	now      func() time.Time
+}
+
+// Check fails if the IP or username is locked out, saying how long until they can try again.
+func (l *LoginLimiter) Check(ip string, username string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.check(keys(ip, username), l.now())
+}
+
+// Attempt checks the IP and username like Check, then counts the attempt as a failure until Succeed
+// clears it. Counting up front means concurrent guesses can't all get past the check.
+func (l *LoginLimiter) Attempt(ip string, username string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	if err := l.check(keys(ip, username), now); err != nil {
+		return err
+	}
+
+	l.fail(keys(ip, username), now)
+
+	return nil
+}
+
+// AttemptSecondFactor is Attempt for a two factor code. The password's attempt is still counted
+// against the IP and username until the code succeeds, so codes are counted under their own key.
+func (l *LoginLimiter) AttemptSecondFactor(ip string, username string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	if err := l.check(append(keys(ip, username), secondFactorKey(username)), now); err != nil {
+		return err
+	}
+
+	l.fail([]string{secondFactorKey(username)}, now)
+
+	return nil
+}
+
+func (l *LoginLimiter) check(keys []string, now time.Time) error {
+	var lockedUntil time.Time
+	for _, key := range keys {
+		if a, ok := l.attempts[key]; ok && a.Locked(now) && a.LockedUntil.After(lockedUntil) {
+			lockedUntil = a.LockedUntil
+		}
+	}
+
+	if lockedUntil.IsZero() {
+		return nil
+	}
+
+	wait := lockedUntil.Sub(now).Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+
+	return fmt.Errorf("too many failed logins, try again in %s", wait)
+}
+
+// Records a failed login, locking out the keys once they pass Config.MaxAttempts.
+func (l *LoginLimiter) fail(keys []string, now time.Time) {
+	l.prune(now)
+
+	for _, key := range keys {
+		a, ok := l.attempts[key]
+		if !ok {
+			if len(l.attempts) >= l.cfg.MaxTracked {
+				l.evictOldest()
+			}
+			a = &attempt{}
+			l.attempts[key] = a
+		}
+
+		a.Failures++
+		a.lastFailure = now
+
+		if over := a.Failures - l.cfg.MaxAttempts; over >= 0 {
+			a.LockedUntil = now.Add(l.lockout(over))
+		}
+	}
+}
+
+// Succeed clears failures for the IP and username after a good password.
+func (l *LoginLimiter) Succeed(ip string, username string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, key := range keys(ip, username) {
+		delete(l.attempts, key)
+	}
+}
+
+// SucceedSecondFactor clears failures for the IP, username and their codes after a good code.
+// A good password alone doesn't clear code failures, or it could be used to keep guessing codes.
+func (l *LoginLimiter) SucceedSecondFactor(ip string, username string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, key := range append(keys(ip, username), secondFactorKey(username)) {
+		delete(l.attempts, key)
+	}
+}
+
+// UserStatus returns the failures and lockout for a username's password or codes,
+// whichever is locked out longer or has more failures.
+func (l *LoginLimiter) UserStatus(username string) Status {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	status := Status{}
+	for _, key := range []string{userKey(username), secondFactorKey(username)} {
+		a, ok := l.attempts[key]
+		if !ok || l.now().Sub(a.lastFailure) >= l.cfg.Reset {
+			continue
+		}
+
+		if a.LockedUntil.After(status.LockedUntil) || (a.LockedUntil.Equal(status.LockedUntil) && a.Failures > status.Failures) {
+			status = a.Status
+		}
+	}
+
+	return status
+}
+
+// Unlock clears a username's failures so they can log in straight away.
+func (l *LoginLimiter) Unlock(username string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	delete(l.attempts, userKey(username))
+	delete(l.attempts, secondFactorKey(username))
+}
+
+// Now is the limiter's clock, for comparing against Status.LockedUntil.
+func (l *LoginLimiter) Now() time.Time {
+	return l.now()
+}
+
+func (l *LoginLimiter) lockout(over int) time.Duration {
+	lockout := l.cfg.Lockout
+	for i := 0; i < over && lockout < l.cfg.MaxLockout; i++ {
+		lockout *= 2
+	}
+
+	if lockout > l.cfg.MaxLockout {
+		lockout = l.cfg.MaxLockout
+	}
+
+	return lockout
+}
+
+func (l *LoginLimiter) prune(now time.Time) {
+	for key, a := range l.attempts {
+		if !a.Locked(now) && now.Sub(a.lastFailure) >= l.cfg.Reset {
+			delete(l.attempts, key)
+		}
+	}
+}
+
+// Makes room when failures are sprayed over many usernames or IPs.
+func (l *LoginLimiter) evictOldest() {
+	oldestKey := ""
+	var oldest time.Time
+	for key, a := range l.attempts {
+		if oldestKey == "" || a.lastFailure.Before(oldest) {
+			oldestKey, oldest = key, a.lastFailure
+		}
+	}
+
+	delete(l.attempts, oldestKey)
+}
+
+func keys(ip string, username string) []string {
+	return []string{"ip:" + ip, userKey(username)}
+}
+
+func userKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func secondFactorKey(username string) string {
+	return "2fa:" + strings.ToLower(username)
+}
@@ -0,0 +1,186 @@
+package loginlimit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func _limiterSetup() (*LoginLimiter, *time.Time) {
+	limiter := NewLoginLimiter(&Config{
+		MaxAttempts: 3,
+		Lockout:     time.Minute,
+		MaxLockout:  5 * time.Minute,
+		Reset:       time.Hour,
+	})
+
+	now := time.Date(2019, 10, 1, 3, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time {
+		return now
+	}
+
+	return limiter, &now
+}
+
+func TestLockoutBackoff(t *testing.T) {
+	limiter, now := _limiterSetup()
+
+	for i := 0; i < 2; i++ {
+		limiter.Attempt("10.0.0.1", "user")
+		if err := limiter.Check("10.0.0.1", "user"); err != nil {
+			t.Error("unexpected lockout", i, err)
+		}
+	}
+
+	rows := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for _, lockout := range rows {
+		limiter.Attempt("10.0.0.1", "user")
+
+		if err := limiter.Check("10.0.0.1", "user"); err == nil {
+			t.Error("expected lockout")
+		}
+
+		*now = now.Add(lockout - time.Second)
+		if err := limiter.Check("10.0.0.1", "user"); err == nil {
+			t.Error("expected lockout to last", lockout)
+		}
+
+		*now = now.Add(time.Second)
+		if err := limiter.Check("10.0.0.1", "user"); err != nil {
+			t.Error("expected lockout to end after", lockout, err)
+		}
+	}
+
+	status := limiter.UserStatus("USER")
+	if status.Failures != 7 || status.Locked(*now) {
+		t.Error("unexpected status", status)
+	}
+
+	*now = now.Add(time.Hour)
+	if status := limiter.UserStatus("user"); status.Failures != 0 {
+		t.Error("expected failures to reset", status)
+	}
+
+	limiter.Attempt("10.0.0.1", "user")
+	if err := limiter.Check("10.0.0.1", "user"); err != nil {
+		t.Error("expected old failures to be forgotten", err)
+	}
+}
+
+func TestLockoutPerIpAndUsername(t *testing.T) {
+	limiter, now := _limiterSetup()
+
+	for i := 0; i < 3; i++ {
+		limiter.Attempt("10.0.0.1", "user")
+	}
+
+	if err := limiter.Check("10.0.0.2", "user"); err == nil {
+		t.Error("expected username to be locked from any IP")
+	}
+
+	if err := limiter.Check("10.0.0.1", "other-user"); err == nil {
+		t.Error("expected IP to be locked for any username")
+	}
+
+	if err := limiter.Check("10.0.0.2", "other-user"); err != nil {
+		t.Error("unexpected lockout", err)
+	}
+
+	limiter.Unlock("user")
+	if err := limiter.Check("10.0.0.2", "user"); err != nil {
+		t.Error("expected username to be unlocked", err)
+	}
+
+	if !limiter.UserStatus("user").LockedUntil.IsZero() {
+		t.Error("expected no lockout status")
+	}
+
+	*now = now.Add(time.Minute)
+	limiter.Attempt("10.0.0.3", "user")
+	limiter.Succeed("10.0.0.3", "user")
+	if status := limiter.UserStatus("user"); status.Failures != 0 {
+		t.Error("expected success to clear failures", status)
+	}
+}
+
+func TestSecondFactor(t *testing.T) {
+	limiter, _ := _limiterSetup()
+
+	limiter.Attempt("10.0.0.1", "user")
+	for i := 0; i < 3; i++ {
+		limiter.Attempt("10.0.0.1", "user")
+		if err := limiter.AttemptSecondFactor("10.0.0.1", "user"); err != nil {
+			t.Fatal("expected a good password to get to the code", i, err)
+		}
+		limiter.SucceedSecondFactor("10.0.0.1", "user")
+	}
+
+	if status := limiter.UserStatus("user"); status.Failures != 0 {
+		t.Error("expected two factor logins not to count as failures", status)
+	}
+
+	for i := 0; i < 3; i++ {
+		limiter.Attempt("10.0.0.1", "user")
+		limiter.Succeed("10.0.0.1", "user")
+		if err := limiter.AttemptSecondFactor("10.0.0.1", "user"); err != nil {
+			t.Error("unexpected lockout", i, err)
+		}
+	}
+
+	if err := limiter.AttemptSecondFactor("10.0.0.1", "user"); err == nil {
+		t.Error("expected codes to be locked out even after good passwords")
+	}
+
+	if err := limiter.Check("10.0.0.1", "user"); err != nil {
+		t.Error("expected passwords not to be locked by code failures", err)
+	}
+
+	if status := limiter.UserStatus("user"); status.Failures != 3 || status.LockedUntil.IsZero() {
+		t.Error("expected code lockout in status", status)
+	}
+
+	limiter.Unlock("user")
+	if err := limiter.AttemptSecondFactor("10.0.0.1", "user"); err != nil {
+		t.Error("expected unlock to clear code failures", err)
+	}
+}
+
+func TestConcurrentAttempts(t *testing.T) {
+	limiter, _ := _limiterSetup()
+
+	allowed := make(chan bool, 20)
+	for i := 0; i < 20; i++ {
+		go func() {
+			allowed <- limiter.Attempt("10.0.0.1", "user") == nil
+		}()
+	}
+
+	count := 0
+	for i := 0; i < 20; i++ {
+		if <-allowed {
+			count++
+		}
+	}
+
+	if count != 3 {
+		t.Error("expected only MaxAttempts attempts to get through", count)
+	}
+}
+
+func TestMaxTracked(t *testing.T) {
+	limiter, now := _limiterSetup()
+	limiter.cfg.MaxTracked = 4
+
+	for i := 0; i < 10; i++ {
+		*now = now.Add(time.Second)
+		limiter.Attempt(fmt.Sprintf("10.0.0.%d", i), fmt.Sprintf("user-%d", i))
+	}
+
+	if len(limiter.attempts) != 4 {
+		t.Error("expected attempts to be capped", len(limiter.attempts))
+	}
+
+	if limiter.UserStatus("user-9").Failures != 1 || limiter.UserStatus("user-0").Failures != 0 {
+		t.Error("expected the oldest usernames to be forgotten")
+	}
+}
@@ -0,0 +1,19 @@
+package passpolicy
+
+// Common passwords from public breach lists, compared case insensitively.
+var commonPasswords = []string{
+	"123456", "1234567", "12345678", "123456789", "1234567890", "0123456789", "0987654321",
+	"111111", "11111111", "000000", "00000000", "121212", "123123", "123321", "654321",
+	"666666", "696969", "112233", "123qwe", "1q2w3e", "1q2w3e4r", "1q2w3e4r5t", "1qaz2wsx",
+	"qwerty", "qwerty1", "qwerty12", "qwerty123", "qwertyuiop", "asdfgh", "asdfghjkl", "zxcvbnm",
+	"password", "password1", "password12", "password123", "passw0rd", "p@ssw0rd", "p@ssword",
+	"abc123", "abcd1234", "abcdef", "abcdefg", "abcdefgh", "aa123456", "a1b2c3d4",
+	"admin", "admin123", "administrator", "root", "toor", "changeme", "default", "guest",
+	"letmein", "welcome", "welcome1", "welcome123", "login", "master", "secret", "trustno1",
+	"iloveyou", "princess", "sunshine", "starwars", "football", "baseball", "basketball",
+	"soccer", "hockey", "dragon", "monkey", "shadow", "superman", "batman", "pokemon",
+	"michael", "jennifer", "jordan23", "charlie", "daniel", "thomas", "hunter2", "freedom",
+	"whatever", "computer", "internet", "mustang", "access", "flower", "cheese", "summer",
+	"winter", "spring", "autumn", "killer", "ginger", "pepper", "cookie", "chocolate",
+	"raspberry", "raspberrypi", "pi", "goairmon", "airmonitor", "sensor",
+}
@@ -0,0 +1,143 @@
+package passpolicy
+
+import (
+	"bufio"
+	"fmt"
+	"goairmon/business/data/models"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func NewPolicy(cfg *Config) (*Policy, error) {
+	if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	blocked := make(map[string]bool, len(commonPasswords))
+	for _, password := range commonPasswords {
+		blocked[password] = true
+	}
+
+	if cfg.BlocklistFile != "" {
+		if err := readBlocklist(cfg.BlocklistFile, blocked); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Policy{cfg: cfg, blocked: blocked}, nil
+}
+
+// DefaultPolicy is used when the policy isn't configured, e.g. in tests.
+func DefaultPolicy() *Policy {
+	policy, _ := NewPolicy(DefaultConfig())
+	return policy
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		MinLength:  8,
+		History:    3,
+		BcryptCost: bcrypt.DefaultCost,
+	}
+}
+
+type Config struct {
+	MinLength int
+	// New passwords can't match the current or previous History-1 passwords
+	History    int
+	BcryptCost int
+	// Extra passwords to refuse, one per line
+	BlocklistFile string
+}
+
+type Policy struct {
+	cfg     *Config
+	blocked map[string]bool
+}
+
+// Validate checks the password is long enough, not common and not recently used by the user.
+func (p *Policy) Validate(user *models.User, password string) error {
+	if len(password) < p.cfg.MinLength {
+		return fmt.Errorf("password must be atleast %d characters", p.cfg.MinLength)
+	}
+
+	lower := strings.ToLower(password)
+	if p.blocked[lower] || (user != nil && lower == strings.ToLower(user.Username)) {
+		return fmt.Errorf("password is too common, please choose another")
+	}
+
+	if user != nil && p.reused(user, password) {
+		return fmt.Errorf("password was used recently, please choose another")
+	}
+
+	return nil
+}
+
+// SetPassword validates and hashes the new password, remembering the old hash so it can't be reused.
+func (p *Policy) SetPassword(user *models.User, password string) error {
+	if err := p.Validate(user, password); err != nil {
+		return err
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), p.cfg.BcryptCost)
+	if err != nil {
+		return fmt.Errorf("failed to set password: %s", err)
+	}
+
+	if len(user.PasswordHash) > 0 && p.cfg.History > 1 {
+		history := append([][]byte{user.PasswordHash}, user.PasswordHistory...)
+		if len(history) > p.cfg.History-1 {
+			history = history[:p.cfg.History-1]
+		}
+		user.PasswordHistory = history
+	}
+
+	user.PasswordHash = hashed
+
+	return nil
+}
+
+func (p *Policy) reused(user *models.User, password string) bool {
+	if p.cfg.History < 1 {
+		return false
+	}
+
+	if len(user.PasswordHash) > 0 && user.CheckPassword(password) {
+		return true
+	}
+
+	for i, hash := range user.PasswordHistory {
+		if i >= p.cfg.History-1 {
+			break
+		}
+
+		if bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func readBlocklist(path string, blocked map[string]bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open password blocklist: %s", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			blocked[strings.ToLower(line)] = true
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read password blocklist: %s", err)
+	}
+
+	return nil
+}
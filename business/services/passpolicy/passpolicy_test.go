@@ -0,0 +1,125 @@
+package passpolicy
+
+import (
+	"goairmon/business/data/models"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func _testPolicy(t *testing.T, cfg *Config) *Policy {
+	if cfg.BcryptCost == 0 {
+		cfg.BcryptCost = bcrypt.MinCost
+	}
+
+	policy, err := NewPolicy(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return policy
+}
+
+func TestValidate(t *testing.T) {
+	policy := _testPolicy(t, &Config{MinLength: 8})
+	user := &models.User{Username: "long-username"}
+
+	rows := []struct {
+		password string
+		valid    bool
+	}{
+		{"short", false},
+		{"password", false},
+		{"PassWord1", false},
+		{"Long-Username", false},
+		{"correct horse battery", true},
+	}
+
+	for _, row := range rows {
+		if err := policy.Validate(user, row.password); (err == nil) != row.valid {
+			t.Error("unexpected result", row.password, err)
+		}
+	}
+
+	if err := policy.Validate(nil, "correct horse battery"); err != nil {
+		t.Error("expected new user password to validate", err)
+	}
+}
+
+func TestPasswordHistory(t *testing.T) {
+	policy := _testPolicy(t, &Config{MinLength: 8, History: 3, BcryptCost: bcrypt.MinCost + 1})
+	user := &models.User{Username: "history-user"}
+
+	for _, password := range []string{"first-password", "second-password", "third-password"} {
+		if err := policy.SetPassword(user, password); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if cost, _ := bcrypt.Cost(user.PasswordHash); cost != bcrypt.MinCost+1 {
+		t.Error("expected configured bcrypt cost", cost)
+	}
+
+	if len(user.PasswordHistory) != 2 {
+		t.Error("expected history to be trimmed", len(user.PasswordHistory))
+	}
+
+	for _, password := range []string{"third-password", "second-password"} {
+		if err := policy.SetPassword(user, password); err == nil {
+			t.Error("expected recent password to be refused", password)
+		}
+	}
+
+	if err := policy.SetPassword(user, "first-password"); err == nil {
+		t.Error("expected third newest password to be refused")
+	}
+
+	if err := policy.SetPassword(user, "fourth-password"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := policy.SetPassword(user, "first-password"); err != nil {
+		t.Error("expected old password to be allowed", err)
+	}
+
+	if !user.CheckPassword("first-password") {
+		t.Error("expected password to be changed")
+	}
+
+	noHistory := _testPolicy(t, &Config{MinLength: 8})
+	if err := noHistory.SetPassword(user, "first-password"); err != nil || len(user.PasswordHistory) != 2 {
+		t.Error("expected reuse without history", err)
+	}
+}
+
+func TestBlocklistFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "goairmon_blocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	file.WriteString("Office-Password\n\n  building-42  \n")
+	file.Close()
+
+	policy := _testPolicy(t, &Config{MinLength: 8, BlocklistFile: file.Name()})
+	for _, password := range []string{"office-password", "BUILDING-42", "password"} {
+		if err := policy.Validate(nil, password); err == nil {
+			t.Error("expected password to be blocked", password)
+		}
+	}
+
+	if _, err := NewPolicy(&Config{MinLength: 8, BcryptCost: bcrypt.MinCost, BlocklistFile: file.Name() + "_missing"}); err == nil {
+		t.Error("expected missing blocklist to fail")
+	}
+
+	if _, err := NewPolicy(&Config{MinLength: 8, BcryptCost: 100}); err == nil {
+		t.Error("expected bad bcrypt cost to fail")
+	}
+
+	if DefaultPolicy() == nil {
+		t.Error("expected default policy")
+	}
+}
@@ -13,31 +13,80 @@ import (
 
 func NewPollService(cfg *Config, dbContext context.DbContext) *PollService {
 	sensorCfg := &hardware.Co2SensorCfg{
-		ReadDelayMillis:      1000,
-		BaselineDelaySeconds: 60,
-		Logger:               cfg.Logger,
+		ReadDelayMillis:      cfg.SensorReadMillis,
+		BaselineDelaySeconds: cfg.BaselineDelaySeconds,
+		Logger:               cfg.SensorLogger,
+	}
+	if sensorCfg.Logger == nil {
+		sensorCfg.Logger = cfg.Logger
+	}
+	if sensorCfg.ReadDelayMillis <= 0 {
+		sensorCfg.ReadDelayMillis = 1000
+	}
+	if sensorCfg.BaselineDelaySeconds <= 0 {
+		sensorCfg.BaselineDelaySeconds = 60
 	}
 	co2Sensor := hardware.NewPiCo2Sensor(sensorCfg, dbContext)
 
 	return &PollService{
-		cfg:       cfg,
-		co2Sensor: co2Sensor,
-		stopChan:  nil,
-		dbContext: dbContext,
+		cfg:          cfg,
+		co2Sensor:    co2Sensor,
+		sensorStatus: co2Sensor.Status,
+		stopChan:     nil,
+		dbContext:    dbContext,
 	}
 }
 
 type PollService struct {
-	dbContext context.DbContext
-	stopChan  chan int
-	lock      sync.Mutex
-	cfg       *Config
-	co2Sensor *hardware.Co2Sensor
+	dbContext  context.DbContext
+	stopChan   chan int
+	lock       sync.Mutex
+	cfg        *Config
+	co2Sensor  *hardware.Co2Sensor
+	status     Status
+	statusLock sync.Mutex
+	// The sensor's status, replaced in tests
+	sensorStatus func() hardware.SensorStatus
+}
+
+type Status struct {
+	Running   bool
+	Interval  time.Duration
+	StartedAt time.Time
+	// Last time a point was taken and last time storage was saved after one
+	LastPoll  time.Time
+	LastSave  time.Time
+	LastError string
+	Sensor    hardware.SensorStatus
+}
+
+// Status reports when the poll loop last took a point and saved it, along with the sensor's status.
+func (p *PollService) Status() Status {
+	p.statusLock.Lock()
+	status := p.status
+	p.statusLock.Unlock()
+
+	status.Interval = time.Millisecond * time.Duration(p.cfg.PollDelayMillis)
+	status.Sensor = p.sensorStatus()
+
+	return status
+}
+
+func (p *PollService) setStatus(update func(status *Status)) {
+	p.statusLock.Lock()
+	defer p.statusLock.Unlock()
+
+	update(&p.status)
 }
 
 type Config struct {
 	PollDelayMillis int
 	Logger          echo.Logger
+	// Used by the sensor, defaults to Logger
+	SensorLogger echo.Logger
+	// How often the sensor is read and its baseline saved, default to 1s and 60s
+	SensorReadMillis     int
+	BaselineDelaySeconds int
 }
 
 func (p *PollService) Start() error {
@@ -53,6 +102,9 @@ func (p *PollService) Start() error {
 	}
 
 	p.stopChan = make(chan int)
+	p.setStatus(func(status *Status) {
+		*status = Status{Running: true, StartedAt: time.Now()}
+	})
 
 	ticker := time.NewTicker(time.Millisecond * time.Duration(p.cfg.PollDelayMillis))
 	go p.pollRoutine(ticker)
@@ -60,6 +112,9 @@ func (p *PollService) Start() error {
 	return nil
 }
 
+// Stop records a last point from the sensor, so it isn't lost on shutdown, then closes the sensor.
+// The point is only recorded if the sensor was read within the poll interval, a stale reading would
+// be saved as if it were current.
 func (p *PollService) Stop() error {
 	p.lock.Lock()
 	defer p.lock.Unlock()
@@ -75,11 +130,22 @@ func (p *PollService) Stop() error {
 		break
 	}
 
+	lastRead := p.sensorStatus().LastRead
+	fresh := !lastRead.IsZero() && time.Since(lastRead) <= time.Millisecond*time.Duration(p.cfg.PollDelayMillis)
+	if fresh && (p.co2Sensor.ECO2 != 0 || p.co2Sensor.TVOC != 0) {
+		if err := p.pushPoint(); err != nil {
+			p.cfg.Logger.Error("failed to save last sensor point", err)
+		}
+	}
+
 	if err := p.co2Sensor.Close(); err != nil {
 		return err
 	}
 
 	p.stopChan = nil
+	p.setStatus(func(status *Status) {
+		status.Running = false
+	})
 
 	return nil
 }
@@ -103,10 +169,34 @@ func (p *PollService) takePoll() error {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
-	err := p.dbContext.PushSensorPoint(&models.SensorPoint{Time: time.Now(), Co2Value: float64(p.co2Sensor.ECO2)})
+	return p.pushPoint()
+}
+
+func (p *PollService) pushPoint() error {
+	err := p.dbContext.PushSensorPoint(&models.SensorPoint{
+		Time:      time.Now(),
+		Co2Value:  float64(p.co2Sensor.ECO2),
+		TVOCValue: float64(p.co2Sensor.TVOC),
+	})
 	if err != nil {
+		p.setStatus(func(status *Status) {
+			status.LastError = err.Error()
+		})
 		return err
 	}
+	p.setStatus(func(status *Status) {
+		status.LastPoll = time.Now()
+	})
+
+	err = p.dbContext.Save()
+	p.setStatus(func(status *Status) {
+		if err != nil {
+			status.LastError = err.Error()
+		} else {
+			status.LastSave = status.LastPoll
+			status.LastError = ""
+		}
+	})
 
-	return p.dbContext.Save()
+	return err
 }
@@ -1,7 +1,9 @@
 package poll
 
 import (
+	"goairmon/business/data/context"
 	"goairmon/business/data/models"
+	"goairmon/business/hardware"
 	"testing"
 	"time"
 
@@ -85,6 +87,78 @@ func TestPollRoutine(t *testing.T) {
 	poll.stopChan <- 0
 }
 
+func TestStopSavesLastPoint(t *testing.T) {
+	sensorPoints := make([]*models.SensorPoint, 0)
+	var savedECO2 uint16
+	ctx := &_fakeDbContext{
+		setBaselineClosure: func(eCO2 uint16, tVOC uint16) error {
+			savedECO2 = eCO2
+			return nil
+		},
+		getBaselineClosure: func() (eCO2 uint16, tVOC uint16, err error) {
+			return 23, 2, nil
+		},
+		sensorPointClosure: func(point *models.SensorPoint) error {
+			sensorPoints = append(sensorPoints, point)
+			return nil
+		},
+	}
+
+	poll := NewPollService(&Config{PollDelayMillis: 1000 * 60, Logger: echo.New().Logger}, ctx)
+	if err := poll.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	poll.sensorStatus = func() hardware.SensorStatus {
+		return hardware.SensorStatus{Running: true, LastRead: time.Now()}
+	}
+	poll.co2Sensor.ECO2 = 412
+	if err := poll.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sensorPoints) != 1 || sensorPoints[0].Co2Value != 412 {
+		t.Error("expected last point to be saved", sensorPoints)
+	}
+
+	if savedECO2 != 23 {
+		t.Error("expected sensor baseline to be saved", savedECO2)
+	}
+}
+
+func TestStopSkipsStaleReading(t *testing.T) {
+	sensorPoints := make([]*models.SensorPoint, 0)
+	ctx := &_fakeDbContext{
+		setBaselineClosure: func(eCO2 uint16, tVOC uint16) error {
+			return nil
+		},
+		getBaselineClosure: func() (eCO2 uint16, tVOC uint16, err error) {
+			return 23, 2, nil
+		},
+		sensorPointClosure: func(point *models.SensorPoint) error {
+			sensorPoints = append(sensorPoints, point)
+			return nil
+		},
+	}
+
+	poll := NewPollService(&Config{PollDelayMillis: 1000 * 60, Logger: echo.New().Logger}, ctx)
+	if err := poll.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	poll.sensorStatus = func() hardware.SensorStatus {
+		return hardware.SensorStatus{Running: true, LastRead: time.Now().Add(-time.Hour), LastError: "remote I/O error"}
+	}
+	poll.co2Sensor.ECO2 = 412
+	if err := poll.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sensorPoints) != 0 {
+		t.Error("expected a stale reading not to be saved", sensorPoints)
+	}
+}
+
 type _fakeDbContext struct {
 	setBaselineClosure func(eCO2 uint16, TVOC uint16) error
 	getBaselineClosure func() (eCO2 uint16, TVOC uint16, err error)
@@ -107,14 +181,46 @@ func (f *_fakeDbContext) FindUserByName(username string) (*models.User, error) {
 	panic("not implemented")
 }
 
+func (f *_fakeDbContext) GetUsers() ([]*models.User, error) {
+	panic("not implemented")
+}
+
 func (f *_fakeDbContext) DeleteUser(id uuid.UUID) error {
 	panic("not implemented")
 }
 
+func (f *_fakeDbContext) CreateInvite(invite *models.Invite) error {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) FindInvite(id uuid.UUID) (*models.Invite, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) GetInvites() ([]*models.Invite, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) DeleteInvite(id uuid.UUID) error {
+	panic("not implemented")
+}
+
 func (f *_fakeDbContext) PushSensorPoint(point *models.SensorPoint) error {
 	return f.sensorPointClosure(point)
 }
 
+func (f *_fakeDbContext) MergeSensorPoints(points []*models.SensorPoint) (int, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) MergeArchivedDay(day time.Time, points []*models.SensorPoint) (int, error) {
+	panic("not implemented")
+}
+
+func (f *_fakeDbContext) Archives() context.ArchiveStore {
+	panic("not implemented")
+}
+
 func (f *_fakeDbContext) GetSensorPoints(count int) ([]*models.SensorPoint, error) {
 	panic("not implemented")
 }
@@ -134,3 +240,7 @@ func (f *_fakeDbContext) Save() error {
 func (f *_fakeDbContext) ClearSensorPoints() error {
 	panic("not implemented")
 }
+
+func (f *_fakeDbContext) Snapshot(fn func() error) error {
+	panic("not implemented")
+}
@@ -0,0 +1,158 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+// Headers describing the original request, only believed from trusted proxies.
+var forwardedHeaders = []string{
+	echo.HeaderXForwardedFor,
+	echo.HeaderXRealIP,
+	echo.HeaderXForwardedProto,
+	echo.HeaderXForwardedProtocol,
+	echo.HeaderXForwardedSsl,
+	echo.HeaderXUrlScheme,
+	"X-Forwarded-Host",
+}
+
+func NewProxyService(cfg *Config) *ProxyService {
+	return &ProxyService{cfg: cfg}
+}
+
+type Config struct {
+	// Path the site is served under, e.g. /airmon, or empty for the root
+	BasePath string
+	// Forwarded client IP, scheme and host headers are only used from these networks
+	TrustedProxies []*net.IPNet
+}
+
+// Lets the site run behind a reverse proxy, optionally under a sub path.
+type ProxyService struct {
+	cfg *Config
+}
+
+// ParseBasePath normalizes the path to have a leading slash and no trailing one, "/" becomes empty.
+func ParseBasePath(path string) (string, error) {
+	path = strings.Trim(strings.TrimSpace(path), "/")
+	if path == "" {
+		return "", nil
+	}
+
+	if strings.ContainsAny(path, "?#\\ ") || strings.Contains(path, "//") {
+		return "", fmt.Errorf("invalid base path %q", path)
+	}
+
+	return "/" + path, nil
+}
+
+func (p *ProxyService) BasePath() string {
+	return p.cfg.BasePath
+}
+
+// Middleware resolves the client from trusted proxy headers and strips the base path
+// before routing, adding it back to redirects to site paths. Use it with echo.Pre.
+func (p *ProxyService) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			p.resolveClient(c.Request())
+
+			if p.cfg.BasePath != "" {
+				p.stripBasePath(c.Request())
+
+				res := c.Response()
+				res.Before(func() {
+					location := res.Header().Get(echo.HeaderLocation)
+					if strings.HasPrefix(location, "/") && !strings.HasPrefix(location, "//") {
+						res.Header().Set(echo.HeaderLocation, p.cfg.BasePath+location)
+					}
+				})
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// Replaces the remote address with the client's when the request came through a trusted proxy,
+// then drops the forwarded headers so echo's RealIP and Scheme can't be spoofed.
+func (p *ProxyService) resolveClient(r *http.Request) {
+	host, port, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if !p.trusted(net.ParseIP(host)) {
+		for _, header := range forwardedHeaders {
+			r.Header.Del(header)
+		}
+		return
+	}
+
+	if client := p.forwardedClient(r.Header); client != "" {
+		r.RemoteAddr = net.JoinHostPort(client, port)
+	}
+
+	if forwardedHost := r.Header.Get("X-Forwarded-Host"); forwardedHost != "" {
+		r.Host = strings.TrimSpace(strings.Split(forwardedHost, ",")[0])
+	}
+
+	r.Header.Del(echo.HeaderXForwardedFor)
+	r.Header.Del(echo.HeaderXRealIP)
+}
+
+// The client is the last address before the chain of trusted proxies.
+func (p *ProxyService) forwardedClient(header http.Header) string {
+	forwarded := strings.Split(header.Get(echo.HeaderXForwardedFor), ",")
+	client := ""
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if ip == nil {
+			break
+		}
+
+		client = ip.String()
+		if !p.trusted(ip) {
+			break
+		}
+	}
+
+	if client == "" {
+		if ip := net.ParseIP(strings.TrimSpace(header.Get(echo.HeaderXRealIP))); ip != nil {
+			client = ip.String()
+		}
+	}
+
+	return client
+}
+
+func (p *ProxyService) trusted(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range p.cfg.TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Proxies may pass the base path on or strip it themselves, so both are accepted.
+func (p *ProxyService) stripBasePath(r *http.Request) {
+	base := p.cfg.BasePath
+	if r.URL.Path != base && !strings.HasPrefix(r.URL.Path, base+"/") {
+		return
+	}
+
+	r.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, base), "/")
+	if r.URL.RawPath != "" {
+		r.URL.RawPath = "/" + strings.TrimPrefix(strings.TrimPrefix(r.URL.RawPath, base), "/")
+	}
+}
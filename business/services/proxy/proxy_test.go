@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"goairmon/business/services/identity"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo"
+)
+
+func _proxyServer(t *testing.T, basePath string, trusted string) *echo.Echo {
+	networks, err := identity.ParseNetworks(trusted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := echo.New()
+	server.Pre(NewProxyService(&Config{BasePath: basePath, TrustedProxies: networks}).Middleware())
+	server.GET("/client", func(c echo.Context) error {
+		return c.String(http.StatusOK, c.RealIP()+" "+c.Scheme()+" "+c.Request().Host)
+	})
+	server.GET("/redirect", func(c echo.Context) error {
+		return c.Redirect(http.StatusSeeOther, "/auth/login")
+	})
+
+	return server
+}
+
+func _get(server *echo.Echo, path string, remoteAddr string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	req.RemoteAddr = remoteAddr
+	for key, val := range headers {
+		req.Header.Set(key, val)
+	}
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestResolveClient(t *testing.T) {
+	server := _proxyServer(t, "", "10.0.0.0/8")
+
+	rows := []struct {
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		{"192.0.2.1:1234", nil, "192.0.2.1 http example.com"},
+		{"192.0.2.1:1234", map[string]string{"X-Forwarded-For": "203.0.113.9", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.com"}, "192.0.2.1 http example.com"},
+		{"192.0.2.1:1234", map[string]string{"X-Real-IP": "203.0.113.9"}, "192.0.2.1 http example.com"},
+		{"10.0.0.2:1234", map[string]string{"X-Forwarded-For": "203.0.113.9", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "air.example.com"}, "203.0.113.9 https air.example.com"},
+		{"10.0.0.2:1234", map[string]string{"X-Forwarded-For": "198.51.100.7, 203.0.113.9, 10.0.0.3"}, "203.0.113.9 http example.com"},
+		{"10.0.0.2:1234", map[string]string{"X-Forwarded-For": "10.0.0.4, 10.0.0.3"}, "10.0.0.4 http example.com"},
+		{"10.0.0.2:1234", map[string]string{"X-Real-IP": "203.0.113.9"}, "203.0.113.9 http example.com"},
+		{"10.0.0.2:1234", nil, "10.0.0.2 http example.com"},
+	}
+
+	for _, row := range rows {
+		rec := _get(server, "/client", row.remoteAddr, row.headers)
+		if rec.Body.String() != row.expected {
+			t.Error("unexpected client", row.remoteAddr, row.headers, rec.Body.String())
+		}
+	}
+}
+
+func TestBasePath(t *testing.T) {
+	server := _proxyServer(t, "/airmon", "")
+
+	for _, path := range []string{"/airmon/client", "/client"} {
+		if rec := _get(server, path, "192.0.2.1:1234", nil); rec.Code != http.StatusOK {
+			t.Error("expected route to be found", path, rec.Code)
+		}
+	}
+
+	if rec := _get(server, "/airmonclient", "192.0.2.1:1234", nil); rec.Code != http.StatusNotFound {
+		t.Error("expected partial prefix not to be stripped", rec.Code)
+	}
+
+	rec := _get(server, "/airmon/redirect", "192.0.2.1:1234", nil)
+	if location := rec.Header().Get("Location"); location != "/airmon/auth/login" {
+		t.Error("expected redirect under base path", location)
+	}
+
+	rec = _get(_proxyServer(t, "", ""), "/redirect", "192.0.2.1:1234", nil)
+	if location := rec.Header().Get("Location"); location != "/auth/login" {
+		t.Error("expected redirect to be left alone", location)
+	}
+}
+
+func TestParseBasePath(t *testing.T) {
+	rows := []struct {
+		path     string
+		expected string
+		ok       bool
+	}{
+		{"", "", true},
+		{"/", "", true},
+		{"airmon", "/airmon", true},
+		{"/airmon/", "/airmon", true},
+		{"/apps/airmon", "/apps/airmon", true},
+		{"/air mon", "", false},
+		{"/airmon?x=1", "", false},
+		{"/apps//airmon", "", false},
+	}
+
+	for _, row := range rows {
+		path, err := ParseBasePath(row.path)
+		if path != row.expected || (err == nil) != row.ok {
+			t.Error("unexpected base path", row.path, path, err)
+		}
+	}
+}
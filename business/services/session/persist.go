@@ -0,0 +1,113 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// Load reads sessions saved by Save, discarding any that expired while the server was down.
+func (s *SessionStore) Load() error {
+	if s.Config.StorageFile == "" {
+		return nil
+	}
+
+	raw, err := ioutil.ReadFile(s.Config.StorageFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read sessions: %s", err)
+	}
+
+	loaded := []*Session{}
+	if err := json.Unmarshal(raw, &loaded); err != nil {
+		return fmt.Errorf("failed to decode sessions: %s", err)
+	}
+
+	sort.SliceStable(loaded, func(a, b int) bool {
+		return loaded[a].StartTime.Before(loaded[b].StartTime)
+	})
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	deadline := s.deadlineTime()
+	for _, sess := range loaded {
+		if sess.Id == "" || sess.IsExpired(deadline) {
+			continue
+		}
+
+		if _, ok := s.sessions[sess.Id]; ok {
+			continue
+		}
+
+		if sess.Values == nil {
+			sess.Values = make(map[string]string)
+		}
+
+		s.sessions[sess.Id] = sess
+		s.idStack.PushBack(sess.Id)
+		s.indexUser(sess)
+	}
+
+	return nil
+}
+
+// Save writes the sessions to Config.StorageFile if they changed since the last save.
+func (s *SessionStore) Save() error {
+	if s.Config.StorageFile == "" {
+		return nil
+	}
+
+	s.saveLock.Lock()
+	defer s.saveLock.Unlock()
+
+	s.lock.Lock()
+	if !s.dirty {
+		s.lock.Unlock()
+		return nil
+	}
+
+	sessions := make([]*Session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess.copy())
+	}
+	s.dirty = false
+	s.lock.Unlock()
+
+	if err := writeSessions(s.Config.StorageFile, sessions); err != nil {
+		s.markDirty()
+		return err
+	}
+
+	return nil
+}
+
+func (s *SessionStore) markDirty() {
+	s.lock.Lock()
+	s.dirty = true
+	s.lock.Unlock()
+}
+
+// Sessions are login tokens so the file is only readable by the server's user.
+func writeSessions(path string, sessions []*Session) error {
+	raw, err := json.Marshal(sessions)
+	if err != nil {
+		return fmt.Errorf("failed to encode sessions: %s", err)
+	}
+
+	tempPath := path + ".tmp"
+	if err := ioutil.WriteFile(tempPath, raw, 0600); err != nil {
+		return fmt.Errorf("failed to write sessions: %s", err)
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to replace sessions: %s", err)
+	}
+
+	return nil
+}
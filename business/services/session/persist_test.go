@@ -0,0 +1,142 @@
+package session
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func _persistSetup(t *testing.T) (*SessionStore, string) {
+	dir, err := ioutil.TempDir("", "goairmon_sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := _sessionSetup()
+	store.Config.StorageFile = filepath.Join(dir, "sessions.json")
+
+	return store, dir
+}
+
+func TestSaveAndLoadSessions(t *testing.T) {
+	store, dir := _persistSetup(t)
+	defer os.RemoveAll(dir)
+
+	timePro := (store.timeProvider).(*mockTimeProvider)
+	startNow := time.Unix(503539200, 0)
+	timePro.NowCallback = func() time.Time {
+		return startNow
+	}
+
+	if err := store.Load(); err != nil {
+		t.Error("expected missing file to be ignored", err)
+	}
+
+	store.NewOrExisting("old_id")
+	startNow = startNow.Add(store.Config.expirationDuration() / 2)
+	store.NewOrExisting("first_id")
+	store.NewOrExisting("second_id")
+
+	if err := store.SetUser("first_id", "first_user", "127.0.0.1", "agent"); err != nil {
+		t.Error(err)
+	}
+
+	if err := store.SetUser("not_found", "first_user", "127.0.0.1", "agent"); err == nil {
+		t.Error("expected error")
+	}
+
+	info, err := os.Stat(store.Config.StorageFile)
+	if err != nil {
+		t.Fatal("expected sessions to be saved on change", err)
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Error("unexpected sessions file mode", info.Mode())
+	}
+
+	startNow = startNow.Add(store.Config.expirationDuration()/2 + time.Minute)
+
+	loaded := NewSessionStore(store.Config)
+	loaded.timeProvider = timePro
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	_assertSessionStore(loaded, 2, "first_id", t)
+	if _, ok := loaded.sessions["old_id"]; ok {
+		t.Error("expected expired session to be discarded")
+	}
+
+	sess, err := loaded.Find("first_id")
+	if err != nil || sess.UserId != "first_user" || sess.UserAgent != "agent" {
+		t.Error("expected session user to be loaded", sess, err)
+	}
+
+	if len(loaded.FindByUser("first_user")) != 1 {
+		t.Error("expected user index to be rebuilt")
+	}
+
+	if err := loaded.Remove("second_id"); err != nil {
+		t.Error(err)
+	}
+
+	reloaded := NewSessionStore(store.Config)
+	reloaded.timeProvider = timePro
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	_assertSessionStore(reloaded, 1, "first_id", t)
+}
+
+func TestSaveOnlyWhenChanged(t *testing.T) {
+	store, dir := _persistSetup(t)
+	defer os.RemoveAll(dir)
+
+	if err := store.Save(); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := os.Stat(store.Config.StorageFile); !os.IsNotExist(err) {
+		t.Error("expected nothing to be saved")
+	}
+
+	store.NewOrExisting("first_id")
+	os.Remove(store.Config.StorageFile)
+
+	if err := store.Save(); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := os.Stat(store.Config.StorageFile); !os.IsNotExist(err) {
+		t.Error("expected unchanged sessions not to be saved")
+	}
+
+	store.Find("first_id")
+	if err := store.Save(); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := os.Stat(store.Config.StorageFile); err != nil {
+		t.Error("expected refreshed session to be saved", err)
+	}
+}
+
+func TestLoadInvalidSessions(t *testing.T) {
+	store, dir := _persistSetup(t)
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(store.Config.StorageFile, []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Load(); err == nil {
+		t.Error("expected error")
+	}
+
+	if len(store.sessions) != 0 {
+		t.Error("expected no sessions")
+	}
+}
@@ -2,23 +2,34 @@ package session
 
 import (
 	"fmt"
+	"goairmon/business/services/applog"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/labstack/gommon/log"
+	"github.com/labstack/echo"
 )
 
 type Config struct {
 	ExpirationSecs int
 	GCDelaySeconds int
+	// Sessions are saved here so logins survive restarts, left empty to keep them in memory only
+	StorageFile      string
+	SaveDelaySeconds int
+	Logger           echo.Logger
 }
 
 func NewSessionStore(cfg Config) *SessionStore {
+	if cfg.Logger == nil {
+		cfg.Logger = applog.Default().Subsystem("auth")
+	}
+
 	store := SessionStore{
 		Config:       cfg,
 		startedGc:    false,
 		sessions:     make(map[string]*Session),
+		userSessions: make(map[string]map[string]bool),
 		idStack:      NewIdStack(),
 		lock:         sync.Mutex{},
 		timeProvider: systemTime{},
@@ -31,8 +42,11 @@ type SessionStore struct {
 	Config       Config
 	startedGc    bool
 	sessions     map[string]*Session
+	userSessions map[string]map[string]bool
 	idStack      *IdStack
 	lock         sync.Mutex
+	saveLock     sync.Mutex
+	dirty        bool
 	timeProvider TimeProvider
 }
 
@@ -44,6 +58,14 @@ func (c *Config) gcDelay() time.Duration {
 	return time.Duration(c.GCDelaySeconds) * time.Second
 }
 
+func (c *Config) saveDelay() time.Duration {
+	if c.SaveDelaySeconds <= 0 {
+		return time.Minute
+	}
+
+	return time.Duration(c.SaveDelaySeconds) * time.Second
+}
+
 // Allows injection of mock timing for tests but intended to use traditional system time functions.
 type TimeProvider interface {
 	Now() time.Time
@@ -62,9 +84,24 @@ func (p systemTime) Tick(duration time.Duration) <-chan time.Time {
 }
 
 type Session struct {
-	Id        string
-	StartTime time.Time
-	Values    map[string]string
+	Id         string
+	UserId     string
+	RemoteAddr string
+	UserAgent  string
+	StartTime  time.Time
+	Values     map[string]string
+}
+
+func (s *Session) copy() *Session {
+	values := make(map[string]string, len(s.Values))
+	for key, val := range s.Values {
+		values[key] = val
+	}
+
+	copied := *s
+	copied.Values = values
+
+	return &copied
 }
 
 func (s *Session) IsExpired(deadline time.Time) bool {
@@ -78,9 +115,20 @@ func (s *SessionStore) StartGC() error {
 
 	s.startedGc = true
 
+	var saveTick <-chan time.Time
+	if s.Config.StorageFile != "" {
+		saveTick = s.timeProvider.Tick(s.Config.saveDelay())
+	}
+
 	go func() {
-		for range s.timeProvider.Tick(s.Config.gcDelay()) {
-			s.removeExpiredSessions()
+		gcTick := s.timeProvider.Tick(s.Config.gcDelay())
+		for {
+			select {
+			case <-gcTick:
+				s.removeExpiredSessions()
+			case <-saveTick:
+				s.saveOrLog()
+			}
 		}
 	}()
 
@@ -88,6 +136,8 @@ func (s *SessionStore) StartGC() error {
 }
 
 func (s *SessionStore) NewOrExisting(sessionId string) (*Session, error) {
+	defer s.saveOrLog()
+
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
@@ -104,10 +154,123 @@ func (s *SessionStore) NewOrExisting(sessionId string) (*Session, error) {
 	sess.StartTime = s.timeProvider.Now()
 	s.sessions[sessionId] = sess
 	s.idStack.PushBack(sessionId)
+	s.dirty = true
 
 	return sess, nil
 }
 
+// SetUser logs the session in as the user and saves it straight away.
+func (s *SessionStore) SetUser(sessionId string, userId string, remoteAddr string, userAgent string) error {
+	s.lock.Lock()
+	sess, ok := s.sessions[sessionId]
+	if ok {
+		s.unindexUser(sess)
+		sess.UserId = userId
+		sess.RemoteAddr = remoteAddr
+		sess.UserAgent = userAgent
+		s.indexUser(sess)
+		s.dirty = true
+	}
+	s.lock.Unlock()
+
+	if !ok {
+		return fmt.Errorf("session not found")
+	}
+
+	return s.Save()
+}
+
+// SetClient records where the session was last seen from.
+func (s *SessionStore) SetClient(sessionId string, remoteAddr string, userAgent string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	sess, ok := s.sessions[sessionId]
+	if ok && (sess.RemoteAddr != remoteAddr || sess.UserAgent != userAgent) {
+		sess.RemoteAddr = remoteAddr
+		sess.UserAgent = userAgent
+		s.dirty = true
+	}
+}
+
+// SetValue keeps a value with the session on the server, an empty value removes it.
+func (s *SessionStore) SetValue(sessionId string, key string, value string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	sess, ok := s.sessions[sessionId]
+	if !ok {
+		return fmt.Errorf("session not found")
+	}
+
+	if value == "" {
+		delete(sess.Values, key)
+	} else {
+		sess.Values[key] = value
+	}
+	s.dirty = true
+
+	return nil
+}
+
+// Value returns a value set with SetValue, or an empty string.
+func (s *SessionStore) Value(sessionId string, key string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if sess, ok := s.sessions[sessionId]; ok {
+		return sess.Values[key]
+	}
+
+	return ""
+}
+
+// FindByUser returns copies of the user's sessions, most recently seen first.
+func (s *SessionStore) FindByUser(userId string) []*Session {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	found := []*Session{}
+	deadline := s.deadlineTime()
+	for id := range s.userSessions[userId] {
+		if sess, ok := s.sessions[id]; ok && !sess.IsExpired(deadline) {
+			found = append(found, sess.copy())
+		}
+	}
+
+	sort.Slice(found, func(a, b int) bool {
+		return found[a].StartTime.After(found[b].StartTime)
+	})
+
+	return found
+}
+
+// RemoveUserSessions removes all of the user's sessions except keepIds, returning how many were removed.
+func (s *SessionStore) RemoveUserSessions(userId string, keepIds ...string) int {
+	defer s.saveOrLog()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	keep := map[string]bool{}
+	for _, id := range keepIds {
+		keep[id] = true
+	}
+
+	removed := 0
+	for id := range s.userSessions[userId] {
+		if keep[id] {
+			continue
+		}
+
+		s.deleteSession(id)
+		_ = s.idStack.Remove(id)
+		removed++
+	}
+
+	return removed
+}
+
 func (s *SessionStore) Find(sessionId string) (*Session, error) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
@@ -117,12 +280,15 @@ func (s *SessionStore) Find(sessionId string) (*Session, error) {
 		return existing, fmt.Errorf("session not found")
 	}
 
+	s.dirty = true
+
 	if existing.IsExpired(s.deadlineTime()) {
-		delete(s.sessions, sessionId)
+		s.deleteSession(sessionId)
 		_ = s.idStack.Remove(sessionId)
 		return nil, fmt.Errorf("session not found")
 	}
 
+	// Refreshed start times are saved on the next save tick rather than every request
 	_ = s.idStack.Remove(sessionId)
 	s.idStack.PushBack(sessionId)
 	existing.StartTime = s.timeProvider.Now()
@@ -131,13 +297,15 @@ func (s *SessionStore) Find(sessionId string) (*Session, error) {
 }
 
 func (s *SessionStore) Remove(sessionId string) error {
+	defer s.saveOrLog()
+
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
 	errors := []string{}
 	_, ok := s.sessions[sessionId]
 	if ok {
-		delete(s.sessions, sessionId)
+		s.deleteSession(sessionId)
 	} else {
 		errors = append(errors, "no session found")
 	}
@@ -168,7 +336,7 @@ func (s *SessionStore) removeExpiredSessions() {
 		id := s.idStack.Peak()
 		sess, ok := s.sessions[id]
 		if !ok {
-			log.Errorf("stacked id %s has no corresponding session", id)
+			s.Config.Logger.Errorf("stacked id %s has no corresponding session", id)
 			_, _ = s.idStack.Pop()
 			continue
 		}
@@ -177,11 +345,54 @@ func (s *SessionStore) removeExpiredSessions() {
 			return
 		}
 
-		delete(s.sessions, id)
+		s.deleteSession(id)
 		_, _ = s.idStack.Pop()
 	}
 }
 
+// Removes the session and its user index entry, the caller handles the id stack.
+func (s *SessionStore) deleteSession(sessionId string) {
+	if sess, ok := s.sessions[sessionId]; ok {
+		s.unindexUser(sess)
+		delete(s.sessions, sessionId)
+		s.dirty = true
+	}
+}
+
+func (s *SessionStore) indexUser(sess *Session) {
+	if sess.UserId == "" {
+		return
+	}
+
+	if s.userSessions == nil {
+		s.userSessions = make(map[string]map[string]bool)
+	}
+
+	if s.userSessions[sess.UserId] == nil {
+		s.userSessions[sess.UserId] = make(map[string]bool)
+	}
+
+	s.userSessions[sess.UserId][sess.Id] = true
+}
+
+func (s *SessionStore) unindexUser(sess *Session) {
+	ids, ok := s.userSessions[sess.UserId]
+	if !ok {
+		return
+	}
+
+	delete(ids, sess.Id)
+	if len(ids) == 0 {
+		delete(s.userSessions, sess.UserId)
+	}
+}
+
+func (s *SessionStore) saveOrLog() {
+	if err := s.Save(); err != nil {
+		s.Config.Logger.Error("failed to save sessions", err)
+	}
+}
+
 func (s *SessionStore) deadlineTime() time.Time {
 	return s.timeProvider.Now().Add(-s.Config.expirationDuration())
 }
@@ -300,3 +300,85 @@ func (t mockTimeProvider) Now() time.Time {
 func (t mockTimeProvider) Tick(duration time.Duration) <-chan time.Time {
 	return t.TickChannel
 }
+
+func TestUserSessions(t *testing.T) {
+	store := _sessionSetup()
+	timePro := (store.timeProvider).(*mockTimeProvider)
+	startNow := time.Unix(503539200, 0)
+	timePro.NowCallback = func() time.Time {
+		return startNow
+	}
+
+	for _, id := range []string{"first_id", "second_id", "third_id", "other_id"} {
+		store.NewOrExisting(id)
+		startNow = startNow.Add(time.Minute)
+	}
+
+	store.SetUser("first_id", "user", "10.0.0.1", "first agent")
+	store.SetUser("second_id", "user", "10.0.0.2", "second agent")
+	store.SetUser("third_id", "user", "10.0.0.3", "third agent")
+	store.SetUser("other_id", "other_user", "10.0.0.4", "other agent")
+
+	sessions := store.FindByUser("user")
+	if len(sessions) != 3 || sessions[0].Id != "third_id" || sessions[2].Id != "first_id" {
+		t.Fatal("expected user sessions newest first", sessions)
+	}
+
+	sessions[0].UserId = "changed"
+	if store.sessions["third_id"].UserId != "user" {
+		t.Error("expected copies of sessions")
+	}
+
+	store.SetClient("first_id", "10.0.0.5", "new agent")
+	if store.sessions["first_id"].RemoteAddr != "10.0.0.5" {
+		t.Error("expected client to be updated")
+	}
+
+	store.Remove("second_id")
+	if len(store.FindByUser("user")) != 2 {
+		t.Error("expected removed session to leave the index")
+	}
+
+	if removed := store.RemoveUserSessions("user", "first_id"); removed != 1 {
+		t.Error("unexpected removed count", removed)
+	}
+
+	_assertSessionStore(store, 2, "first_id", t)
+
+	if removed := store.RemoveUserSessions("user"); removed != 1 {
+		t.Error("unexpected removed count", removed)
+	}
+
+	_assertSessionStore(store, 1, "other_id", t)
+	if len(store.FindByUser("user")) != 0 || len(store.userSessions) != 1 {
+		t.Error("expected user to have no sessions", store.userSessions)
+	}
+
+	startNow = startNow.Add(store.Config.expirationDuration() + time.Minute)
+	store.removeExpiredSessions()
+	if len(store.userSessions) != 0 {
+		t.Error("expected expired sessions to leave the index")
+	}
+}
+
+func TestSessionValues(t *testing.T) {
+	store := _sessionSetup()
+	store.NewOrExisting("first_id")
+
+	if err := store.SetValue("first_id", "key", "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	if value := store.Value("first_id", "key"); value != "value" {
+		t.Error("unexpected value", value)
+	}
+
+	store.SetValue("first_id", "key", "")
+	if _, ok := store.sessions["first_id"].Values["key"]; ok {
+		t.Error("expected empty value to be removed")
+	}
+
+	if err := store.SetValue("missing_id", "key", "value"); err == nil || store.Value("missing_id", "key") != "" {
+		t.Error("expected missing session to fail")
+	}
+}
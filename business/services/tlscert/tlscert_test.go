@@ -0,0 +1,88 @@
+package tlscert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestSelfSignedPersists(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goairmon_tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := &Config{StoragePath: dir, Hosts: []string{"airmon.lan", "192.0.2.10"}}
+	certFile, keyFile, err := Files(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, host := range []string{"localhost", "airmon.lan", "192.0.2.10", "127.0.0.1"} {
+		if err := cert.VerifyHostname(host); err != nil {
+			t.Error("expected certificate to cover host", host, err)
+		}
+	}
+
+	if info, _ := os.Stat(keyFile); info == nil || info.Mode().Perm() != 0600 {
+		t.Error("expected key to only be readable by the owner", info)
+	}
+
+	if _, _, err := Files(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	again, _ := tls.LoadX509KeyPair(certFile, keyFile)
+	if string(again.Certificate[0]) != string(pair.Certificate[0]) {
+		t.Error("expected certificate to be reused")
+	}
+
+	if _, _, err := Files(&Config{CertFile: certFile}); err == nil {
+		t.Error("expected missing key to fail")
+	}
+
+	if _, _, err := Files(&Config{CertFile: keyFile, KeyFile: keyFile}); err == nil {
+		t.Error("expected invalid pair to fail")
+	}
+
+	if c, k, err := Files(&Config{CertFile: certFile, KeyFile: keyFile}); err != nil || c != certFile || k != keyFile {
+		t.Error("expected provided pair to be used", c, k, err)
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	rows := []struct {
+		address  string
+		host     string
+		expected string
+	}{
+		{":443", "airmon.lan", "https://airmon.lan/auth/login?next=1"},
+		{":3443", "airmon.lan:3000", "https://airmon.lan:3443/auth/login?next=1"},
+		{"0.0.0.0:8443", "192.0.2.10", "https://192.0.2.10:8443/auth/login?next=1"},
+	}
+
+	for _, row := range rows {
+		req := httptest.NewRequest("GET", "/auth/login?next=1", nil)
+		req.Host = row.host
+		rec := httptest.NewRecorder()
+		RedirectHandler(row.address).ServeHTTP(rec, req)
+
+		if location := rec.Header().Get("Location"); rec.Code != 301 || location != row.expected {
+			t.Error("unexpected redirect", row.address, row.host, rec.Code, location)
+		}
+	}
+}
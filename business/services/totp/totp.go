@@ -0,0 +1,196 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"goairmon/business/data/models"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	Digits = 6
+	Period = 30 * time.Second
+	// Codes from one step either side are accepted to allow for clock drift
+	Skew              = 1
+	RecoveryCodeCount = 10
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret makes a random base32 secret to share with an authenticator app.
+func GenerateSecret() (string, error) {
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %s", err)
+	}
+
+	return encoding.EncodeToString(raw), nil
+}
+
+// URI is the otpauth URI authenticator apps scan to add the account.
+func URI(issuer string, account string, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(Digits))
+	query.Set("period", fmt.Sprint(int(Period.Seconds())))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// Code is the RFC 6238 code for the secret at time t.
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return hotp(key, uint64(step(t)), Digits), nil
+}
+
+// Check looks for the code within Skew steps of t, returning the step it matched.
+func Check(secret string, code string, t time.Time) (int64, bool) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) != Digits {
+		return 0, false
+	}
+
+	current := step(t)
+	for offset := int64(-Skew); offset <= Skew; offset++ {
+		expected := hotp(key, uint64(current+offset), Digits)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return current + offset, true
+		}
+	}
+
+	return 0, false
+}
+
+// Verify checks a code or unused recovery code for the user, refusing codes that were already used.
+// The user is updated to record what was used so the caller must save it on success.
+func Verify(user *models.User, code string, t time.Time) bool {
+	if user.TotpSecret == "" {
+		return false
+	}
+
+	if matched, ok := Check(user.TotpSecret, code, t); ok {
+		if matched <= user.TotpLastStep {
+			return false
+		}
+
+		user.TotpLastStep = matched
+		return true
+	}
+
+	hashed := HashRecoveryCode(code)
+	for i, recoveryCode := range user.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(recoveryCode), []byte(hashed)) == 1 {
+			user.RecoveryCodes = append(user.RecoveryCodes[:i:i], user.RecoveryCodes[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Enable turns on two factor for the user, returning recovery codes to show them once.
+func Enable(user *models.User, secret string, t time.Time) ([]string, error) {
+	if _, err := decodeSecret(secret); err != nil {
+		return nil, err
+	}
+
+	codes, err := ResetRecoveryCodes(user)
+	if err != nil {
+		return nil, err
+	}
+
+	user.TotpSecret = secret
+	user.TotpLastStep = step(t)
+
+	return codes, nil
+}
+
+// Disable turns off two factor and removes the user's recovery codes.
+func Disable(user *models.User) {
+	user.TotpSecret = ""
+	user.TotpLastStep = 0
+	user.RecoveryCodes = nil
+}
+
+// ResetRecoveryCodes replaces the user's recovery codes, storing only their hashes.
+func ResetRecoveryCodes(user *models.User) ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %s", err)
+		}
+
+		encoded := strings.ToLower(encoding.EncodeToString(raw))
+		codes[i] = encoded[:4] + "-" + encoded[4:]
+		hashes[i] = HashRecoveryCode(codes[i])
+	}
+
+	user.RecoveryCodes = hashes
+
+	return codes, nil
+}
+
+// HashRecoveryCode normalizes and hashes a code, they're random enough not to need bcrypt.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.Replace(strings.TrimSpace(code), "-", "", -1))
+	sum := sha256.Sum256([]byte(normalized))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("invalid secret")
+	}
+
+	return key, nil
+}
+
+func step(t time.Time) int64 {
+	return t.Unix() / int64(Period.Seconds())
+}
+
+// RFC 4226 HOTP with HMAC-SHA1.
+func hotp(key []byte, counter uint64, digits int) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
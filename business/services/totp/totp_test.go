@@ -0,0 +1,155 @@
+package totp
+
+import (
+	"goairmon/business/data/models"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The RFC test key "12345678901234567890" in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestHotpRfc4226(t *testing.T) {
+	expected := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+
+	for counter, code := range expected {
+		if actual := hotp([]byte("12345678901234567890"), uint64(counter), 6); actual != code {
+			t.Error("unexpected hotp", counter, actual, code)
+		}
+	}
+}
+
+func TestTotpRfc6238(t *testing.T) {
+	rows := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	key, _ := decodeSecret(rfcSecret)
+	for _, row := range rows {
+		at := time.Unix(row.unix, 0)
+		if actual := hotp(key, uint64(step(at)), 8); actual != row.code {
+			t.Error("unexpected totp", row.unix, actual, row.code)
+		}
+
+		if actual, _ := Code(rfcSecret, at); actual != row.code[2:] {
+			t.Error("unexpected 6 digit totp", row.unix, actual, row.code[2:])
+		}
+	}
+}
+
+func TestCheckAllowsSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, _ := Code(rfcSecret, now)
+
+	rows := []struct {
+		at time.Time
+		ok bool
+	}{
+		{now, true},
+		{now.Add(-Period), true},
+		{now.Add(Period), true},
+		{now.Add(-2 * Period), false},
+		{now.Add(2 * Period), false},
+	}
+
+	for _, row := range rows {
+		if _, ok := Check(rfcSecret, code, row.at); ok != row.ok {
+			t.Error("unexpected check result", row.at, ok)
+		}
+	}
+
+	if _, ok := Check(rfcSecret, " "+code+" ", now); !ok {
+		t.Error("expected whitespace to be ignored")
+	}
+
+	if _, ok := Check("not base32!", code, now); ok {
+		t.Error("expected invalid secret to fail")
+	}
+}
+
+func TestVerifyRejectsReuse(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	user := &models.User{}
+
+	if Verify(user, "123456", now) {
+		t.Error("expected verify to fail without two factor")
+	}
+
+	codes, err := Enable(user, rfcSecret, now.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(codes) != RecoveryCodeCount || len(user.RecoveryCodes) != RecoveryCodeCount || !user.TwoFactorEnabled() {
+		t.Fatal("expected two factor to be enabled", codes, user)
+	}
+
+	code, _ := Code(rfcSecret, now)
+	if !Verify(user, code, now) {
+		t.Error("expected code to verify")
+	}
+
+	if Verify(user, code, now) {
+		t.Error("expected used code to be rejected")
+	}
+
+	previous, _ := Code(rfcSecret, now.Add(-Period))
+	if Verify(user, previous, now) {
+		t.Error("expected code older than the last used to be rejected")
+	}
+
+	if !Verify(user, strings.ToUpper(codes[0]), now) {
+		t.Error("expected recovery code to verify")
+	}
+
+	if Verify(user, codes[0], now) || len(user.RecoveryCodes) != RecoveryCodeCount-1 {
+		t.Error("expected recovery code to be used up")
+	}
+
+	if !Verify(user, strings.Replace(codes[1], "-", "", -1), now) {
+		t.Error("expected recovery code without dash to verify")
+	}
+
+	Disable(user)
+	if user.TwoFactorEnabled() || len(user.RecoveryCodes) != 0 || Verify(user, codes[2], now) {
+		t.Error("expected two factor to be disabled")
+	}
+}
+
+func TestGenerateSecretAndURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := decodeSecret(secret); err != nil || len(secret) != 32 {
+		t.Error("unexpected secret", secret, err)
+	}
+
+	uri, err := url.Parse(URI("GoAirMon", "some user", secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/GoAirMon:some user" {
+		t.Error("unexpected uri", uri)
+	}
+
+	if uri.Query().Get("secret") != secret || uri.Query().Get("issuer") != "GoAirMon" || uri.Query().Get("digits") != "6" {
+		t.Error("unexpected uri query", uri.Query())
+	}
+
+	if _, err := Enable(&models.User{}, "not base32!", time.Now()); err == nil {
+		t.Error("expected error")
+	}
+}
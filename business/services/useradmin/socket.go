@@ -0,0 +1,201 @@
+package useradmin
+
+import (
+	"encoding/json"
+	"fmt"
+	"goairmon/business/data/context"
+	"goairmon/business/services/audit"
+	"goairmon/business/services/passpolicy"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+const socketTimeout = 10 * time.Second
+
+func NewSocketServer(socketPath string, dbContext context.DbContext, sessions SessionRevoker, policy *passpolicy.Policy, auditLog *audit.AuditLog, logger echo.Logger) *SocketServer {
+	return &SocketServer{
+		socketPath: socketPath,
+		dbContext:  dbContext,
+		sessions:   sessions,
+		policy:     policy,
+		auditLog:   auditLog,
+		logger:     logger,
+	}
+}
+
+// Accepts user admin requests from the CLI on a unix socket so the running
+// server doesn't overwrite changes when it next saves.
+type SocketServer struct {
+	socketPath string
+	dbContext  context.DbContext
+	sessions   SessionRevoker
+	policy     *passpolicy.Policy
+	auditLog   *audit.AuditLog
+	logger     echo.Logger
+	listener   net.Listener
+	lock       sync.Mutex
+}
+
+func (s *SocketServer) Start() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.listener != nil {
+		return fmt.Errorf("socket already started")
+	}
+
+	if conn, err := net.DialTimeout("unix", s.socketPath, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("admin socket %s is already in use", s.socketPath)
+	}
+
+	listener, err := listenPrivate(s.socketPath)
+	if err != nil {
+		return err
+	}
+
+	s.listener = listener
+	go s.acceptRoutine(listener)
+
+	return nil
+}
+
+func (s *SocketServer) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.listener == nil {
+		return fmt.Errorf("socket already closed")
+	}
+
+	err := s.listener.Close()
+	s.listener = nil
+	os.Remove(s.socketPath)
+
+	return err
+}
+
+// Binds the socket in a directory only we can open and moves it into place once it's 0600,
+// so other users can't connect while its permissions are still the umask's.
+func listenPrivate(socketPath string) (*net.UnixListener, error) {
+	dir, err := ioutil.TempDir(filepath.Dir(socketPath), ".goairmon_socket_")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open admin socket: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	privatePath := filepath.Join(dir, "admin.sock")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: privatePath, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open admin socket: %s", err)
+	}
+	// The socket is moved, so Close removes it from its final path instead
+	listener.SetUnlinkOnClose(false)
+
+	if err := os.Chmod(privatePath, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to secure admin socket: %s", err)
+	}
+
+	os.Remove(socketPath)
+	if err := os.Rename(privatePath, socketPath); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to open admin socket: %s", err)
+	}
+
+	return listener, nil
+}
+
+func (s *SocketServer) acceptRoutine(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.handle(conn)
+	}
+}
+
+func (s *SocketServer) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(socketTimeout))
+
+	req := &Request{}
+	if err := json.NewDecoder(conn).Decode(req); err != nil {
+		json.NewEncoder(conn).Encode(&Response{Error: "invalid request"})
+		return
+	}
+
+	if req.Command == CommandAudit {
+		s.recordAudit(conn, req.Audit)
+		return
+	}
+
+	res := Execute(s.dbContext, s.sessions, s.policy, req)
+	if res.Error == "" && req.Command != CommandList {
+		s.logger.Infof("admin socket: %s", res.Message)
+
+		if err := s.auditLog.Record(AuditEntry(req, res)); err != nil {
+			s.logger.Error(err)
+		}
+	}
+
+	if err := json.NewEncoder(conn).Encode(res); err != nil {
+		s.logger.Error("failed to write admin socket response", err)
+	}
+}
+
+func (s *SocketServer) recordAudit(conn net.Conn, entry *audit.Entry) {
+	res := &Response{}
+	if entry == nil {
+		res.Error = "an audit entry must be provided"
+	} else if err := s.auditLog.Record(*entry); err != nil {
+		res.Error = err.Error()
+	}
+
+	if err := json.NewEncoder(conn).Encode(res); err != nil {
+		s.logger.Error("failed to write admin socket response", err)
+	}
+}
+
+// Dial connects to a running server's admin socket, failing if the server isn't up.
+func Dial(socketPath string) (*Client, error) {
+	conn, err := net.DialTimeout("unix", socketPath, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	conn.Close()
+
+	return &Client{socketPath: socketPath}, nil
+}
+
+type Client struct {
+	socketPath string
+}
+
+func (c *Client) Send(req *Request) (*Response, error) {
+	conn, err := net.DialTimeout("unix", c.socketPath, time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to admin socket: %s", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(socketTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request: %s", err)
+	}
+
+	res := &Response{}
+	if err := json.NewDecoder(conn).Decode(res); err != nil {
+		return nil, fmt.Errorf("failed to read response: %s", err)
+	}
+
+	return res, nil
+}
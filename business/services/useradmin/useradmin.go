@@ -0,0 +1,247 @@
+package useradmin
+
+import (
+	"fmt"
+	"goairmon/business/data/context"
+	"goairmon/business/data/models"
+	"goairmon/business/services/audit"
+	"goairmon/business/services/passpolicy"
+	"goairmon/business/services/totp"
+	"strings"
+	"time"
+)
+
+const (
+	CommandAdd    = "add"
+	CommandRemove = "remove"
+	CommandList   = "list"
+	CommandPasswd = "passwd"
+	CommandRename = "rename"
+	CommandLock   = "lock"
+	CommandUnlock = "unlock"
+	CommandRole   = "role"
+	// Turns off two factor for a user who lost their device and recovery codes
+	CommandReset2fa = "reset-2fa"
+	// Records the request's audit entry, so the CLI doesn't rotate the server's audit log under it
+	CommandAudit = "audit"
+)
+
+// SessionRevoker ends a user's logins when their password changes or they're removed.
+type SessionRevoker interface {
+	RemoveUserSessions(userID string, keepIds ...string) int
+}
+
+type Request struct {
+	Command     string       `json:"command"`
+	Username    string       `json:"username"`
+	NewUsername string       `json:"new_username,omitempty"`
+	Password    string       `json:"password,omitempty"`
+	Timezone    string       `json:"timezone,omitempty"`
+	Role        string       `json:"role,omitempty"`
+	Audit       *audit.Entry `json:"audit,omitempty"`
+}
+
+type Response struct {
+	Error   string      `json:"error,omitempty"`
+	Message string      `json:"message,omitempty"`
+	Users   []*UserInfo `json:"users,omitempty"`
+}
+
+type UserInfo struct {
+	Username  string    `json:"username"`
+	Timezone  string    `json:"timezone"`
+	LastLogin time.Time `json:"last_login"`
+	Locked    bool      `json:"locked"`
+	Role      string    `json:"role"`
+	TwoFactor bool      `json:"two_factor"`
+}
+
+// Execute runs the request against the DbContext and saves any changes,
+// logging the user out of sessions if their password changed or they were removed.
+// New passwords are checked against the policy, or passpolicy.DefaultPolicy if it's nil.
+func Execute(dbContext context.DbContext, sessions SessionRevoker, policy *passpolicy.Policy, req *Request) *Response {
+	if policy == nil {
+		policy = passpolicy.DefaultPolicy()
+	}
+
+	message, users, err := execute(dbContext, sessions, policy, req)
+	if err != nil {
+		return &Response{Error: err.Error()}
+	}
+
+	if req.Command != CommandList {
+		if err := dbContext.Save(); err != nil {
+			return &Response{Error: fmt.Sprintf("failed to save: %s", err)}
+		}
+	}
+
+	return &Response{Message: message, Users: users}
+}
+
+// AuditEntry records a successful change made from the CLI.
+func AuditEntry(req *Request, res *Response) audit.Entry {
+	event := audit.EventUserChanged
+	if req.Command == CommandAdd {
+		event = audit.EventUserAdded
+	} else if req.Command == CommandRemove {
+		event = audit.EventUserRemoved
+	}
+
+	return audit.Entry{Event: event, Actor: "cli", Details: res.Message}
+}
+
+func execute(dbContext context.DbContext, sessions SessionRevoker, policy *passpolicy.Policy, req *Request) (string, []*UserInfo, error) {
+	if req.Command == CommandList {
+		users, err := dbContext.GetUsers()
+		if err != nil {
+			return "", nil, err
+		}
+
+		infos := make([]*UserInfo, len(users))
+		for i, user := range users {
+			infos[i] = &UserInfo{
+				Username:  user.Username,
+				Timezone:  user.Timezone,
+				LastLogin: user.LastLogin,
+				Locked:    user.Locked,
+				Role:      user.Role,
+				TwoFactor: user.TwoFactorEnabled(),
+			}
+		}
+
+		return "", infos, nil
+	}
+
+	if req.Command == CommandAdd {
+		if err := ValidateUsername(dbContext, req.Username); err != nil {
+			return "", nil, err
+		}
+
+		user := &models.User{Username: req.Username, Role: models.RoleViewer}
+		if req.Role != "" {
+			if err := setRole(user, req.Role); err != nil {
+				return "", nil, err
+			}
+		}
+
+		if err := policy.SetPassword(user, req.Password); err != nil {
+			return "", nil, err
+		}
+
+		if err := setTimezone(user, req.Timezone); err != nil {
+			return "", nil, err
+		}
+
+		if err := dbContext.CreateOrUpdateUser(user); err != nil {
+			return "", nil, fmt.Errorf("failed to create user: %s", err)
+		}
+
+		return fmt.Sprintf("Added %s %s", user.Role, user.Username), nil, nil
+	}
+
+	user, err := dbContext.FindUserByName(req.Username)
+	if err != nil {
+		return "", nil, fmt.Errorf("user %s not found", req.Username)
+	}
+
+	var message string
+	switch req.Command {
+	case CommandRemove:
+		if err := dbContext.DeleteUser(user.ID); err != nil {
+			return "", nil, fmt.Errorf("failed to remove user: %s", err)
+		}
+		revokeSessions(sessions, user)
+
+		return fmt.Sprintf("Removed user %s", user.Username), nil, nil
+	case CommandPasswd:
+		if err := policy.SetPassword(user, req.Password); err != nil {
+			return "", nil, err
+		}
+		message = fmt.Sprintf("Changed password for %s", user.Username)
+	case CommandRename:
+		if err := ValidateUsername(dbContext, req.NewUsername); err != nil {
+			return "", nil, err
+		}
+		message = fmt.Sprintf("Renamed %s to %s", user.Username, req.NewUsername)
+		user.Username = req.NewUsername
+	case CommandLock:
+		user.Locked = true
+		message = fmt.Sprintf("Locked %s", user.Username)
+	case CommandUnlock:
+		user.Locked = false
+		message = fmt.Sprintf("Unlocked %s", user.Username)
+	case CommandRole:
+		if err := setRole(user, req.Role); err != nil {
+			return "", nil, err
+		}
+		message = fmt.Sprintf("Made %s %s", user.Username, user.Role)
+	case CommandReset2fa:
+		if !user.TwoFactorEnabled() {
+			return "", nil, fmt.Errorf("%s doesn't have two factor enabled", user.Username)
+		}
+		totp.Disable(user)
+		message = fmt.Sprintf("Turned off two factor for %s", user.Username)
+	default:
+		return "", nil, fmt.Errorf("unknown command %q", req.Command)
+	}
+
+	if err := dbContext.CreateOrUpdateUser(user); err != nil {
+		return "", nil, fmt.Errorf("failed to update user: %s", err)
+	}
+
+	if req.Command == CommandPasswd {
+		revokeSessions(sessions, user)
+	}
+
+	return message, nil, nil
+}
+
+func revokeSessions(sessions SessionRevoker, user *models.User) {
+	if sessions != nil {
+		sessions.RemoveUserSessions(user.ID.String())
+	}
+}
+
+// ValidateUsername checks a new username is long enough and not taken.
+func ValidateUsername(dbContext context.DbContext, username string) error {
+	if len(username) < 6 {
+		return fmt.Errorf("username must be atleast 6 characters")
+	}
+
+	if _, err := dbContext.FindUserByName(username); err == nil {
+		return fmt.Errorf("username %s is taken", username)
+	}
+
+	return nil
+}
+
+func setTimezone(user *models.User, timezone string) error {
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return fmt.Errorf("invalid timezone: %s", err)
+		}
+	}
+
+	user.Timezone = timezone
+
+	return nil
+}
+
+// ValidateRole checks the role is one of models.Roles.
+func ValidateRole(role string) error {
+	if !models.ValidRole(role) {
+		return fmt.Errorf("role must be one of %s", strings.Join(models.Roles, ", "))
+	}
+
+	return nil
+}
+
+func setRole(user *models.User, role string) error {
+	if err := ValidateRole(role); err != nil {
+		return err
+	}
+
+	user.Role = role
+
+	return nil
+}
@@ -0,0 +1,215 @@
+package useradmin
+
+import (
+	"goairmon/business/data/context"
+	"goairmon/business/data/models"
+	"goairmon/business/services/audit"
+	"goairmon/business/services/totp"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+func _setupDbContext(t *testing.T) (context.DbContext, string) {
+	dir, err := ioutil.TempDir("", "goairmon_useradmin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return context.NewMemDbContext(&context.MemDbConfig{StoragePath: dir, SensorPointCount: 10}), dir
+}
+
+func TestExecute(t *testing.T) {
+	dbContext, dir := _setupDbContext(t)
+	defer os.RemoveAll(dir)
+
+	rows := []struct {
+		req     *Request
+		success bool
+	}{
+		{&Request{Command: CommandAdd, Username: "short", Password: "air-quality"}, false},
+		{&Request{Command: CommandAdd, Username: "first-user", Password: "short"}, false},
+		{&Request{Command: CommandAdd, Username: "first-user", Password: "air-quality", Timezone: "Not/AZone"}, false},
+		{&Request{Command: CommandAdd, Username: "first-user", Password: "air-quality", Timezone: "America/Vancouver"}, true},
+		{&Request{Command: CommandAdd, Username: "first-user", Password: "air-quality"}, false},
+		{&Request{Command: CommandAdd, Username: "second-user", Password: "air-quality", Role: "superuser"}, false},
+		{&Request{Command: CommandAdd, Username: "second-user", Password: "air-quality"}, true},
+		{&Request{Command: CommandRole, Username: "second-user", Role: "superuser"}, false},
+		{&Request{Command: CommandRole, Username: "second-user", Role: models.RoleOperator}, true},
+		{&Request{Command: CommandPasswd, Username: "second-user", Password: "password"}, false},
+		{&Request{Command: CommandPasswd, Username: "second-user", Password: "air-quality"}, false},
+		{&Request{Command: CommandPasswd, Username: "second-user", Password: "new-password"}, true},
+		{&Request{Command: CommandRename, Username: "second-user", NewUsername: "first-user"}, false},
+		{&Request{Command: CommandRename, Username: "second-user", NewUsername: "renamed-user"}, true},
+		{&Request{Command: CommandLock, Username: "renamed-user"}, true},
+		{&Request{Command: CommandLock, Username: "not-a-user"}, false},
+		{&Request{Command: "explode", Username: "renamed-user"}, false},
+		{&Request{Command: CommandRemove, Username: "first-user"}, true},
+		{&Request{Command: CommandRemove, Username: "first-user"}, false},
+	}
+
+	for _, row := range rows {
+		res := Execute(dbContext, nil, nil, row.req)
+		if (res.Error == "") != row.success {
+			t.Error("unexpected result", row.req, res.Error)
+		}
+	}
+
+	res := Execute(dbContext, nil, nil, &Request{Command: CommandList})
+	if len(res.Users) != 1 {
+		t.Fatal("unexpected users", res.Users)
+	}
+
+	if res.Users[0].Username != "renamed-user" || !res.Users[0].Locked || res.Users[0].Role != models.RoleOperator {
+		t.Error("unexpected user", res.Users[0])
+	}
+
+	user, _ := dbContext.FindUserByName("renamed-user")
+	if !user.CheckPassword("new-password") {
+		t.Error("expected password to be changed")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "goairmon_config.json")); err != nil {
+		t.Error("expected changes to be saved")
+	}
+}
+
+func TestSocketServer(t *testing.T) {
+	dbContext, dir := _setupDbContext(t)
+	defer os.RemoveAll(dir)
+
+	socketPath := filepath.Join(dir, "admin.sock")
+	if _, err := Dial(socketPath); err == nil {
+		t.Error("expected dial to fail without server")
+	}
+
+	auditLog := audit.NewAuditLog(&audit.Config{File: filepath.Join(dir, "audit.log")})
+	server := NewSocketServer(socketPath, dbContext, nil, nil, auditLog, echo.New().Logger)
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	if info, err := os.Stat(socketPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Error("expected socket only the owner can use", info, err)
+	}
+
+	if staged, _ := filepath.Glob(filepath.Join(dir, ".goairmon_socket_*")); len(staged) != 0 {
+		t.Error("expected private directory to be removed", staged)
+	}
+
+	if err := NewSocketServer(socketPath, dbContext, nil, nil, nil, echo.New().Logger).Start(); err == nil {
+		t.Error("expected error when socket is in use")
+	}
+
+	client, err := Dial(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := client.Send(&Request{Command: CommandAdd, Username: "socket-user", Password: "air-quality"})
+	if err != nil || res.Error != "" {
+		t.Fatal(err, res.Error)
+	}
+
+	if _, err := dbContext.FindUserByName("socket-user"); err != nil {
+		t.Error("expected user to be added to server context")
+	}
+
+	if entries, err := auditLog.Query(audit.Filter{}); err != nil || len(entries) != 1 || entries[0].Event != audit.EventUserAdded || entries[0].Actor != "cli" {
+		t.Error("expected user add to be audited", entries, err)
+	}
+
+	res, err = client.Send(&Request{Command: CommandList})
+	if err != nil || len(res.Users) != 1 {
+		t.Error("unexpected list response", res, err)
+	}
+
+	res, err = client.Send(&Request{Command: CommandAudit, Audit: &audit.Entry{Event: audit.EventBackup, Actor: "cli", Details: "backup.tar.gz"}})
+	if err != nil || res.Error != "" {
+		t.Error("unexpected audit response", res, err)
+	}
+
+	if entries, err := auditLog.Query(audit.Filter{Event: audit.EventBackup}); err != nil || len(entries) != 1 || entries[0].Details != "backup.tar.gz" {
+		t.Error("expected CLI entry to be recorded by the server", entries, err)
+	}
+
+	if res, err = client.Send(&Request{Command: CommandAudit}); err != nil || res.Error == "" {
+		t.Error("expected audit without an entry to fail", res, err)
+	}
+
+	if err := server.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := os.Stat(socketPath); !os.IsNotExist(err) {
+		t.Error("expected socket to be removed", err)
+	}
+
+	if err := server.Close(); err == nil {
+		t.Error("expected error")
+	}
+}
+
+type _fakeRevoker struct {
+	revoked []string
+}
+
+func (r *_fakeRevoker) RemoveUserSessions(userID string, keepIds ...string) int {
+	r.revoked = append(r.revoked, userID)
+	return 1
+}
+
+func TestExecuteRevokesSessions(t *testing.T) {
+	dbContext, dir := _setupDbContext(t)
+	defer os.RemoveAll(dir)
+
+	revoker := &_fakeRevoker{}
+	Execute(dbContext, revoker, nil, &Request{Command: CommandAdd, Username: "session-user", Password: "air-quality"})
+	user, _ := dbContext.FindUserByName("session-user")
+
+	Execute(dbContext, revoker, nil, &Request{Command: CommandLock, Username: "session-user"})
+	if len(revoker.revoked) != 0 {
+		t.Error("expected sessions to be kept", revoker.revoked)
+	}
+
+	Execute(dbContext, revoker, nil, &Request{Command: CommandPasswd, Username: "session-user", Password: "short"})
+	Execute(dbContext, revoker, nil, &Request{Command: CommandPasswd, Username: "session-user", Password: "new-password"})
+	Execute(dbContext, revoker, nil, &Request{Command: CommandRemove, Username: "session-user"})
+
+	if len(revoker.revoked) != 2 || revoker.revoked[0] != user.ID.String() || revoker.revoked[1] != user.ID.String() {
+		t.Error("expected sessions to be revoked on password change and removal", revoker.revoked)
+	}
+}
+
+func TestExecuteReset2fa(t *testing.T) {
+	dbContext, dir := _setupDbContext(t)
+	defer os.RemoveAll(dir)
+
+	Execute(dbContext, nil, nil, &Request{Command: CommandAdd, Username: "totp-user", Password: "air-quality"})
+	if res := Execute(dbContext, nil, nil, &Request{Command: CommandReset2fa, Username: "totp-user"}); res.Error == "" {
+		t.Error("expected error without two factor")
+	}
+
+	user, _ := dbContext.FindUserByName("totp-user")
+	secret, _ := totp.GenerateSecret()
+	totp.Enable(user, secret, time.Now())
+	dbContext.CreateOrUpdateUser(user)
+
+	if res := Execute(dbContext, nil, nil, &Request{Command: CommandList}); !res.Users[0].TwoFactor {
+		t.Error("expected two factor in list")
+	}
+
+	if res := Execute(dbContext, nil, nil, &Request{Command: CommandReset2fa, Username: "totp-user"}); res.Error != "" {
+		t.Error(res.Error)
+	}
+
+	user, _ = dbContext.FindUserByName("totp-user")
+	if user.TwoFactorEnabled() || len(user.RecoveryCodes) != 0 {
+		t.Error("expected two factor to be reset")
+	}
+}
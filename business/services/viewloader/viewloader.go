@@ -4,10 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"goairmon/business/data/context"
+	"goairmon/business/data/models"
 	"goairmon/site/helper"
 	vmodels "goairmon/site/models"
 	"html/template"
-	"log"
 	"path/filepath"
 	"time"
 
@@ -17,6 +17,12 @@ import (
 const CtxKey = "view_loader"
 
 type ViewLoader struct {
+	// Charted for minutes without a reading
+	FillCo2 float64
+	// How often readings are saved, defaults to a minute
+	PollInterval time.Duration
+	// Readings kept in the DbContext, charts show gaps if that's less than 8 days of them
+	SensorPointCount int
 }
 
 func fullViewPath(viewPath string) string {
@@ -46,9 +52,9 @@ func (v *ViewLoader) LoadView(viewPath string, c echo.Context) *template.Templat
 				reducedSensorPoints = v.initReducedSensorPoints(c)
 			}
 
-			raw, err := json.Marshal(reducedSensorPoints.Last48Hours())
+			raw, err := json.Marshal(reducedSensorPoints.Labelled(reducedSensorPoints.Last48Hours(), "Mon 15:04"))
 			if err != nil {
-				log.Println(err)
+				c.Logger().Error("failed to encode sensor points", err)
 			}
 
 			return string(raw)
@@ -58,9 +64,9 @@ func (v *ViewLoader) LoadView(viewPath string, c echo.Context) *template.Templat
 				reducedSensorPoints = v.initReducedSensorPoints(c)
 			}
 
-			raw, err := json.Marshal(reducedSensorPoints.Last2Hours())
+			raw, err := json.Marshal(reducedSensorPoints.Labelled(reducedSensorPoints.Last2Hours(), "15:04"))
 			if err != nil {
-				log.Println(err)
+				c.Logger().Error("failed to encode sensor points", err)
 			}
 
 			return string(raw)
@@ -70,13 +76,26 @@ func (v *ViewLoader) LoadView(viewPath string, c echo.Context) *template.Templat
 				reducedSensorPoints = v.initReducedSensorPoints(c)
 			}
 
-			raw, err := json.Marshal(reducedSensorPoints.Last7Days())
+			raw, err := json.Marshal(reducedSensorPoints.Labelled(reducedSensorPoints.Last7Days(), "Mon Jan 2 15:04"))
 			if err != nil {
-				log.Println(err)
+				c.Logger().Error("failed to encode sensor points", err)
 			}
 
 			return string(raw)
 		},
+		// Site paths in templates are prefixed with this so they work under BASE_PATH
+		"basePath": func() string {
+			basePath, _ := c.Get(helper.CtxBasePath).(string)
+			return basePath
+		},
+		"currentReading": func() string {
+			points, err := c.Get(helper.CtxDbContext).(context.DbContext).GetSensorPoints(1)
+			if err != nil || len(points) == 0 {
+				return ""
+			}
+
+			return fmt.Sprintf("%.0f ppm at %s", points[0].Co2Value, points[0].Time.In(v.displayLocation(c)).Format("15:04"))
+		},
 	})
 
 	parsed, err := mainTemplate.ParseFiles(files...)
@@ -88,10 +107,43 @@ func (v *ViewLoader) LoadView(viewPath string, c echo.Context) *template.Templat
 }
 
 func (v *ViewLoader) initReducedSensorPoints(c echo.Context) *vmodels.ReducedSensorPoints {
-	points, err := c.Get(helper.CtxDbContext).(context.DbContext).GetSensorPoints(60 * 24 * 8)
+	points, err := c.Get(helper.CtxDbContext).(context.DbContext).GetSensorPoints(v.chartPointCount())
 	if err != nil {
-		log.Println(err)
+		c.Logger().Error("failed to load sensor points", err)
+	}
+
+	return vmodels.NewReducedSensorPoints(points, time.Now(), v.displayLocation(c), v.FillCo2, v.pollInterval())
+}
+
+func (v *ViewLoader) pollInterval() time.Duration {
+	if v.PollInterval <= 0 {
+		return time.Minute
+	}
+
+	return v.PollInterval
+}
+
+// Enough readings for 8 days of charts, up to as many as are kept.
+func (v *ViewLoader) chartPointCount() int {
+	count := int(8 * 24 * time.Hour / v.pollInterval())
+	if v.SensorPointCount > 0 && count > v.SensorPointCount {
+		count = v.SensorPointCount
+	}
+
+	return count
+}
+
+// Uses the logged in user's timezone if they have one, otherwise the site's.
+func (v *ViewLoader) displayLocation(c echo.Context) *time.Location {
+	loc, ok := c.Get(helper.CtxLocation).(*time.Location)
+	if !ok || loc == nil {
+		loc = time.Local
+	}
+
+	user, ok := c.Get(helper.CtxCurrentUser).(*models.User)
+	if !ok || user == nil {
+		return loc
 	}
 
-	return vmodels.NewReducedSensorPoints(points, time.Now())
+	return user.Location(loc)
 }
@@ -0,0 +1,25 @@
+package viewloader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChartPointCount(t *testing.T) {
+	rows := []struct {
+		loader   *ViewLoader
+		expected int
+	}{
+		{&ViewLoader{}, 11520},
+		{&ViewLoader{PollInterval: time.Minute, SensorPointCount: 11520}, 11520},
+		{&ViewLoader{PollInterval: 5 * time.Minute, SensorPointCount: 11520}, 2304},
+		{&ViewLoader{PollInterval: 30 * time.Second, SensorPointCount: 11520}, 11520},
+		{&ViewLoader{PollInterval: time.Second, SensorPointCount: 100}, 100},
+	}
+
+	for _, row := range rows {
+		if count := row.loader.chartPointCount(); count != row.expected {
+			t.Error("unexpected count", row.loader, row.expected, count)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"goairmon/business/services/audit"
+	"goairmon/business/services/backup"
+	"os"
+	"time"
+)
+
+func runBackup(args []string) error {
+	flags := newFlagSet("backup", "")
+	outPath := flags.String("out", "", "backup file to write (defaults to goairmon_backup_{date}.tar.gz)")
+
+	cfg, err := parse(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if *outPath == "" {
+		*outPath = backup.FileName(time.Now())
+	}
+
+	out, err := os.OpenFile(*outPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %s", err)
+	}
+
+	manifest, err := backup.Create(out, cfg.StoragePath)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(*outPath)
+		return fmt.Errorf("failed to create backup: %s", err)
+	}
+
+	recordAudit(cfg, audit.Entry{Event: audit.EventBackup, Details: *outPath})
+	fmt.Printf("Backed up %d files to %s\n", len(manifest.Files), *outPath)
+
+	return nil
+}
+
+func runRestore(args []string) error {
+	flags := newFlagSet("restore", "backup.tar.gz")
+	force := flags.Bool("force", false, "restore even if the backup version doesn't match")
+	validateOnly := flags.Bool("validate", false, "only check the backup against its manifest")
+
+	cfg, err := parse(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("a single backup file must be provided")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %s", err)
+	}
+	defer file.Close()
+
+	if *validateOnly {
+		manifest, err := backup.Validate(file, *force)
+		if err != nil {
+			return fmt.Errorf("invalid backup: %s", err)
+		}
+
+		fmt.Printf("Backup from %s with %d files is valid\n", manifest.CreatedAt, len(manifest.Files))
+		return nil
+	}
+
+	if serverRunning(cfg) {
+		return fmt.Errorf("stop the server before restoring")
+	}
+
+	manifest, err := backup.Restore(file, cfg.StoragePath, *force)
+	if err != nil {
+		return fmt.Errorf("failed to restore backup: %s", err)
+	}
+
+	recordAudit(cfg, audit.Entry{Event: audit.EventRestore, Details: fmt.Sprintf("%s created %s", flags.Arg(0), manifest.CreatedAt)})
+	fmt.Printf("Restored %d files from backup created %s\n", len(manifest.Files), manifest.CreatedAt)
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+	"goairmon/business/hardware"
+	"goairmon/business/services/applog"
+	"os"
+)
+
+func runCheckSensor(args []string) error {
+	flags := newFlagSet("check-sensor", "")
+	count := flags.Int("count", 10, "number of measurements to take")
+	delayMillis := flags.Int("delay", 1000, "milliseconds between measurements")
+
+	cfg, err := parse(flags, args)
+	if err != nil {
+		return err
+	}
+
+	// Init and the self-test restart the sensor's baseline under the running poll
+	if serverRunning(cfg) {
+		return fmt.Errorf("stop the server before checking the sensor")
+	}
+
+	result, err := hardware.CheckSensor(hardware.NewSGP30(applog.Default().Subsystem("sensor")), &hardware.SensorCheckCfg{
+		Measurements: *count,
+		DelayMillis:  *delayMillis,
+		Out:          os.Stdout,
+	})
+
+	if result != nil {
+		fmt.Printf("\nSerial:       %#012x\n", result.SerialID)
+		fmt.Printf("Feature set:  %#04x\n", result.FeatureSet)
+		fmt.Printf("Init time:    %s\n", result.InitTime)
+		fmt.Printf("Measurements: %d (%d failed)\n", result.Measurements, result.Failures)
+		fmt.Printf("eCO2:         min %d, max %d, mean %.1f ppm\n", result.ECO2.Min, result.ECO2.Max, result.ECO2.Mean)
+		fmt.Printf("TVOC:         min %d, max %d, mean %.1f ppb\n", result.TVOC.Min, result.TVOC.Max, result.TVOC.Mean)
+		fmt.Printf("Measure time: min %s, max %s, mean %s\n", result.MeasureTime.Min, result.MeasureTime.Max, result.MeasureTime.Mean)
+		fmt.Printf("Baseline:     eCO2 %#04x, TVOC %#04x\n", result.BaselineECO2, result.BaselineTVOC)
+	}
+
+	return err
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"goairmon/business/data/context"
+	"goairmon/business/services/audit"
+	"goairmon/business/services/useradmin"
+	"goairmon/site"
+	"goairmon/site/config"
+	"os"
+	"strings"
+)
+
+type command struct {
+	name        string
+	description string
+	run         func(args []string) error
+}
+
+var commands = []*command{
+	{"serve", "run the web server and sensor poll (default)", runServe},
+	{"user", "add, remove, list and edit users", runUser},
+	{"export", "export readings as csv or ndjson", runExport},
+	{"import", "merge exported readings or archives into storage", runImport},
+	{"backup", "write a backup of storage", runBackup},
+	{"restore", "restore storage from a backup", runRestore},
+	{"check-sensor", "check the sensor is connected and reading", runCheckSensor},
+	{"migrate", "upgrade stored config to this version", runMigrate},
+	{"config", "check the config and print the values in effect", runConfig},
+}
+
+// Run dispatches to the subcommand named by args[0], serving when none is given.
+func Run(args []string) int {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			if err := cmd.run(args); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+
+			return 0
+		}
+	}
+
+	printUsage()
+	if name == "help" {
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "\nunknown command %q\n", name)
+
+	return 1
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [-envpath .env] [-config file] [flags]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-14s%s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun %s <command> -h for command flags.\n", os.Args[0])
+}
+
+// newFlagSet makes a flag set for the command with usage showing argUsage after the flags.
+func newFlagSet(name string, argUsage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s %s [flags] %s\n", os.Args[0], name, argUsage)
+		flags.PrintDefaults()
+	}
+
+	return flags
+}
+
+// parse adds the shared -envpath and -config flags, parses args and loads the config.
+func parse(flags *flag.FlagSet, args []string) (*site.Config, error) {
+	opts := configFlags(flags)
+	flags.Parse(args)
+
+	cfg, err := config.Load(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg.Site, nil
+}
+
+func configFlags(flags *flag.FlagSet) *config.Options {
+	opts := &config.Options{}
+	flags.StringVar(&opts.EnvPath, "envpath", ".env", "path to .env file")
+	flags.StringVar(&opts.File, "config", "", "path to a TOML or YAML config file, overrides CONFIG_FILE")
+
+	return opts
+}
+
+// openDbContext loads storage directly, commands must only Close it when the server isn't running.
+func openDbContext(cfg *site.Config) context.DbContext {
+	return context.NewMemDbContext(&context.MemDbConfig{
+		StoragePath:      cfg.StoragePath,
+		SensorPointCount: cfg.SensorPointCount,
+	})
+}
+
+// recordAudit appends a change made from the CLI to the audit log, warning if it can't.
+// A running server owns the log and its rotation, so the entry is sent to it instead.
+func recordAudit(cfg *site.Config, entry audit.Entry) {
+	if entry.Actor == "" {
+		entry.Actor = "cli"
+	}
+
+	if client, err := useradmin.Dial(cfg.AdminSocket); err == nil {
+		res, err := client.Send(&useradmin.Request{Command: useradmin.CommandAudit, Audit: &entry})
+		if err == nil && res.Error != "" {
+			err = errors.New(res.Error)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "warning: failed to record audit entry:", err)
+		}
+		return
+	}
+
+	auditLog := audit.NewAuditLog(&cfg.Audit)
+	defer auditLog.Close()
+
+	if err := auditLog.Record(entry); err != nil {
+		fmt.Fprintln(os.Stderr, "warning:", err)
+	}
+}
+
+// serverRunning checks for the running server's admin socket.
+func serverRunning(cfg *site.Config) bool {
+	_, err := useradmin.Dial(cfg.AdminSocket)
+
+	return err == nil
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"goairmon/site/config"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintf(os.Stderr, "Usage: %s config check [flags]\n", os.Args[0])
+		return fmt.Errorf("a config command must be provided")
+	}
+
+	flags := newFlagSet("config check", "")
+	opts := configFlags(flags)
+	flags.Parse(args[1:])
+
+	cfg, err := config.Load(opts)
+	if _, invalid := err.(*config.ValidationError); err != nil && !invalid {
+		return err
+	}
+
+	printConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("\nConfig is valid")
+
+	return nil
+}
+
+// Prints every setting with secrets redacted.
+func printConfig(cfg *config.Config) {
+	if cfg.File != "" {
+		fmt.Printf("Config file: %s\n\n", cfg.File)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "KEY\tVALUE\tSOURCE")
+
+	for _, value := range cfg.Values {
+		display := value.Redacted()
+		if display == "" {
+			display = "-"
+		} else if strings.TrimSpace(display) != display {
+			display = fmt.Sprintf("%q", display)
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", value.Setting.Key, display, value.Source)
+	}
+
+	writer.Flush()
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"goairmon/business/services/export"
+	"os"
+)
+
+func runExport(args []string) error {
+	flags := newFlagSet("export", "")
+	from := flags.String("from", "", "start of range, YYYY-MM-DD or RFC3339 (defaults to oldest point)")
+	to := flags.String("to", "", "end of range (exclusive), YYYY-MM-DD or RFC3339 (defaults to now)")
+	format := flags.String("format", export.FormatCSV, "output format, csv or ndjson")
+	interval := flags.Duration("interval", 0, "average points over this interval, e.g. 1h (default raw points)")
+	includeTVOC := flags.Bool("tvoc", false, "include TVOC values")
+	outPath := flags.String("out", "", "output file (defaults to stdout)")
+
+	cfg, err := parse(flags, args)
+	if err != nil {
+		return err
+	}
+
+	query := &export.Query{
+		Interval:    *interval,
+		Format:      *format,
+		IncludeTVOC: *includeTVOC,
+	}
+
+	if query.From, err = export.ParseTime(*from, cfg.Location); err != nil {
+		return err
+	}
+
+	if query.To, err = export.ParseTime(*to, cfg.Location); err != nil {
+		return err
+	}
+
+	if err := query.Validate(); err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if *outPath != "" {
+		out, err = os.Create(*outPath)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %s", err)
+		}
+		defer out.Close()
+	}
+
+	// Read only, the context is never closed so the running server's storage isn't overwritten.
+	exporter := export.NewExporter(&export.Config{Location: cfg.Location}, openDbContext(cfg))
+	if err := exporter.Export(out, query); err != nil {
+		return fmt.Errorf("failed to export points: %s", err)
+	}
+
+	return nil
+}
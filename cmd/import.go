@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"goairmon/business/data/context"
+	"goairmon/business/data/models"
+	"goairmon/business/services/export"
+	"os"
+	"time"
+)
+
+func runImport(args []string) error {
+	flags := newFlagSet("import", "file...")
+	format := flags.String("format", "", "input format, csv, ndjson or archive (defaults to file extension)")
+	dryRun := flags.Bool("dry-run", false, "parse the files without saving anything")
+
+	cfg, err := parse(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		return fmt.Errorf("at least one file must be provided")
+	}
+
+	points := make([]*models.SensorPoint, 0)
+	for _, path := range flags.Args() {
+		filePoints, err := readFile(path, *format)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Read %d points from %s\n", len(filePoints), path)
+		points = append(points, filePoints...)
+	}
+
+	if *dryRun {
+		return nil
+	}
+
+	if serverRunning(cfg) {
+		return fmt.Errorf("stop the server before importing")
+	}
+
+	ctx := openDbContext(cfg)
+	archived, err := archiveCompleteDays(ctx, points, cfg.Location)
+	if err != nil {
+		return fmt.Errorf("failed to archive points: %s", err)
+	}
+
+	added, err := ctx.MergeSensorPoints(points)
+	if err != nil {
+		return fmt.Errorf("failed to merge points: %s", err)
+	}
+
+	if err := ctx.Close(); err != nil {
+		return fmt.Errorf("failed to save context: %s", err)
+	}
+
+	fmt.Printf("Archived %d new points, added %d recent points\n", archived, added)
+
+	return nil
+}
+
+func readFile(path string, format string) ([]*models.SensorPoint, error) {
+	if format == "" {
+		var err error
+		if format, err = export.FormatFromPath(path); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %s", path, err)
+	}
+	defer file.Close()
+
+	points, err := export.ReadPoints(file, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %s", path, err)
+	}
+
+	return points, nil
+}
+
+// Days before today go to the archives, the ring buffer only keeps what fits.
+func archiveCompleteDays(ctx context.DbContext, points []*models.SensorPoint, loc *time.Location) (int, error) {
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	days := make(map[time.Time][]*models.SensorPoint)
+	for _, p := range points {
+		local := p.Time.In(loc)
+		day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+		if day.Before(today) {
+			days[day] = append(days[day], p)
+		}
+	}
+
+	total := 0
+	for day, dayPoints := range days {
+		added, err := ctx.MergeArchivedDay(day, dayPoints)
+		if err != nil {
+			return total, err
+		}
+
+		total += added
+	}
+
+	return total, nil
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+	"goairmon/business/data/context"
+)
+
+func runMigrate(args []string) error {
+	cfg, err := parse(newFlagSet("migrate", ""), args)
+	if err != nil {
+		return err
+	}
+
+	if serverRunning(cfg) {
+		return fmt.Errorf("stop the server before migrating")
+	}
+
+	from, to, err := context.MigrateStorage(cfg.StoragePath)
+	if err != nil {
+		return err
+	}
+
+	if from == to {
+		fmt.Printf("Stored config is already at version %d\n", to)
+	} else {
+		fmt.Printf("Migrated stored config from version %d to %d\n", from, to)
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"goairmon/site"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func runServe(args []string) error {
+	cfg, err := parse(newFlagSet("serve", ""), args)
+	if err != nil {
+		return err
+	}
+
+	server := site.NewSite(cfg)
+	server.Start()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+	signal.Stop(signals)
+
+	return server.Shutdown()
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"goairmon/business/data/models"
+	"goairmon/business/services/applog"
+	"goairmon/business/services/identity"
+	"goairmon/business/services/passpolicy"
+	"goairmon/business/services/useradmin"
+	"goairmon/site"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/term"
+)
+
+func runUser(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintf(os.Stderr, "Usage: %s user <add|remove|list|passwd|rename|role|lock|unlock|reset-2fa> [flags]\n", os.Args[0])
+		return fmt.Errorf("a user command must be provided")
+	}
+
+	req := &useradmin.Request{Command: args[0]}
+	flags := newFlagSet("user "+req.Command, "")
+
+	switch req.Command {
+	case useradmin.CommandList:
+	case useradmin.CommandAdd:
+		flags.StringVar(&req.Username, "username", "", "username to add")
+		flags.StringVar(&req.Password, "password", "", "password for user (prompts if empty)")
+		flags.StringVar(&req.Timezone, "timezone", "", "display timezone for user, e.g. America/Vancouver (defaults to TIMEZONE)")
+		flags.StringVar(&req.Role, "role", models.RoleViewer, "role for user, "+strings.Join(models.Roles, ", "))
+	case useradmin.CommandPasswd:
+		flags.StringVar(&req.Username, "username", "", "user to change")
+		flags.StringVar(&req.Password, "password", "", "new password (prompts if empty)")
+	case useradmin.CommandRename:
+		flags.StringVar(&req.Username, "username", "", "user to rename")
+		flags.StringVar(&req.NewUsername, "new-username", "", "new username")
+	case useradmin.CommandRole:
+		flags.StringVar(&req.Username, "username", "", "user to change")
+		flags.StringVar(&req.Role, "role", "", "new role, "+strings.Join(models.Roles, ", "))
+	case useradmin.CommandRemove, useradmin.CommandLock, useradmin.CommandUnlock, useradmin.CommandReset2fa:
+		flags.StringVar(&req.Username, "username", "", "user to "+req.Command)
+	default:
+		return fmt.Errorf("unknown user command %q", req.Command)
+	}
+
+	cfg, err := parse(flags, args[1:])
+	if err != nil {
+		return err
+	}
+
+	if req.Command != useradmin.CommandList && req.Username == "" {
+		return fmt.Errorf("username must be provided")
+	}
+
+	if (req.Command == useradmin.CommandAdd || req.Command == useradmin.CommandPasswd) && req.Password == "" {
+		if req.Password, err = readPassword(); err != nil {
+			return err
+		}
+	}
+
+	res, err := sendUserRequest(cfg, req)
+	if err != nil {
+		return err
+	}
+
+	if res.Error != "" {
+		return errors.New(res.Error)
+	}
+
+	if req.Command == useradmin.CommandList {
+		printUsers(res.Users)
+	} else {
+		fmt.Println(res.Message)
+	}
+
+	return nil
+}
+
+// Prompts for a password without echoing it when stdin is a terminal, otherwise reads a line.
+func readPassword() (string, error) {
+	fmt.Print("Password: ")
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		password, err := term.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			return "", fmt.Errorf("failed to read password: %s", err)
+		}
+		return string(password), nil
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read password")
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Sends the request to the running server if it's up, otherwise edits storage directly.
+func sendUserRequest(cfg *site.Config, req *useradmin.Request) (*useradmin.Response, error) {
+	if client, err := useradmin.Dial(cfg.AdminSocket); err == nil {
+		return client.Send(req)
+	}
+
+	// An unreadable sessions file is dropped by the server on start, so the empty store is fine here
+	sessions, _ := identity.OpenSessionStore(&identity.IdentityConfig{
+		SessionFile:   cfg.SessionFile,
+		SessionExpiry: cfg.SessionExpiry,
+		Logger:        applog.Default().Subsystem("auth"),
+	})
+
+	policy, err := passpolicy.NewPolicy(&cfg.PasswordPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := openDbContext(cfg)
+	res := useradmin.Execute(ctx, sessions, policy, req)
+
+	if req.Command != useradmin.CommandList {
+		if err := ctx.Close(); err != nil {
+			return nil, fmt.Errorf("failed to save context: %s", err)
+		}
+
+		if res.Error == "" {
+			recordAudit(cfg, useradmin.AuditEntry(req, res))
+		}
+	}
+
+	return res, nil
+}
+
+func printUsers(users []*useradmin.UserInfo) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "USERNAME\tROLE\tTIMEZONE\tLAST LOGIN\tLOCKED\t2FA")
+
+	for _, user := range users {
+		lastLogin := "never"
+		if !user.LastLogin.IsZero() {
+			lastLogin = user.LastLogin.Local().Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%t\t%t\n", user.Username, user.Role, user.Timezone, lastLogin, user.Locked, user.TwoFactor)
+	}
+
+	writer.Flush()
+}
@@ -11,5 +11,8 @@ require (
 	github.com/labstack/echo v3.3.10+incompatible
 	github.com/labstack/gommon v0.2.9
 	github.com/op/go-logging v0.0.0-20160315200505-970db520ece7
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	golang.org/x/crypto v0.1.0
+	golang.org/x/exp v0.0.0-20190829153037-c13cbed26979
+	golang.org/x/term v0.1.0
 )
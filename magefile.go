@@ -62,9 +62,7 @@ func must(err error) {
 func buildForTarget(arch string, arm string) error {
 	fullDist := fmt.Sprintf("dist/%s/", arch+arm)
 
-	os.MkdirAll(fullDist+"cmd", 0700)
-
-	os.Mkdir(fullDist+"storage", 0700)
+	os.MkdirAll(fullDist+"storage", 0700)
 
 	if err := exec.Command("cp", "-r", "resources", fullDist+"resources").Run(); err != nil {
 		return err
@@ -90,13 +88,6 @@ func buildForTarget(arch string, arm string) error {
 		return err
 	}
 
-	if err := buildCommand(fullDist+"cmd/adduser", "./cmd/adduser", arch, arm); err != nil {
-		return err
-	}
-	if err := buildCommand(fullDist+"cmd/rmuser", "./cmd/rmuser", arch, arm); err != nil {
-		return err
-	}
-
 	tarCmd := exec.Command("tar", "-czf", "dist/goairmon-"+arch+arm+".tar.gz", "-C", fullDist, ".")
 	if out, err := tarCmd.CombinedOutput(); err != nil {
 		fmt.Println(string(out))
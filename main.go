@@ -1,27 +1,10 @@
 package main
 
 import (
-	"fmt"
-	"goairmon/site"
-
-	"github.com/joho/godotenv"
+	"goairmon/cmd"
+	"os"
 )
 
 func main() {
-	if err := godotenv.Load(".env"); err != nil {
-		panic("failed to load .env")
-	}
-
-	server := site.NewSite(site.EnvSiteConfig())
-	defer cleanup(server)
-
-	server.Start()
-
-	select {}
-}
-
-func cleanup(server *site.Site) {
-	if err := server.Cleanup(); err != nil {
-		fmt.Print(err)
-	}
+	os.Exit(cmd.Run(os.Args[1:]))
 }
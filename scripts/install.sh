@@ -17,7 +17,7 @@ cp goairmon.service /etc/systemd/system/goairmon.service
 
 rm ${AppDir}/install.sh ${AppDir}/goairmon.service
 
-(cd ${AppDir} && cmd/adduser -username ${UserName} -password ${Password}) || echo "Failed to add new user"
+(cd ${AppDir} && ./goairmon user add -username ${UserName} -password ${Password} -role admin) || echo "Failed to add new user"
 
 CookieKey=$(cat /dev/urandom | tr -dc 'a-zA-Z0-9' | fold -w 16 | head -n 1)
 sed -i "s/%%COOKIE_KEY%%/${CookieKey}/g" ${AppDir}/.env
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"fmt"
+	"goairmon/business/services/passpolicy"
+	"goairmon/site"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// Where a value came from, later sources override earlier ones.
+const (
+	SourceDefault = "default"
+	SourceEnvFile = "env file"
+	SourceFile    = "config file"
+	SourceEnv     = "environment"
+)
+
+type Options struct {
+	// The .env file, skipped if it doesn't exist
+	EnvPath string
+	// Optional config file, read from CONFIG_FILE when empty
+	File string
+}
+
+// A setting's effective value and where it came from.
+type Value struct {
+	Setting *Setting
+	Value   string
+	Source  string
+}
+
+// Redacted is the value to print, secrets are masked.
+func (v *Value) Redacted() string {
+	if v.Setting.Secret && v.Value != "" {
+		return "********"
+	}
+
+	return v.Value
+}
+
+type Config struct {
+	Site   *site.Config
+	Values []*Value
+	// The config file read, if any
+	File string
+}
+
+// Every problem found in the config, so they can all be fixed in one go.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Load builds the site config from the defaults, the .env file, the config file and then environment variables,
+// an empty value falls back to the one before it. The config is returned along with a *ValidationError
+// if any values are invalid, other errors mean a file couldn't be read.
+func Load(opts *Options) (*Config, error) {
+	envFile, err := readEnvFile(opts.EnvPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{
+		Site: &site.Config{},
+		File: opts.File,
+	}
+	if cfg.File == "" {
+		cfg.File = lookup(envFile, "CONFIG_FILE")
+	}
+
+	var fileValues map[string]*fileValue
+	if cfg.File != "" {
+		if fileValues, err = readFile(cfg.File); err != nil {
+			return nil, err
+		}
+	}
+
+	problems := make([]string, 0)
+	for key, val := range fileValues {
+		if lookupSetting(key) == nil {
+			problems = append(problems, fmt.Sprintf("%s:%d: unknown setting %s", cfg.File, val.line, key))
+		}
+	}
+	sort.Strings(problems)
+
+	for _, setting := range Settings {
+		value := &Value{Setting: setting, Value: setting.Default, Source: SourceDefault}
+		if val := envFile[setting.Key]; val != "" {
+			value.Value, value.Source = val, SourceEnvFile
+		}
+		if val, ok := fileValues[setting.Key]; ok && val.value != "" {
+			value.Value, value.Source = val.value, SourceFile
+		}
+		if val := os.Getenv(setting.Key); val != "" {
+			value.Value, value.Source = val, SourceEnv
+		}
+		cfg.Values = append(cfg.Values, value)
+
+		if value.Value == "" {
+			if setting.Required {
+				problems = append(problems, fmt.Sprintf("%s is required", setting.Key))
+			}
+			continue
+		}
+
+		if err := setting.apply(cfg.Site, strings.TrimSpace(value.Value)); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", setting.Key, err))
+		}
+	}
+
+	deriveFromStorage(cfg.Site)
+	problems = append(problems, validate(cfg.Site)...)
+
+	if len(problems) > 0 {
+		return cfg, &ValidationError{Problems: problems}
+	}
+
+	return cfg, nil
+}
+
+// Settings can all come from the config file or environment, so a missing .env file is empty.
+func readEnvFile(path string) (map[string]string, error) {
+	if path == "" {
+		return map[string]string{}, nil
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+
+	envFile, err := godotenv.Read(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load env file %s: %s", path, err)
+	}
+
+	return envFile, nil
+}
+
+// Environment variables override the .env file.
+func lookup(envFile map[string]string, key string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+
+	return envFile[key]
+}
+
+// Files kept in storage unless they're set.
+func deriveFromStorage(cfg *site.Config) {
+	cfg.SessionFile = filepath.Join(cfg.StoragePath, "goairmon_sessions.json")
+	cfg.TLS.StoragePath = cfg.StoragePath
+
+	if cfg.AdminSocket == "" {
+		cfg.AdminSocket = filepath.Join(cfg.StoragePath, "goairmon.sock")
+	}
+	if cfg.Audit.File == "" {
+		cfg.Audit.File = filepath.Join(cfg.StoragePath, "goairmon_audit.log")
+	}
+}
+
+// Checks settings that depend on each other or on files, skipping those already found invalid.
+func validate(cfg *site.Config) []string {
+	problems := make([]string, 0)
+
+	if (cfg.TLS.CertFile == "") != (cfg.TLS.KeyFile == "") {
+		problems = append(problems, "TLS_CERT and TLS_KEY must be set together")
+	}
+
+	if cfg.LoginLimit.MaxLockout > 0 && cfg.LoginLimit.Lockout > cfg.LoginLimit.MaxLockout {
+		problems = append(problems, "LOGIN_LOCKOUT_SECS can't be longer than LOGIN_MAX_LOCKOUT_SECS")
+	}
+
+	if cfg.PollInterval > 0 && cfg.SensorReadDelay > cfg.PollInterval {
+		problems = append(problems, "SENSOR_READ_MILLIS can't be longer than POLL_INTERVAL_SECS")
+	}
+
+	if cfg.PasswordPolicy.BlocklistFile != "" {
+		if _, err := passpolicy.NewPolicy(&cfg.PasswordPolicy); err != nil {
+			problems = append(problems, fmt.Sprintf("PASSWORD_BLOCKLIST: %s", err))
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func _writeFile(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func _tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "goairmon_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+const _envFile = "APP_COOKIE_KEY=cookie\nCOOKIE_STORE_ENCRYPTION=secret\nSTORAGE_PATH=/tmp/storage\nPOLL_INTERVAL_SECS=30\nLOG_LEVEL=warn\n"
+
+func _value(cfg *Config, key string) *Value {
+	for _, value := range cfg.Values {
+		if value.Setting.Key == key {
+			return value
+		}
+	}
+
+	return nil
+}
+
+func TestDefaults(t *testing.T) {
+	dir := _tempDir(t)
+	defer os.RemoveAll(dir)
+
+	cfg, err := Load(&Options{EnvPath: _writeFile(t, dir, ".env", _envFile)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	site := cfg.Site
+	if site.Address != ":3000" || site.SensorPointCount != 11520 || site.ChartFillCo2 != 400 || site.SessionExpiry != 28*24*time.Hour {
+		t.Error("unexpected defaults", site)
+	}
+
+	if site.SessionFile != "/tmp/storage/goairmon_sessions.json" || site.AdminSocket != "/tmp/storage/goairmon.sock" || site.TLS.StoragePath != "/tmp/storage" {
+		t.Error("unexpected storage paths", site)
+	}
+
+	if value := _value(cfg, "SERVER_ADDRESS"); value.Source != SourceDefault {
+		t.Error("unexpected source", value)
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	dir := _tempDir(t)
+	defer os.RemoveAll(dir)
+
+	file := _writeFile(t, dir, "goairmon.toml", "poll_interval_secs = 20 # seconds\nchart-fill-ppm = 420\n\n[log]\nlevel = \"debug\"\nfile = \"/tmp/goairmon # log\"\n")
+
+	os.Setenv("POLL_INTERVAL_SECS", "10")
+	defer os.Unsetenv("POLL_INTERVAL_SECS")
+
+	cfg, err := Load(&Options{EnvPath: _writeFile(t, dir, ".env", _envFile), File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Site.PollInterval != 10*time.Second || _value(cfg, "POLL_INTERVAL_SECS").Source != SourceEnv {
+		t.Error("expected environment to override the config file", cfg.Site.PollInterval)
+	}
+
+	if cfg.Site.Log.Level != "debug" || _value(cfg, "LOG_LEVEL").Source != SourceFile {
+		t.Error("expected config file to override .env", cfg.Site.Log.Level)
+	}
+
+	if cfg.Site.ChartFillCo2 != 420 || cfg.Site.Log.File != "/tmp/goairmon # log" {
+		t.Error("unexpected config file values", cfg.Site.ChartFillCo2, cfg.Site.Log.File)
+	}
+
+	if _value(cfg, "APP_COOKIE_KEY").Source != SourceEnvFile {
+		t.Error("expected value from .env")
+	}
+}
+
+func TestYAMLFile(t *testing.T) {
+	dir := _tempDir(t)
+	defer os.RemoveAll(dir)
+
+	file := _writeFile(t, dir, "goairmon.yaml", "server_address: \":8080\"\nsession:\n  expiry_hours: 24\n  gc_mins: 5\nbackup_schedule: '0 4 * * *'\n")
+
+	cfg, err := Load(&Options{EnvPath: _writeFile(t, dir, ".env", _envFile), File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Site.Address != ":8080" || cfg.Site.SessionExpiry != 24*time.Hour || cfg.Site.SessionGCDelay != 5*time.Minute || cfg.Site.BackupSchedule != "0 4 * * *" {
+		t.Error("unexpected yaml values", cfg.Site)
+	}
+}
+
+func TestYAMLEmptyValue(t *testing.T) {
+	dir := _tempDir(t)
+	defer os.RemoveAll(dir)
+
+	file := _writeFile(t, dir, "goairmon.yaml", "log_file:\nserver_address: \":8080\"\nlog:\n  format: JSON\nbackup_schedule:\n")
+	values, err := readFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if val, ok := values["LOG_FILE"]; !ok || val.value != "" || val.line != 1 {
+		t.Error("expected an empty value, not a section", val)
+	}
+
+	if val, ok := values["BACKUP_SCHEDULE"]; !ok || val.value != "" {
+		t.Error("expected an empty value at the end of the file", val)
+	}
+
+	if values["SERVER_ADDRESS"].value != ":8080" || values["LOG_FORMAT"].value != "JSON" || len(values) != 4 {
+		t.Error("unexpected yaml values", values)
+	}
+
+	cfg, err := Load(&Options{EnvPath: _writeFile(t, dir, ".env", _envFile), File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Site.Address != ":8080" || cfg.Site.Log.File != "" || cfg.Site.Log.Format != "json" {
+		t.Error("unexpected config", cfg.Site.Address, cfg.Site.Log)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	dir := _tempDir(t)
+	defer os.RemoveAll(dir)
+
+	env := "COOKIE_STORE_ENCRYPTION=secret\nSTORAGE_PATH=/tmp/storage\nPOLL_INTERVAL_SECS=soon\nTIMEZONE=Mars/Olympus\nTLS_CERT=cert.pem\n"
+	file := _writeFile(t, dir, "goairmon.toml", "[log]\nlevel = \"loud\"\ncolour = true\n")
+
+	cfg, err := Load(&Options{EnvPath: _writeFile(t, dir, ".env", env), File: file})
+	verr, ok := err.(*ValidationError)
+	if !ok || cfg == nil {
+		t.Fatal("expected validation error", err)
+	}
+
+	expected := []string{"unknown setting LOG_COLOUR", "APP_COOKIE_KEY is required", "TIMEZONE", "POLL_INTERVAL_SECS: must be a whole number", "LOG_LEVEL", "TLS_CERT and TLS_KEY"}
+	if len(verr.Problems) != len(expected) {
+		t.Fatal("unexpected problems", verr.Problems)
+	}
+
+	for i, problem := range verr.Problems {
+		if !strings.Contains(problem, expected[i]) {
+			t.Error("unexpected problem", i, problem)
+		}
+	}
+}
+
+func TestMissingFiles(t *testing.T) {
+	dir := _tempDir(t)
+	defer os.RemoveAll(dir)
+
+	os.Setenv("APP_COOKIE_KEY", "cookie")
+	os.Setenv("COOKIE_STORE_ENCRYPTION", "secret")
+	os.Setenv("STORAGE_PATH", "/tmp/storage")
+	defer os.Unsetenv("APP_COOKIE_KEY")
+	defer os.Unsetenv("COOKIE_STORE_ENCRYPTION")
+	defer os.Unsetenv("STORAGE_PATH")
+
+	if _, err := Load(&Options{EnvPath: filepath.Join(dir, ".env")}); err != nil {
+		t.Error("expected missing .env to be skipped", err)
+	}
+
+	if _, err := Load(&Options{EnvPath: dir}); err == nil {
+		t.Error("expected unreadable .env to fail")
+	}
+
+	_, err := Load(&Options{EnvPath: _writeFile(t, dir, ".env", _envFile), File: filepath.Join(dir, "missing.toml")})
+	if _, invalid := err.(*ValidationError); err == nil || invalid {
+		t.Error("expected missing config file to fail", err)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	dir := _tempDir(t)
+	defer os.RemoveAll(dir)
+
+	cfg, err := Load(&Options{EnvPath: _writeFile(t, dir, ".env", _envFile)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value := _value(cfg, "COOKIE_STORE_ENCRYPTION").Redacted(); value == "secret" || value == "" {
+		t.Error("expected secret to be redacted", value)
+	}
+
+	if value := _value(cfg, "STORAGE_PATH").Redacted(); value != "/tmp/storage" {
+		t.Error("unexpected value", value)
+	}
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A value read from the config file, with its line for errors.
+type fileValue struct {
+	value string
+	line  int
+}
+
+// readFile reads the flat subset of TOML, or YAML for .yaml and .yml files, that the settings need:
+// key = value (key: value in YAML) lines, quoted or bare values, # comments and one level of sections
+// ([log] in TOML, an unindented log: followed by indented keys in YAML) whose name prefixes the keys, so level under log is LOG_LEVEL.
+// Keys are case insensitive and dashes or dots become underscores. Lists are comma separated strings.
+func readFile(path string) (map[string]*fileValue, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config file: %s", err)
+	}
+	defer file.Close()
+
+	ext := strings.ToLower(filepath.Ext(path))
+	yaml := ext == ".yaml" || ext == ".yml"
+	sep := "="
+	if yaml {
+		sep = ":"
+	}
+
+	values := make(map[string]*fileValue)
+	section := ""
+	// A YAML key without a value is a section if the lines after it are indented, otherwise it's empty
+	sectionLine := 0
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		raw := scanner.Text()
+		line := strings.TrimSpace(stripComment(raw))
+		if line == "" || line == "---" {
+			continue
+		}
+
+		var key, value string
+		var ok bool
+		if yaml {
+			key, value, ok = cut(line, sep)
+			indented := raw[0] == ' ' || raw[0] == '\t'
+			if sectionLine > 0 && !indented {
+				values[normalizeKey(section)] = &fileValue{line: sectionLine}
+			}
+			sectionLine = 0
+
+			switch {
+			case ok && !indented && value == "":
+				section, sectionLine = key, lineNum
+				continue
+			case ok && !indented:
+				section = ""
+			case ok && section == "":
+				return nil, fmt.Errorf("%s:%d: indented key without a section", path, lineNum)
+			}
+		} else {
+			if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+				section = strings.TrimSpace(line[1 : len(line)-1])
+				continue
+			}
+			key, value, ok = cut(line, sep)
+		}
+
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected key %s value", path, lineNum, sep)
+		}
+
+		value, err := unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, lineNum, err)
+		}
+
+		if section != "" {
+			key = section + "_" + key
+		}
+		values[normalizeKey(key)] = &fileValue{value: value, line: lineNum}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load config file: %s", err)
+	}
+
+	if sectionLine > 0 {
+		values[normalizeKey(section)] = &fileValue{line: sectionLine}
+	}
+
+	return values, nil
+}
+
+func cut(line string, sep string) (string, string, bool) {
+	idx := strings.Index(line, sep)
+	if idx < 0 {
+		return "", "", false
+	}
+
+	return strings.TrimSpace(line[:idx]), strings.TrimSpace(line[idx+1:]), true
+}
+
+func normalizeKey(key string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(strings.TrimSpace(key)))
+}
+
+// Drops a # comment that isn't inside quotes.
+func stripComment(line string) string {
+	var quote rune
+	escaped := false
+	for i, c := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && c == '\\':
+			escaped = true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+
+	return line
+}
+
+func unquote(value string) (string, error) {
+	if len(value) < 2 {
+		return value, nil
+	}
+
+	switch value[0] {
+	case '"':
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted value %s", value)
+		}
+		return unquoted, nil
+	case '\'':
+		if value[len(value)-1] != '\'' {
+			return "", fmt.Errorf("invalid quoted value %s", value)
+		}
+		return value[1 : len(value)-1], nil
+	}
+
+	return value, nil
+}
@@ -0,0 +1,265 @@
+package config
+
+import (
+	"fmt"
+	"goairmon/business/services/applog"
+	"goairmon/business/services/backup"
+	"goairmon/business/services/identity"
+	"goairmon/business/services/proxy"
+	"goairmon/business/services/tlscert"
+	"goairmon/site"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A config key with its default, read from the environment, .env or the config file.
+type Setting struct {
+	Key     string
+	Default string
+	Doc     string
+	// Redacted when the config is printed
+	Secret bool
+	// Must be set, there's no sensible default
+	Required bool
+	apply    func(cfg *site.Config, val string) error
+}
+
+// Settings lists every key in the order they're printed by config check.
+var Settings = []*Setting{
+	{Key: "SERVER_ADDRESS", Default: ":3000", Doc: "address the web server listens on", apply: stringVal(func(cfg *site.Config, val string) {
+		cfg.Address = val
+	})},
+	{Key: "APP_COOKIE_KEY", Doc: "name of the session cookie", Required: true, apply: stringVal(func(cfg *site.Config, val string) {
+		cfg.AppCookieKey = val
+	})},
+	{Key: "COOKIE_STORE_ENCRYPTION", Doc: "key the session cookie is encrypted and invite links signed with", Secret: true, Required: true, apply: stringVal(func(cfg *site.Config, val string) {
+		cfg.CookieStoreEncryption = val
+	})},
+	{Key: "STORAGE_PATH", Doc: "directory readings, users and sessions are stored in", Required: true, apply: stringVal(func(cfg *site.Config, val string) {
+		cfg.StoragePath = val
+	})},
+	{Key: "SENSOR_POINT_COUNT", Default: "11520", Doc: "readings kept before they're archived, one is saved each poll and charts need 8 days of them", apply: intVal(1, 1000000, func(cfg *site.Config, val int) {
+		cfg.SensorPointCount = val
+	})},
+	{Key: "TIMEZONE", Default: "Local", Doc: "timezone charts and exports are shown in", apply: func(cfg *site.Config, val string) error {
+		loc, err := time.LoadLocation(val)
+		if err != nil {
+			return fmt.Errorf("unknown timezone %q", val)
+		}
+		cfg.Location = loc
+		return nil
+	}},
+	{Key: "ADMIN_SOCKET", Doc: "socket the CLI edits users through, defaults to STORAGE_PATH/goairmon.sock", apply: stringVal(func(cfg *site.Config, val string) {
+		cfg.AdminSocket = val
+	})},
+	{Key: "POLL_INTERVAL_SECS", Default: "60", Doc: "how often a reading is saved", apply: durationVal(1, 60*60, time.Second, func(cfg *site.Config, val time.Duration) {
+		cfg.PollInterval = val
+	})},
+	{Key: "SENSOR_READ_MILLIS", Default: "1000", Doc: "how often the sensor is measured, the SGP30 expects every second", apply: durationVal(100, 60*1000, time.Millisecond, func(cfg *site.Config, val time.Duration) {
+		cfg.SensorReadDelay = val
+	})},
+	{Key: "SENSOR_BASELINE_SECS", Default: "60", Doc: "how often the sensor baseline is saved", apply: durationVal(1, 24*60*60, time.Second, func(cfg *site.Config, val time.Duration) {
+		cfg.SensorBaselineDelay = val
+	})},
+	{Key: "CHART_FILL_PPM", Default: "400", Doc: "co2 charted for minutes without a reading", apply: func(cfg *site.Config, val string) error {
+		ppm, err := strconv.ParseFloat(val, 64)
+		if err != nil || ppm < 0 {
+			return fmt.Errorf("must be a positive number")
+		}
+		cfg.ChartFillCo2 = ppm
+		return nil
+	}},
+	{Key: "SESSION_EXPIRY_HOURS", Default: "672", Doc: "logins expire after this long unused", apply: durationVal(1, 24*365, time.Hour, func(cfg *site.Config, val time.Duration) {
+		cfg.SessionExpiry = val
+	})},
+	{Key: "SESSION_GC_MINS", Default: "60", Doc: "how often expired sessions are cleared", apply: durationVal(1, 24*60, time.Minute, func(cfg *site.Config, val time.Duration) {
+		cfg.SessionGCDelay = val
+	})},
+	{Key: "PUBLIC_DASHBOARD", Default: "false", Doc: "show the dashboard without logging in", apply: boolVal(func(cfg *site.Config, val bool) {
+		cfg.PublicDashboard = val
+	})},
+	{Key: "PUBLIC_DASHBOARD_NETWORKS", Doc: "comma separated CIDRs the public dashboard is open to, defaults to everyone", apply: networksVal(func(cfg *site.Config, val string) {
+		cfg.PublicNetworks = val
+	})},
+	{Key: "BASE_PATH", Doc: "path prefix when served under a reverse proxy, e.g. /airmon", apply: func(cfg *site.Config, val string) error {
+		if _, err := proxy.ParseBasePath(val); err != nil {
+			return err
+		}
+		cfg.BasePath = val
+		return nil
+	}},
+	{Key: "TRUSTED_PROXIES", Doc: "comma separated CIDRs whose X-Forwarded headers are trusted", apply: networksVal(func(cfg *site.Config, val string) {
+		cfg.TrustedProxies = val
+	})},
+	{Key: "COOKIE_SECURE", Default: "false", Doc: "only send cookies over HTTPS, implied by TLS_ENABLED", apply: boolVal(func(cfg *site.Config, val bool) {
+		cfg.CookieSecure = val
+	})},
+	{Key: "COOKIE_SAMESITE", Default: "lax", Doc: "lax, strict or none", apply: func(cfg *site.Config, val string) error {
+		if _, err := identity.ParseSameSite(val); err != nil {
+			return err
+		}
+		cfg.CookieSameSite = val
+		return nil
+	}},
+	{Key: "TLS_ENABLED", Default: "false", Doc: "serve HTTPS", apply: boolVal(func(cfg *site.Config, val bool) {
+		cfg.TLSEnabled = val
+	})},
+	{Key: "TLS_CERT", Doc: "certificate file, a self-signed one is made in STORAGE_PATH when empty", apply: stringVal(func(cfg *site.Config, val string) {
+		cfg.TLS.CertFile = val
+	})},
+	{Key: "TLS_KEY", Doc: "private key file for TLS_CERT", apply: stringVal(func(cfg *site.Config, val string) {
+		cfg.TLS.KeyFile = val
+	})},
+	{Key: "TLS_HOSTS", Doc: "comma separated hosts the self-signed certificate is for", apply: stringVal(func(cfg *site.Config, val string) {
+		cfg.TLS.Hosts = tlscert.ParseHosts(val)
+	})},
+	{Key: "TLS_REDIRECT_ADDRESS", Doc: "address redirected from HTTP to HTTPS, e.g. :80", apply: stringVal(func(cfg *site.Config, val string) {
+		cfg.RedirectAddress = val
+	})},
+	{Key: "SHUTDOWN_TIMEOUT_SECS", Default: "10", Doc: "how long requests in flight are waited for on shutdown", apply: durationVal(0, 10*60, time.Second, func(cfg *site.Config, val time.Duration) {
+		cfg.ShutdownTimeout = val
+	})},
+	{Key: "BACKUP_DIR", Doc: "scheduled backups are written here, off when empty", apply: stringVal(func(cfg *site.Config, val string) {
+		cfg.BackupDir = val
+	})},
+	{Key: "BACKUP_SCHEDULE", Default: "0 3 * * *", Doc: "cron schedule for backups", apply: func(cfg *site.Config, val string) error {
+		if _, err := backup.ParseSchedule(val); err != nil {
+			return err
+		}
+		cfg.BackupSchedule = val
+		return nil
+	}},
+	{Key: "BACKUP_KEEP_LAST", Default: "3", Doc: "most recent backups kept", apply: intVal(0, 1000, func(cfg *site.Config, val int) {
+		cfg.BackupRetention.KeepLast = val
+	})},
+	{Key: "BACKUP_KEEP_DAILY", Default: "7", Doc: "daily backups kept", apply: intVal(0, 1000, func(cfg *site.Config, val int) {
+		cfg.BackupRetention.KeepDaily = val
+	})},
+	{Key: "BACKUP_KEEP_WEEKLY", Default: "4", Doc: "weekly backups kept", apply: intVal(0, 1000, func(cfg *site.Config, val int) {
+		cfg.BackupRetention.KeepWeekly = val
+	})},
+	{Key: "LOGIN_MAX_ATTEMPTS", Default: "5", Doc: "failed logins before an account or address is locked out", apply: intVal(1, 1000, func(cfg *site.Config, val int) {
+		cfg.LoginLimit.MaxAttempts = val
+	})},
+	{Key: "LOGIN_LOCKOUT_SECS", Default: "60", Doc: "first lockout, doubled each time", apply: durationVal(1, 24*60*60, time.Second, func(cfg *site.Config, val time.Duration) {
+		cfg.LoginLimit.Lockout = val
+	})},
+	{Key: "LOGIN_MAX_LOCKOUT_SECS", Default: "3600", Doc: "longest lockout", apply: durationVal(1, 7*24*60*60, time.Second, func(cfg *site.Config, val time.Duration) {
+		cfg.LoginLimit.MaxLockout = val
+	})},
+	{Key: "LOGIN_RESET_SECS", Default: "86400", Doc: "failed logins are forgotten after this long", apply: durationVal(1, 30*24*60*60, time.Second, func(cfg *site.Config, val time.Duration) {
+		cfg.LoginLimit.Reset = val
+	})},
+	{Key: "PASSWORD_MIN_LENGTH", Default: "8", Doc: "shortest password allowed", apply: intVal(1, 1024, func(cfg *site.Config, val int) {
+		cfg.PasswordPolicy.MinLength = val
+	})},
+	{Key: "PASSWORD_HISTORY", Default: "3", Doc: "previous passwords that can't be reused", apply: intVal(0, 100, func(cfg *site.Config, val int) {
+		cfg.PasswordPolicy.History = val
+	})},
+	{Key: "PASSWORD_BCRYPT_COST", Default: "10", Doc: "bcrypt cost passwords are hashed with", apply: intVal(4, 31, func(cfg *site.Config, val int) {
+		cfg.PasswordPolicy.BcryptCost = val
+	})},
+	{Key: "PASSWORD_BLOCKLIST", Doc: "file of extra passwords to refuse, one per line", apply: stringVal(func(cfg *site.Config, val string) {
+		cfg.PasswordPolicy.BlocklistFile = val
+	})},
+	{Key: "INVITE_EXPIRY_HOURS", Default: "72", Doc: "invite links expire after this long", apply: durationVal(1, 24*365, time.Hour, func(cfg *site.Config, val time.Duration) {
+		cfg.InviteExpiry = val
+	})},
+	{Key: "AUDIT_LOG", Doc: "audit log file, defaults to STORAGE_PATH/goairmon_audit.log", apply: stringVal(func(cfg *site.Config, val string) {
+		cfg.Audit.File = val
+	})},
+	{Key: "AUDIT_MAX_KB", Default: "1024", Doc: "audit log size before it's rotated", apply: intVal(1, 1024*1024, func(cfg *site.Config, val int) {
+		cfg.Audit.MaxBytes = int64(val) * 1024
+	})},
+	{Key: "AUDIT_KEEP_FILES", Default: "5", Doc: "rotated audit logs kept", apply: intVal(0, 1000, func(cfg *site.Config, val int) {
+		cfg.Audit.MaxFiles = val
+	})},
+	{Key: "LOG_LEVEL", Default: "info", Doc: "debug, info, warn, error or off", apply: func(cfg *site.Config, val string) error {
+		if _, err := applog.ParseLevel(val); err != nil {
+			return err
+		}
+		cfg.Log.Level = val
+		return nil
+	}},
+	{Key: "LOG_FORMAT", Default: applog.FormatText, Doc: "text or json", apply: func(cfg *site.Config, val string) error {
+		val = strings.ToLower(val)
+		if val != applog.FormatText && val != applog.FormatJSON {
+			return fmt.Errorf("log format must be %s or %s", applog.FormatText, applog.FormatJSON)
+		}
+		cfg.Log.Format = val
+		return nil
+	}},
+	{Key: "LOG_FILE", Doc: "log file, logs go to stdout when empty", apply: stringVal(func(cfg *site.Config, val string) {
+		cfg.Log.File = val
+	})},
+	{Key: "LOG_MAX_KB", Default: "10240", Doc: "log file size before it's rotated", apply: intVal(1, 1024*1024, func(cfg *site.Config, val int) {
+		cfg.Log.MaxBytes = int64(val) * 1024
+	})},
+	{Key: "LOG_KEEP_FILES", Default: "5", Doc: "rotated log files kept", apply: intVal(0, 1000, func(cfg *site.Config, val int) {
+		cfg.Log.MaxFiles = val
+	})},
+}
+
+func lookupSetting(key string) *Setting {
+	for _, setting := range Settings {
+		if setting.Key == key {
+			return setting
+		}
+	}
+
+	return nil
+}
+
+func stringVal(set func(cfg *site.Config, val string)) func(cfg *site.Config, val string) error {
+	return func(cfg *site.Config, val string) error {
+		set(cfg, val)
+		return nil
+	}
+}
+
+func intVal(min int, max int, set func(cfg *site.Config, val int)) func(cfg *site.Config, val string) error {
+	return func(cfg *site.Config, val string) error {
+		intVal, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("must be a whole number")
+		}
+
+		if intVal < min || intVal > max {
+			return fmt.Errorf("must be between %d and %d", min, max)
+		}
+
+		set(cfg, intVal)
+		return nil
+	}
+}
+
+// durationVal reads a whole number of units, e.g. seconds for the _SECS keys.
+func durationVal(min int, max int, unit time.Duration, set func(cfg *site.Config, val time.Duration)) func(cfg *site.Config, val string) error {
+	return intVal(min, max, func(cfg *site.Config, val int) {
+		set(cfg, time.Duration(val)*unit)
+	})
+}
+
+func boolVal(set func(cfg *site.Config, val bool)) func(cfg *site.Config, val string) error {
+	return func(cfg *site.Config, val string) error {
+		boolVal, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+
+		set(cfg, boolVal)
+		return nil
+	}
+}
+
+func networksVal(set func(cfg *site.Config, val string)) func(cfg *site.Config, val string) error {
+	return func(cfg *site.Config, val string) error {
+		if _, err := identity.ParseNetworks(val); err != nil {
+			return err
+		}
+
+		set(cfg, strings.TrimSpace(val))
+		return nil
+	}
+}
@@ -0,0 +1,309 @@
+package controllers
+
+import (
+	"fmt"
+	"goairmon/business/data/context"
+	datamodels "goairmon/business/data/models"
+	"goairmon/business/services/audit"
+	"goairmon/business/services/backup"
+	"goairmon/business/services/identity"
+	"goairmon/business/services/loginlimit"
+	"goairmon/business/services/passpolicy"
+	"goairmon/business/services/useradmin"
+	"goairmon/site/helper"
+	"goairmon/site/models"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo"
+)
+
+// The audit page shows the newest matching entries, filter to see further back.
+const auditPageSize = 200
+
+func AdminController(server *echo.Echo, identity *identity.IdentityService) *echo.Group {
+	group := server.Group("admin", identity.RedirectUsersWithoutSession("/auth/login"), identity.RequireRole(datamodels.RoleAdmin))
+	group.GET("/backups", func(c echo.Context) error {
+		view := loadView("admin/backups.gohtml", c)
+		status := getBackupService(c).Status()
+
+		return view.Execute(c.Response().Writer, models.NewContextVm(c, &status))
+	})
+
+	group.POST("/backups/run", func(c echo.Context) error {
+		var err error
+		if path, runErr := getBackupService(c).RunNow(); runErr != nil {
+			err = getFlashService(c).PushError(c, "Backup failed: "+runErr.Error())
+			recordAudit(c, audit.Entry{Event: audit.EventBackupFailed, Details: runErr.Error()})
+		} else {
+			err = getFlashService(c).PushSuccess(c, "Backup saved to "+path)
+			recordAudit(c, audit.Entry{Event: audit.EventBackup, Details: path})
+		}
+
+		if err != nil {
+			getLogger(c, "admin").Error(err)
+		}
+
+		return c.Redirect(http.StatusSeeOther, "/admin/backups")
+	})
+
+	group.GET("/users", func(c echo.Context) error {
+		return renderUsers(c, models.NewContextVm(c, nil), &models.UserFormVm{Role: datamodels.RoleViewer})
+	})
+
+	group.POST("/users", func(c echo.Context) error {
+		formVM := models.UnmarshalUserFormVm(c)
+		if errs := formVM.Validate(getDbContext(c), getPasswordPolicy(c)); errs.Fails() {
+			vm := models.NewContextVm(c, nil)
+			vm.Errors.Merge(errs)
+
+			return renderUsers(c, vm, formVM)
+		}
+
+		message, err := addUser(getDbContext(c), getPasswordPolicy(c), formVM)
+		auditResult(c, audit.EventUserAdded, message, err)
+
+		return redirectWithResult(c, "/admin/users", message, err)
+	})
+
+	group.POST("/users/:id/password", func(c echo.Context) error {
+		message, err := resetPassword(getDbContext(c), getPasswordPolicy(c), identity, c.Param("id"), c.FormValue("password"), currentSessionID(c))
+		auditResult(c, audit.EventUserChanged, message, err)
+
+		return redirectWithResult(c, "/admin/users", message, err)
+	})
+
+	group.POST("/users/:id/role", func(c echo.Context) error {
+		currentUser := models.NewContextVm(c, nil).UserName
+		message, err := changeRole(getDbContext(c), c.Param("id"), c.FormValue("role"), currentUser)
+		auditResult(c, audit.EventUserChanged, message, err)
+
+		return redirectWithResult(c, "/admin/users", message, err)
+	})
+
+	group.POST("/users/:id/clear-lockout", func(c echo.Context) error {
+		user, err := findUser(getDbContext(c), c.Param("id"))
+		if err != nil {
+			return redirectWithResult(c, "/admin/users", "", err)
+		}
+
+		getLoginLimiter(c).Unlock(user.Username)
+
+		message := "Cleared failed logins for " + user.Username
+		auditResult(c, audit.EventUserChanged, message, nil)
+
+		return redirectWithResult(c, "/admin/users", message, nil)
+	})
+
+	group.POST("/users/:id/delete", func(c echo.Context) error {
+		currentUser := models.NewContextVm(c, nil).UserName
+		message, err := deleteUser(getDbContext(c), identity, c.Param("id"), currentUser)
+		auditResult(c, audit.EventUserRemoved, message, err)
+
+		return redirectWithResult(c, "/admin/users", message, err)
+	})
+
+	group.POST("/invites", func(c echo.Context) error {
+		token, invite, err := getInviteService(c).Create(c.FormValue("role"), currentUser(c).Username)
+		if err != nil {
+			return redirectWithResult(c, "/admin/users", "", err)
+		}
+
+		recordAudit(c, audit.Entry{Event: audit.EventInvite, Details: "invited a new " + invite.Role})
+		link := siteURL(c, "/auth/invite/"+token)
+		message := fmt.Sprintf("Send this %s invite link, it works once until %s: %s", invite.Role, invite.ExpiresAt.Format("Mon Jan 2 15:04"), link)
+
+		return redirectWithResult(c, "/admin/users", message, nil)
+	})
+
+	group.POST("/invites/:id/revoke", func(c echo.Context) error {
+		id, err := uuid.Parse(c.Param("id"))
+		if err == nil {
+			err = getInviteService(c).Revoke(id)
+		}
+
+		if err != nil {
+			return redirectWithResult(c, "/admin/users", "", fmt.Errorf("invite not found"))
+		}
+
+		recordAudit(c, audit.Entry{Event: audit.EventInvite, Details: "revoked an invite"})
+
+		return redirectWithResult(c, "/admin/users", "Revoked invite", nil)
+	})
+
+	group.GET("/audit", func(c echo.Context) error {
+		filter := audit.Filter{
+			Event: c.QueryParam("event"),
+			Actor: c.QueryParam("actor"),
+			Text:  c.QueryParam("q"),
+			Limit: auditPageSize,
+		}
+
+		entries, err := getAuditLog(c).Query(filter)
+		if err != nil {
+			return err
+		}
+
+		view := loadView("admin/audit.gohtml", c)
+		vm := &models.AuditVm{Entries: entries, Events: audit.Events, Filter: filter}
+
+		return view.Execute(c.Response().Writer, models.NewContextVm(c, vm))
+	})
+
+	return group
+}
+
+// Records a successful change, the message says what was changed.
+func auditResult(c echo.Context, event string, message string, err error) {
+	if err == nil {
+		recordAudit(c, audit.Entry{Event: event, Details: message})
+	}
+}
+
+func renderUsers(c echo.Context, vm *models.ContextVm, formVM *models.UserFormVm) error {
+	users, err := getDbContext(c).GetUsers()
+	if err != nil {
+		return err
+	}
+
+	limiter := getLoginLimiter(c)
+	logins := map[string]*loginlimit.Status{}
+	for _, user := range users {
+		status := limiter.UserStatus(user.Username)
+		if status.Failures == 0 {
+			continue
+		}
+
+		if !status.Locked(limiter.Now()) {
+			status.LockedUntil = time.Time{}
+		}
+		logins[user.Username] = &status
+	}
+
+	invites, err := getInviteService(c).Pending()
+	if err != nil {
+		return err
+	}
+
+	vm.ViewModel = &models.UsersVm{Users: users, Roles: datamodels.Roles, Form: formVM, Logins: logins, Invites: invites}
+	view := loadView("admin/users.gohtml", c)
+
+	return view.Execute(c.Response().Writer, vm)
+}
+
+func addUser(dbContext context.DbContext, policy *passpolicy.Policy, formVM *models.UserFormVm) (string, error) {
+	user := &datamodels.User{Username: formVM.Username, Timezone: formVM.Timezone, Role: formVM.Role}
+	if err := policy.SetPassword(user, formVM.Password); err != nil {
+		return "", err
+	}
+
+	if err := dbContext.CreateOrUpdateUser(user); err != nil {
+		return "", fmt.Errorf("failed to add user: %s", err)
+	}
+
+	return "Added user " + user.Username, dbContext.Save()
+}
+
+// Resets the password and logs the user out everywhere except the admin's own session.
+func resetPassword(dbContext context.DbContext, policy *passpolicy.Policy, identity *identity.IdentityService, id string, password string, keepSessionID string) (string, error) {
+	user, err := findUser(dbContext, id)
+	if err != nil {
+		return "", err
+	}
+
+	if err := policy.SetPassword(user, password); err != nil {
+		return "", err
+	}
+
+	if err := dbContext.CreateOrUpdateUser(user); err != nil {
+		return "", fmt.Errorf("failed to update user: %s", err)
+	}
+
+	if err := dbContext.Save(); err != nil {
+		return "", err
+	}
+
+	ended := identity.RemoveUserSessions(user.ID.String(), keepSessionID)
+
+	return fmt.Sprintf("Reset password for %s and ended %d sessions", user.Username, ended), nil
+}
+
+func changeRole(dbContext context.DbContext, id string, role string, currentUser string) (string, error) {
+	user, err := findUser(dbContext, id)
+	if err != nil {
+		return "", err
+	}
+
+	if user.Username == currentUser {
+		return "", fmt.Errorf("you can't change your own role")
+	}
+
+	if err := useradmin.ValidateRole(role); err != nil {
+		return "", err
+	}
+
+	user.Role = role
+	if err := dbContext.CreateOrUpdateUser(user); err != nil {
+		return "", fmt.Errorf("failed to update user: %s", err)
+	}
+
+	return fmt.Sprintf("Made %s %s", user.Username, user.Role), dbContext.Save()
+}
+
+func deleteUser(dbContext context.DbContext, identity *identity.IdentityService, id string, currentUser string) (string, error) {
+	user, err := findUser(dbContext, id)
+	if err != nil {
+		return "", err
+	}
+
+	if user.Username == currentUser {
+		return "", fmt.Errorf("you can't delete yourself")
+	}
+
+	if err := dbContext.DeleteUser(user.ID); err != nil {
+		return "", fmt.Errorf("failed to delete user: %s", err)
+	}
+
+	identity.RemoveUserSessions(user.ID.String())
+
+	return "Deleted user " + user.Username, dbContext.Save()
+}
+
+func findUser(dbContext context.DbContext, id string) (*datamodels.User, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	user, err := dbContext.FindUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	return user, nil
+}
+
+// Flashes the message, or the error if there is one, then redirects.
+func redirectWithResult(c echo.Context, path string, message string, err error) error {
+	var flashErr error
+	if err != nil {
+		flashErr = getFlashService(c).PushError(c, err.Error())
+	} else {
+		flashErr = getFlashService(c).PushSuccess(c, message)
+	}
+
+	if flashErr != nil {
+		getLogger(c, "admin").Error(flashErr)
+	}
+
+	return c.Redirect(http.StatusSeeOther, path)
+}
+
+func getDbContext(c echo.Context) context.DbContext {
+	return c.Get(helper.CtxDbContext).(context.DbContext)
+}
+
+func getBackupService(c echo.Context) *backup.BackupService {
+	return c.Get(helper.CtxBackupService).(*backup.BackupService)
+}
@@ -3,14 +3,16 @@ package controllers
 import (
 	"fmt"
 	"goairmon/business/data/context"
+	datamodels "goairmon/business/data/models"
+	"goairmon/business/services/audit"
 	"goairmon/business/services/identity"
-	"goairmon/site/helper"
+	"goairmon/business/services/totp"
 	"goairmon/site/models"
-	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo"
-	"github.com/op/go-logging"
 )
 
 func AuthController(server *echo.Echo, identity *identity.IdentityService) *echo.Group {
@@ -24,51 +26,338 @@ func AuthController(server *echo.Echo, identity *identity.IdentityService) *echo
 	group.POST("/login", func(c echo.Context) error {
 		loginVM := models.UnmarshalLoginVm(c)
 
-		if err := loginUser(c, identity, loginVM); err != nil {
+		if err := getLoginLimiter(c).Attempt(clientIP(c), loginVM.Username); err != nil {
+			view := loadView("auth/login.gohtml", c)
+			vm := models.NewContextVm(c, loginVM)
+			vm.Errors["general"] = "Failed to log in, " + err.Error()
+
+			recordAudit(c, audit.Entry{Event: audit.EventLoginFailed, Actor: loginVM.Username, Details: err.Error()})
+
+			return view.Execute(c.Response().Writer, vm)
+		}
+
+		user, err := checkLogin(c, loginVM)
+		if err == nil && user.TwoFactorEnabled() {
+			if err = identity.StartPendingLogin(c, user.ID.String()); err == nil {
+				return c.Redirect(http.StatusSeeOther, "/auth/login/2fa")
+			}
+		}
+
+		if err == nil {
+			err = startUserSession(c, identity, user)
+		}
+
+		if err != nil {
 			view := loadView("auth/login.gohtml", c)
 			vm := models.NewContextVm(c, loginVM)
 			vm.Errors["general"] = "Failed to log in"
 
-			logging.MustGetLogger("goairmon").Error(err)
+			recordAudit(c, audit.Entry{Event: audit.EventLoginFailed, Actor: loginVM.Username, Details: err.Error()})
 
 			return view.Execute(c.Response().Writer, vm)
 		}
 
-		err := getFlashService(c).PushSuccess(c, "Successfully logged in!")
+		if err := getFlashService(c).PushSuccess(c, "Successfully logged in!"); err != nil {
+			getLogger(c, "auth").Error(err)
+		}
+
+		return c.Redirect(http.StatusSeeOther, "/")
+	}, identity.RedirectUsersWithSession("/"))
+
+	group.GET("/login/2fa", func(c echo.Context) error {
+		if _, ok := identity.PendingLogin(c); !ok {
+			return c.Redirect(http.StatusSeeOther, "/auth/login")
+		}
+
+		view := loadView("auth/twofactor.gohtml", c)
+
+		return view.Execute(c.Response().Writer, models.NewContextVm(c, nil))
+	}, identity.RedirectUsersWithSession("/"))
+
+	group.POST("/login/2fa", func(c echo.Context) error {
+		userID, ok := identity.PendingLogin(c)
+		if !ok {
+			return redirectWithResult(c, "/auth/login", "", fmt.Errorf("Login timed out, please try again"))
+		}
+
+		user, err := checkTwoFactor(c, userID, c.FormValue("code"))
+		if err == nil {
+			_ = identity.ClearPendingLogin(c)
+			err = startUserSession(c, identity, user)
+		}
+
 		if err != nil {
-			log.Println(err)
+			view := loadView("auth/twofactor.gohtml", c)
+			vm := models.NewContextVm(c, nil)
+			vm.Errors["general"] = "Failed to log in, " + err.Error()
+
+			recordAudit(c, audit.Entry{Event: audit.EventLoginFailed, Actor: pendingUsername(c, userID), Details: "two factor: " + err.Error()})
+
+			return view.Execute(c.Response().Writer, vm)
+		}
+
+		if err := getFlashService(c).PushSuccess(c, "Successfully logged in!"); err != nil {
+			getLogger(c, "auth").Error(err)
 		}
 
 		return c.Redirect(http.StatusSeeOther, "/")
 	}, identity.RedirectUsersWithSession("/"))
 
+	group.GET("/invite/:token", func(c echo.Context) error {
+		invite, err := getInviteService(c).Find(c.Param("token"))
+		if err != nil {
+			return redirectWithResult(c, "/auth/login", "", err)
+		}
+
+		return renderInvite(c, models.NewContextVm(c, nil), &models.UserFormVm{Role: invite.Role})
+	}, identity.RedirectUsersWithSession("/"))
+
+	group.POST("/invite/:token", func(c echo.Context) error {
+		invite, err := getInviteService(c).Find(c.Param("token"))
+		if err != nil {
+			return redirectWithResult(c, "/auth/login", "", err)
+		}
+
+		formVM := models.UnmarshalUserFormVm(c)
+		formVM.Role = invite.Role
+		if errs := formVM.Validate(getDbContext(c), getPasswordPolicy(c)); errs.Fails() {
+			vm := models.NewContextVm(c, nil)
+			vm.Errors.Merge(errs)
+
+			return renderInvite(c, vm, formVM)
+		}
+
+		user := &datamodels.User{Username: formVM.Username, Timezone: formVM.Timezone}
+		if err := getPasswordPolicy(c).SetPassword(user, formVM.Password); err != nil {
+			return redirectWithResult(c, "/auth/login", "", err)
+		}
+
+		if invite, err = getInviteService(c).Accept(c.Param("token"), user); err != nil {
+			vm := models.NewContextVm(c, nil)
+			vm.Errors["general"] = err.Error()
+
+			return renderInvite(c, vm, formVM)
+		}
+
+		recordAudit(c, audit.Entry{Event: audit.EventUserAdded, Actor: user.Username, Details: fmt.Sprintf("Accepted %s invite from %s", invite.Role, invite.CreatedBy)})
+
+		return redirectWithResult(c, "/auth/login", "Account created, you can log in now", nil)
+	}, identity.RedirectUsersWithSession("/"))
+
 	group.POST("/logout", func(c echo.Context) error {
+		recordAudit(c, audit.Entry{Event: audit.EventLogout})
 		_ = identity.EndSession(c)
 
+		// Only site paths, which get the base path added, so the form can't send users elsewhere
 		returnPath := c.FormValue("referer")
-		if returnPath == "" {
+		if !strings.HasPrefix(returnPath, "/") || strings.HasPrefix(returnPath, "//") {
 			returnPath = "/"
 		}
 
 		return c.Redirect(http.StatusSeeOther, returnPath)
 	}, identity.RedirectUsersWithoutSession("/"))
 
+	group.GET("/sessions", func(c echo.Context) error {
+		view := loadView("auth/sessions.gohtml", c)
+		vm := &models.SessionsVm{
+			Sessions:  identity.UserSessions(currentUser(c).ID.String()),
+			CurrentID: currentSessionID(c),
+		}
+
+		return view.Execute(c.Response().Writer, models.NewContextVm(c, vm))
+	}, identity.RedirectUsersWithoutSession("/auth/login"), identity.RequireRole(datamodels.RoleViewer))
+
+	group.POST("/sessions/:id/revoke", func(c echo.Context) error {
+		if c.Param("id") == currentSessionID(c) {
+			recordAudit(c, audit.Entry{Event: audit.EventLogout})
+			_ = identity.EndSession(c)
+			return c.Redirect(http.StatusSeeOther, "/auth/login")
+		}
+
+		err := identity.RemoveUserSession(currentUser(c).ID.String(), c.Param("id"))
+		if err == nil {
+			recordAudit(c, audit.Entry{Event: audit.EventLogout, Details: "ended another session"})
+		}
+
+		return redirectWithResult(c, "/auth/sessions", "Logged out session", err)
+	}, identity.RedirectUsersWithoutSession("/auth/login"), identity.RequireRole(datamodels.RoleViewer))
+
+	group.POST("/sessions/revoke-others", func(c echo.Context) error {
+		ended := identity.RemoveUserSessions(currentUser(c).ID.String(), currentSessionID(c))
+		recordAudit(c, audit.Entry{Event: audit.EventLogout, Details: fmt.Sprintf("ended %d other sessions", ended)})
+
+		return redirectWithResult(c, "/auth/sessions", fmt.Sprintf("Logged out %d other sessions", ended), nil)
+	}, identity.RedirectUsersWithoutSession("/auth/login"), identity.RequireRole(datamodels.RoleViewer))
+
+	group.GET("/password", func(c echo.Context) error {
+		view := loadView("auth/password.gohtml", c)
+
+		return view.Execute(c.Response().Writer, models.NewContextVm(c, nil))
+	}, identity.RedirectUsersWithoutSession("/auth/login"), identity.RequireRole(datamodels.RoleViewer))
+
+	group.POST("/password", func(c echo.Context) error {
+		user := currentUser(c)
+		formVM := models.UnmarshalChangePasswordVm(c)
+		if err := checkCurrentPassword(c, user, formVM.CurrentPassword); err != nil {
+			view := loadView("auth/password.gohtml", c)
+			vm := models.NewContextVm(c, nil)
+			vm.Errors["current_password"] = err.Error()
+
+			recordAudit(c, audit.Entry{Event: audit.EventLoginFailed, Details: "change password: " + err.Error()})
+
+			return view.Execute(c.Response().Writer, vm)
+		}
+
+		if errs := formVM.Validate(user, getPasswordPolicy(c)); errs.Fails() {
+			view := loadView("auth/password.gohtml", c)
+			vm := models.NewContextVm(c, nil)
+			vm.Errors.Merge(errs)
+
+			return view.Execute(c.Response().Writer, vm)
+		}
+
+		if err := getPasswordPolicy(c).SetPassword(user, formVM.Password); err != nil {
+			return redirectWithResult(c, "/auth/password", "", err)
+		}
+
+		if err := saveUser(getDbContext(c), user); err != nil {
+			return redirectWithResult(c, "/auth/password", "", err)
+		}
+
+		ended := identity.RemoveUserSessions(user.ID.String(), currentSessionID(c))
+		recordAudit(c, audit.Entry{Event: audit.EventUserChanged, Details: "changed own password"})
+
+		return redirectWithResult(c, "/auth/password", fmt.Sprintf("Changed password, logged out %d other sessions", ended), nil)
+	}, identity.RedirectUsersWithoutSession("/auth/login"), identity.RequireRole(datamodels.RoleViewer))
+
 	return group
 }
 
-func loginUser(c echo.Context, identity *identity.IdentityService, loginVM *models.LoginVm) error {
-	dbContext := c.Get(helper.CtxDbContext).(context.DbContext)
-	user, err := dbContext.FindUserByName(loginVM.Username)
+func renderInvite(c echo.Context, vm *models.ContextVm, formVM *models.UserFormVm) error {
+	vm.ViewModel = formVM
+	view := loadView("auth/invite.gohtml", c)
+
+	return view.Execute(c.Response().Writer, vm)
+}
+
+// Checks the password for an attempt already counted by the limiter, only clearing it
+// for users who don't still need their second factor.
+func checkLogin(c echo.Context, loginVM *models.LoginVm) (*datamodels.User, error) {
+	user, err := getDbContext(c).FindUserByName(loginVM.Username)
 	if err != nil || !user.CheckPassword(loginVM.Password) {
-		return fmt.Errorf("invalid username or password")
+		return nil, lockoutError(c, loginVM.Username, fmt.Errorf("invalid username or password"))
+	}
+
+	if user.Locked {
+		return nil, fmt.Errorf("user %s is locked", user.Username)
+	}
+
+	if !user.TwoFactorEnabled() {
+		getLoginLimiter(c).Succeed(clientIP(c), loginVM.Username)
+	}
+
+	return user, nil
+}
+
+// Checks a logged in user's password through the limiter, so a stolen session can't be used to guess it.
+func checkCurrentPassword(c echo.Context, user *datamodels.User, password string) error {
+	if err := getLoginLimiter(c).Attempt(clientIP(c), user.Username); err != nil {
+		return err
+	}
+
+	if !user.CheckPassword(password) {
+		return lockoutError(c, user.Username, fmt.Errorf("current password is incorrect"))
+	}
+
+	getLoginLimiter(c).Succeed(clientIP(c), user.Username)
+
+	return nil
+}
+
+// Checks the pending user's TOTP or recovery code, saving the user so codes can't be reused.
+func checkTwoFactor(c echo.Context, userID string, code string) (*datamodels.User, error) {
+	user, err := findUser(getDbContext(c), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	recoveryCodes := len(user.RecoveryCodes)
+	if err := checkTwoFactorCode(c, user, code); err != nil {
+		return nil, err
 	}
 
+	if err := saveUser(getDbContext(c), user); err != nil {
+		return nil, err
+	}
+
+	if len(user.RecoveryCodes) < recoveryCodes {
+		if err := getFlashService(c).PushError(c, fmt.Sprintf("Used a recovery code, %d left", len(user.RecoveryCodes))); err != nil {
+			getLogger(c, "auth").Error(err)
+		}
+	}
+
+	return user, nil
+}
+
+// Checks a TOTP or recovery code through the limiter, using up the recovery code.
+func checkTwoFactorCode(c echo.Context, user *datamodels.User, code string) error {
+	if err := getLoginLimiter(c).AttemptSecondFactor(clientIP(c), user.Username); err != nil {
+		return err
+	}
+
+	if user.Locked || !totp.Verify(user, code, time.Now()) {
+		return lockoutError(c, user.Username, fmt.Errorf("invalid code"))
+	}
+
+	getLoginLimiter(c).SucceedSecondFactor(clientIP(c), user.Username)
+
+	return nil
+}
+
+// Notes in the failure when it has locked the username out, so the audit log shows it.
+func lockoutError(c echo.Context, username string, err error) error {
+	limiter := getLoginLimiter(c)
+	if status := limiter.UserStatus(username); status.Locked(limiter.Now()) {
+		return fmt.Errorf("%s, locked out after %d failures", err, status.Failures)
+	}
+
+	return err
+}
+
+func startUserSession(c echo.Context, identity *identity.IdentityService, user *datamodels.User) error {
 	session, err := identity.StartNewSession(c)
 	if err != nil {
 		return fmt.Errorf("oops! something went wrong")
 	}
 
-	session.Values["user_name"] = user.Username
+	if err := identity.LoginUser(c, session, user.ID.String()); err != nil {
+		_ = identity.EndSession(c)
+		return fmt.Errorf("oops! something went wrong")
+	}
+
+	recordAudit(c, audit.Entry{Event: audit.EventLogin, Actor: user.Username})
+
+	user.LastLogin = time.Now()
+	if err := getDbContext(c).CreateOrUpdateUser(user); err != nil {
+		getLogger(c, "auth").Error("failed to update last login", err)
+	}
 
 	return nil
 }
+
+// Names the user for the audit log, falling back to their ID if they're gone.
+func pendingUsername(c echo.Context, userID string) string {
+	if user, err := findUser(getDbContext(c), userID); err == nil {
+		return user.Username
+	}
+
+	return userID
+}
+
+func saveUser(dbContext context.DbContext, user *datamodels.User) error {
+	if err := dbContext.CreateOrUpdateUser(user); err != nil {
+		return fmt.Errorf("failed to update user: %s", err)
+	}
+
+	return dbContext.Save()
+}
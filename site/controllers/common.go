@@ -2,7 +2,16 @@ package controllers
 
 import (
 	"fmt"
+	datamodels "goairmon/business/data/models"
+	"goairmon/business/services/applog"
+	"goairmon/business/services/audit"
 	"goairmon/business/services/flash"
+	"goairmon/business/services/health"
+	"goairmon/business/services/identity"
+	"goairmon/business/services/invite"
+	"goairmon/business/services/loginlimit"
+	"goairmon/business/services/passpolicy"
+	"goairmon/business/services/session"
 	"goairmon/business/services/viewloader"
 	"goairmon/site/helper"
 	"html/template"
@@ -26,3 +35,67 @@ func loadView(viewPath string, c echo.Context) *template.Template {
 func getFlashService(c echo.Context) *flash.FlashService {
 	return c.Get(CtxFlashServiceKey).(*flash.FlashService)
 }
+
+func getLoginLimiter(c echo.Context) *loginlimit.LoginLimiter {
+	return c.Get(helper.CtxLoginLimiter).(*loginlimit.LoginLimiter)
+}
+
+func getPasswordPolicy(c echo.Context) *passpolicy.Policy {
+	return c.Get(helper.CtxPasswordPolicy).(*passpolicy.Policy)
+}
+
+func getAuditLog(c echo.Context) *audit.AuditLog {
+	return c.Get(helper.CtxAuditLog).(*audit.AuditLog)
+}
+
+// Records the event from the request's IP, as the current user unless the entry names an actor.
+func recordAudit(c echo.Context, entry audit.Entry) {
+	if entry.Actor == "" {
+		if user := currentUser(c); user != nil {
+			entry.Actor = user.Username
+		}
+	}
+	entry.IP = clientIP(c)
+
+	if err := getAuditLog(c).Record(entry); err != nil {
+		getLogger(c, "audit").Error("failed to record audit entry", err)
+	}
+}
+
+// The peer address, or the client behind a trusted proxy.
+func clientIP(c echo.Context) string {
+	return identity.ClientIP(c)
+}
+
+func getInviteService(c echo.Context) *invite.InviteService {
+	return c.Get(helper.CtxInviteService).(*invite.InviteService)
+}
+
+func getHealthService(c echo.Context) *health.HealthService {
+	return c.Get(helper.CtxHealthService).(*health.HealthService)
+}
+
+// getLogger tags entries with the subsystem, e.g. auth.
+func getLogger(c echo.Context, subsystem string) echo.Logger {
+	return c.Get(helper.CtxLogger).(*applog.Logger).Subsystem(subsystem)
+}
+
+// Absolute URL of a site path for links sent outside the site, like invites.
+func siteURL(c echo.Context, path string) string {
+	basePath, _ := c.Get(helper.CtxBasePath).(string)
+
+	return c.Scheme() + "://" + c.Request().Host + basePath + path
+}
+
+func currentSessionID(c echo.Context) string {
+	if sess, ok := c.Get(helper.CtxServerSession).(*session.Session); ok && sess != nil {
+		return sess.Id
+	}
+
+	return ""
+}
+
+func currentUser(c echo.Context) *datamodels.User {
+	user, _ := c.Get(helper.CtxCurrentUser).(*datamodels.User)
+	return user
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"fmt"
+	datamodels "goairmon/business/data/models"
+	"goairmon/business/services/export"
+	"goairmon/business/services/identity"
+	"goairmon/site/helper"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+func ExportController(server *echo.Echo, identity *identity.IdentityService) *echo.Group {
+	group := server.Group("export")
+	group.GET("", func(c echo.Context) error {
+		exporter := c.Get(helper.CtxExporter).(*export.Exporter)
+		loc, ok := c.Get(helper.CtxLocation).(*time.Location)
+		if !ok || loc == nil {
+			loc = time.Local
+		}
+
+		query, err := parseExportQuery(c, loc)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+
+		fileName := fmt.Sprintf("goairmon_export_%s.%s", time.Now().In(loc).Format("2006_01_02"), query.Format)
+		c.Response().Header().Set(echo.HeaderContentType, exporter.ContentType(query.Format))
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", fileName))
+		c.Response().WriteHeader(http.StatusOK)
+
+		if err := exporter.Export(c.Response(), query); err != nil {
+			getLogger(c, "storage").Error("failed to export points", err)
+		}
+
+		return nil
+	}, identity.RedirectUsersWithoutSession("/auth/login"), identity.RequireRole(datamodels.RoleViewer))
+
+	return group
+}
+
+func parseExportQuery(c echo.Context, loc *time.Location) (*export.Query, error) {
+	query := &export.Query{
+		Format:      c.QueryParam("format"),
+		IncludeTVOC: c.QueryParam("tvoc") == "1" || c.QueryParam("tvoc") == "true",
+	}
+
+	if query.Format == "" {
+		query.Format = export.FormatCSV
+	}
+
+	var err error
+	if query.From, err = export.ParseTime(c.QueryParam("from"), loc); err != nil {
+		return nil, err
+	}
+
+	if query.To, err = export.ParseTime(c.QueryParam("to"), loc); err != nil {
+		return nil, err
+	}
+
+	if interval := c.QueryParam("interval"); interval != "" {
+		if query.Interval, err = time.ParseDuration(interval); err != nil {
+			return nil, fmt.Errorf("invalid interval %q", interval)
+		}
+	}
+
+	return query, query.Validate()
+}
@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"goairmon/business/services/health"
+	"goairmon/business/services/identity"
+	"net/http"
+
+	"github.com/labstack/echo"
+)
+
+// HealthController serves unauthenticated health checks for monitoring and service managers.
+func HealthController(server *echo.Echo, identity *identity.IdentityService) *echo.Group {
+	group := server.Group("")
+	group.GET("/healthz", func(c echo.Context) error {
+		return healthResponse(c, getHealthService(c).Liveness())
+	})
+
+	group.GET("/readyz", func(c echo.Context) error {
+		return healthResponse(c, getHealthService(c).Readiness())
+	})
+
+	return group
+}
+
+func healthResponse(c echo.Context, report health.Report) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+	if !report.OK {
+		return c.JSON(http.StatusServiceUnavailable, report)
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
@@ -1,8 +1,13 @@
 package controllers
 
 import (
+	"goairmon/business/data/context"
+	datamodels "goairmon/business/data/models"
+	"goairmon/business/services/audit"
 	"goairmon/business/services/identity"
+	"goairmon/site/helper"
 	"goairmon/site/models"
+	"net/http"
 
 	"github.com/labstack/echo"
 )
@@ -13,7 +18,23 @@ func HomeController(server *echo.Echo, identity *identity.IdentityService) *echo
 		view := loadView("home/index.gohtml", c)
 
 		return view.Execute(c.Response().Writer, models.NewContextVm(c, nil))
-	}, identity.RedirectUsersWithoutSession("/auth/login"))
+	}, identity.AllowPublic(identity.RedirectUsersWithoutSession("/auth/login"), identity.RequireRole(datamodels.RoleViewer)))
+
+	group.POST("/points/clear", func(c echo.Context) error {
+		var err error
+		if clearErr := c.Get(helper.CtxDbContext).(context.DbContext).ClearSensorPoints(); clearErr != nil {
+			err = getFlashService(c).PushError(c, "Failed to clear points: "+clearErr.Error())
+		} else {
+			err = getFlashService(c).PushSuccess(c, "Cleared sensor points")
+			recordAudit(c, audit.Entry{Event: audit.EventPointsCleared})
+		}
+
+		if err != nil {
+			getLogger(c, "storage").Error(err)
+		}
+
+		return c.Redirect(http.StatusSeeOther, "/")
+	}, identity.RedirectUsersWithoutSession("/auth/login"), identity.RequireRole(datamodels.RoleOperator))
 
 	return group
 }
@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"fmt"
+	datamodels "goairmon/business/data/models"
+	"goairmon/business/services/audit"
+	"goairmon/business/services/identity"
+	"goairmon/business/services/totp"
+	"goairmon/site/models"
+	"html/template"
+	"time"
+
+	"github.com/labstack/echo"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	totpIssuer = "GoAirMon"
+	// The secret being enrolled is kept in the server session until a code from it is confirmed
+	enrollmentSecretKey = "totp_enrollment_secret"
+)
+
+func TwoFactorController(server *echo.Echo, identity *identity.IdentityService) *echo.Group {
+	group := server.Group("auth/2fa", identity.RedirectUsersWithoutSession("/auth/login"), identity.RequireRole(datamodels.RoleViewer))
+	group.GET("", func(c echo.Context) error {
+		user := currentUser(c)
+		vm := &models.TwoFactorVm{Enabled: user.TwoFactorEnabled(), RecoveryCodesLeft: len(user.RecoveryCodes)}
+
+		if !vm.Enabled {
+			secret, err := totp.GenerateSecret()
+			if err != nil {
+				return err
+			}
+
+			if err := identity.SetSessionValue(c, enrollmentSecretKey, secret); err != nil {
+				return err
+			}
+
+			if err := setEnrollment(vm, user, secret); err != nil {
+				return err
+			}
+		}
+
+		return renderTwoFactor(c, vm)
+	})
+
+	group.POST("/enable", func(c echo.Context) error {
+		user := currentUser(c)
+		if user.TwoFactorEnabled() {
+			return redirectWithResult(c, "/auth/2fa", "", fmt.Errorf("two factor is already enabled"))
+		}
+
+		secret := identity.SessionValue(c, enrollmentSecretKey)
+		if secret == "" {
+			return redirectWithResult(c, "/auth/2fa", "", fmt.Errorf("enrollment expired, scan the new code and try again"))
+		}
+
+		if _, ok := totp.Check(secret, c.FormValue("code"), time.Now()); !ok {
+			vm := &models.TwoFactorVm{}
+			if err := setEnrollment(vm, user, secret); err != nil {
+				return redirectWithResult(c, "/auth/2fa", "", err)
+			}
+
+			cvm := models.NewContextVm(c, vm)
+			cvm.Errors["code"] = "Code didn't match, check your device's clock and try again"
+
+			return renderTwoFactorVm(c, cvm)
+		}
+
+		codes, err := totp.Enable(user, secret, time.Now())
+		if err == nil {
+			err = saveUser(getDbContext(c), user)
+		}
+		if err != nil {
+			return redirectWithResult(c, "/auth/2fa", "", err)
+		}
+
+		if err := identity.SetSessionValue(c, enrollmentSecretKey, ""); err != nil {
+			getLogger(c, "auth").Error("failed to clear two factor enrollment", err)
+		}
+
+		recordAudit(c, audit.Entry{Event: audit.EventUserChanged, Details: "enabled two factor"})
+
+		return renderTwoFactor(c, &models.TwoFactorVm{Enabled: true, RecoveryCodesLeft: len(codes), RecoveryCodes: codes})
+	})
+
+	group.POST("/disable", func(c echo.Context) error {
+		user := currentUser(c)
+		if !user.TwoFactorEnabled() {
+			return redirectWithResult(c, "/auth/2fa", "", fmt.Errorf("two factor is already disabled"))
+		}
+
+		err := checkCurrentPassword(c, user, c.FormValue("password"))
+		if err == nil {
+			err = checkTwoFactorCode(c, user, c.FormValue("code"))
+		}
+		if err != nil {
+			recordAudit(c, audit.Entry{Event: audit.EventLoginFailed, Details: "disable two factor: " + err.Error()})
+			return redirectWithResult(c, "/auth/2fa", "", err)
+		}
+
+		totp.Disable(user)
+
+		err = saveUser(getDbContext(c), user)
+		if err == nil {
+			recordAudit(c, audit.Entry{Event: audit.EventUserChanged, Details: "disabled two factor"})
+		}
+
+		return redirectWithResult(c, "/auth/2fa", "Two factor disabled", err)
+	})
+
+	group.POST("/recovery-codes", func(c echo.Context) error {
+		user := currentUser(c)
+		if !user.TwoFactorEnabled() {
+			return redirectWithResult(c, "/auth/2fa", "", fmt.Errorf("two factor isn't enabled"))
+		}
+
+		if err := checkCurrentPassword(c, user, c.FormValue("password")); err != nil {
+			recordAudit(c, audit.Entry{Event: audit.EventLoginFailed, Details: "new recovery codes: " + err.Error()})
+			return redirectWithResult(c, "/auth/2fa", "", err)
+		}
+
+		codes, err := totp.ResetRecoveryCodes(user)
+		if err == nil {
+			err = saveUser(getDbContext(c), user)
+		}
+		if err != nil {
+			return redirectWithResult(c, "/auth/2fa", "", err)
+		}
+
+		recordAudit(c, audit.Entry{Event: audit.EventUserChanged, Details: "made new recovery codes"})
+
+		return renderTwoFactor(c, &models.TwoFactorVm{Enabled: true, RecoveryCodesLeft: len(codes), RecoveryCodes: codes})
+	})
+
+	return group
+}
+
+// Fills in the secret and a QR code of its otpauth URI for the user to scan.
+func setEnrollment(vm *models.TwoFactorVm, user *datamodels.User, secret string) error {
+	vm.Secret = secret
+	vm.URI = totp.URI(totpIssuer, user.Username, secret)
+
+	png, err := qrcode.Encode(vm.URI, qrcode.Medium, 256)
+	if err != nil {
+		return fmt.Errorf("failed to make QR code: %s", err)
+	}
+	vm.QRCode = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png))
+
+	return nil
+}
+
+func renderTwoFactor(c echo.Context, vm *models.TwoFactorVm) error {
+	return renderTwoFactorVm(c, models.NewContextVm(c, vm))
+}
+
+func renderTwoFactorVm(c echo.Context, vm *models.ContextVm) error {
+	view := loadView("auth/2fa.gohtml", c)
+
+	return view.Execute(c.Response().Writer, vm)
+}
@@ -7,4 +7,15 @@ const (
 	CtxFlashMessages   = "flash_messages"
 	CtxDbContext       = "db_context"
 	CtxSensorPoll      = "sensor_poll"
+	CtxLocation        = "location"
+	CtxExporter        = "exporter"
+	CtxBackupService   = "backup_service"
+	CtxCurrentUser     = "current_user"
+	CtxLoginLimiter    = "login_limiter"
+	CtxPasswordPolicy  = "password_policy"
+	CtxAuditLog        = "audit_log"
+	CtxInviteService   = "invite_service"
+	CtxBasePath        = "base_path"
+	CtxHealthService   = "health_service"
+	CtxLogger          = "logger"
 )
@@ -1,31 +1,9 @@
 package helper
 
 import (
-	"fmt"
 	"os"
-	"strconv"
 )
 
-func MustGetEnv(key string) string {
-	val := os.Getenv(key)
-	if val == "" {
-		panic(fmt.Sprintf("Failed to load .env value: %s", key))
-	}
-
-	return val
-}
-
-func MustGetEnvInt(key string) int {
-	strVal := MustGetEnv(key)
-
-	intVal, err := strconv.Atoi(strVal)
-	if err != nil {
-		panic(fmt.Sprintf("Failed to convert .env value: %s", key))
-	}
-
-	return intVal
-}
-
 func ResourceRoot() string {
 	return AppRoot() + "/resources"
 }
@@ -41,9 +19,12 @@ func SiteRoot() string {
 func AppRoot() string {
 	dir, _ := os.Getwd()
 	for i := 0; i < 10; i++ {
+		// The .env file is optional, so look for the views too
+		if _, err := os.Stat(dir + "/.env"); err == nil {
+			return dir
+		}
 
-		_, err := os.Stat(dir + "/.env")
-		if err == nil {
+		if info, err := os.Stat(dir + "/resources/views"); err == nil && info.IsDir() {
 			return dir
 		}
 
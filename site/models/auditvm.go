@@ -0,0 +1,9 @@
+package models
+
+import "goairmon/business/services/audit"
+
+type AuditVm struct {
+	Entries []*audit.Entry
+	Events  []string
+	Filter  audit.Filter
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"goairmon/business/data/models"
 	"goairmon/business/services/session"
 	"goairmon/site/helper"
 
@@ -17,9 +18,10 @@ func NewContextVm(c echo.Context, viewModel interface{}) *ContextVm {
 	sess, _ := c.Get(CtxServerSession).(*session.Session)
 	flashBag, _ := c.Get(CtxFlashMessages).(*FlashBag)
 	csrfToken := c.Get("csrf").(string)
+	currentUser, _ := c.Get(helper.CtxCurrentUser).(*models.User)
 	userName := ""
-	if sess != nil {
-		userName = sess.Values["user_name"]
+	if currentUser != nil {
+		userName = currentUser.Username
 	}
 
 	return &ContextVm{
@@ -29,6 +31,7 @@ func NewContextVm(c echo.Context, viewModel interface{}) *ContextVm {
 		Errors:    ErrorBag{},
 		FlashBag:  flashBag,
 		Csrf:      csrfToken,
+		User:      currentUser,
 	}
 }
 
@@ -39,4 +42,10 @@ type ContextVm struct {
 	Errors    ErrorBag
 	FlashBag  *FlashBag
 	Csrf      string
+	User      *models.User
+}
+
+// HasRole checks the logged in user has at least the role.
+func (v *ContextVm) HasRole(role string) bool {
+	return v.User != nil && v.User.HasRole(role)
 }
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"goairmon/business/data/models"
+	"goairmon/business/services/passpolicy"
+
+	"github.com/labstack/echo"
+)
+
+type ChangePasswordVm struct {
+	CurrentPassword string
+	Password        string
+	ConfirmPassword string
+}
+
+func UnmarshalChangePasswordVm(c echo.Context) *ChangePasswordVm {
+	return &ChangePasswordVm{
+		CurrentPassword: c.FormValue("current_password"),
+		Password:        c.FormValue("password"),
+		ConfirmPassword: c.FormValue("confirm_password"),
+	}
+}
+
+// Validate checks the new password, the current one is checked through the login limiter first.
+func (v *ChangePasswordVm) Validate(user *models.User, policy *passpolicy.Policy) ErrorBag {
+	errs := ErrorBag{}
+
+	if err := policy.Validate(user, v.Password); err != nil {
+		errs["password"] = err.Error()
+	} else if v.Password != v.ConfirmPassword {
+		errs["confirm_password"] = "passwords don't match"
+	}
+
+	return errs
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	datamodels "goairmon/business/data/models"
+	"goairmon/business/services/passpolicy"
+	"testing"
+)
+
+func TestValidateChangePassword(t *testing.T) {
+	policy := passpolicy.DefaultPolicy()
+	user := &datamodels.User{Username: "password-user"}
+	if err := policy.SetPassword(user, "air-quality"); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := []struct {
+		form   *ChangePasswordVm
+		errors []string
+	}{
+		{&ChangePasswordVm{CurrentPassword: "air-quality", Password: "new-password", ConfirmPassword: "new-password"}, nil},
+		{&ChangePasswordVm{CurrentPassword: "air-quality", Password: "air-quality", ConfirmPassword: "air-quality"}, []string{"password"}},
+		{&ChangePasswordVm{CurrentPassword: "air-quality", Password: "password1", ConfirmPassword: "password1"}, []string{"password"}},
+		{&ChangePasswordVm{CurrentPassword: "air-quality", Password: "new-password", ConfirmPassword: "different"}, []string{"confirm_password"}},
+	}
+
+	for _, row := range rows {
+		errs := row.form.Validate(user, policy)
+		if len(errs) != len(row.errors) {
+			t.Error("unexpected errors", row.form, errs)
+		}
+
+		for _, field := range row.errors {
+			if !errs.HasErrors(field) {
+				t.Error("expected error for", field, row.form)
+			}
+		}
+	}
+}
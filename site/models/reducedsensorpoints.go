@@ -6,26 +6,58 @@ import (
 	"time"
 )
 
-func NewReducedSensorPoints(rawPointData []*models.SensorPoint, now time.Time) *ReducedSensorPoints {
-	reduced := &ReducedSensorPoints{}
-	reduced.normalizeSensorData(rawPointData, now)
+// NewReducedSensorPoints charts a point a minute from readings saved every pollInterval, minutes
+// without a reading are charted as fillCo2.
+func NewReducedSensorPoints(rawPointData []*models.SensorPoint, now time.Time, loc *time.Location, fillCo2 float64, pollInterval time.Duration) *ReducedSensorPoints {
+	if loc == nil {
+		loc = time.Local
+	}
+
+	reduced := &ReducedSensorPoints{
+		location: loc,
+		fillCo2:  fillCo2,
+	}
+	reduced.normalizeSensorData(rawPointData, now, pollInterval)
 
 	return reduced
 }
 
 type ReducedSensorPoints struct {
 	pointData []*models.SensorPoint
+	location  *time.Location
+	fillCo2   float64
+}
+
+// Sensor point with a label rendered in the display timezone.
+type LabelledSensorPoint struct {
+	Time     int64   `json:"t"`
+	Co2Value float64 `json:"v"`
+	Label    string  `json:"l"`
+}
+
+func (p *ReducedSensorPoints) Labelled(points []*models.SensorPoint, layout string) []*LabelledSensorPoint {
+	output := make([]*LabelledSensorPoint, len(points))
+	for i, point := range points {
+		output[i] = &LabelledSensorPoint{
+			Time:     point.Time.Unix(),
+			Co2Value: point.Co2Value,
+			Label:    point.Time.In(p.location).Format(layout),
+		}
+	}
+
+	return output
 }
 
 func (p *ReducedSensorPoints) Last7Days() []*models.SensorPoint {
-	// Mean point by 60 minutes
+	// Mean point by 60 minutes, on the hour
 	outputPoints := 7 * 24
 	pointRange := 60
+	offset := p.bucketOffset(pointRange)
 	output := make([]*models.SensorPoint, outputPoints)
 
 	for i := 0; i < outputPoints; i++ {
-		midPointIdx := pointRange * i
-		midPointTime := p.pointData[midPointIdx].Time
+		midPointIdx := offset + pointRange*i
+		midPointTime := p.pointData[midPointIdx].Time.Truncate(time.Minute)
 		meanCo2 := p.meanCo2Value(midPointIdx, pointRange)
 
 		output[i] = &models.SensorPoint{
@@ -38,14 +70,15 @@ func (p *ReducedSensorPoints) Last7Days() []*models.SensorPoint {
 }
 
 func (p *ReducedSensorPoints) Last48Hours() []*models.SensorPoint {
-	// Mean point by 30 minutes
+	// Mean point by 30 minutes, on the half hour
 	outputPoints := 24 * 2 * 2
 	pointRange := 30
+	offset := p.bucketOffset(pointRange)
 	output := make([]*models.SensorPoint, outputPoints)
 
 	for i := 0; i < outputPoints; i++ {
-		midPointIdx := pointRange * i
-		midPointTime := p.pointData[midPointIdx].Time
+		midPointIdx := offset + pointRange*i
+		midPointTime := p.pointData[midPointIdx].Time.Truncate(time.Minute)
 		meanCo2 := p.meanCo2Value(midPointIdx, pointRange)
 
 		output[i] = &models.SensorPoint{
@@ -73,6 +106,14 @@ func (p *ReducedSensorPoints) Last2Hours() []*models.SensorPoint {
 	return output
 }
 
+// Number of points since the last bucket boundary in the display timezone.
+// Zones with partial hour offsets get buckets on their own local hour.
+func (p *ReducedSensorPoints) bucketOffset(pointRange int) int {
+	local := p.pointData[0].Time.In(p.location)
+
+	return (local.Hour()*60 + local.Minute()) % pointRange
+}
+
 func (p *ReducedSensorPoints) meanCo2Value(minPointIdx int, pointRange int) float64 {
 	sum := 0.0
 	for i := minPointIdx; i < minPointIdx+pointRange; i++ {
@@ -82,36 +123,40 @@ func (p *ReducedSensorPoints) meanCo2Value(minPointIdx int, pointRange int) floa
 	return sum / float64(pointRange)
 }
 
-func (p *ReducedSensorPoints) normalizeSensorData(rawPoints []*models.SensorPoint, now time.Time) {
+// Each minute is the mean of the readings in it. When readings are further apart than a minute,
+// minutes between them repeat the last reading until the next is due.
+func (p *ReducedSensorPoints) normalizeSensorData(rawPoints []*models.SensorPoint, now time.Time, pollInterval time.Duration) {
 	pointCount := 24 * 8 * 60
 	p.pointData = make([]*models.SensorPoint, pointCount)
 
+	window := time.Minute
+	if pollInterval > window {
+		window = pollInterval
+	}
+
 	sort.Slice(rawPoints, func(i, j int) bool {
 		return rawPoints[i].Time.After(rawPoints[j].Time)
 	})
 
 	rawIdx := 0
-	var refTime time.Time
-	var nextRawPoint *models.SensorPoint
-	if len(rawPoints) > 0 {
-		nextRawPoint = rawPoints[0]
-	}
-
 	for i := 0; i < pointCount; i++ {
-		refTime = now.Add(-time.Minute * time.Duration(i))
+		refTime := now.Add(-time.Minute * time.Duration(i))
 
-		for nextRawPoint != nil && nextRawPoint.Time.After(refTime) {
+		for rawIdx < len(rawPoints) && rawPoints[rawIdx].Time.After(refTime) {
 			rawIdx++
-			if rawIdx >= len(rawPoints) {
-				nextRawPoint = nil
-			} else {
-				nextRawPoint = rawPoints[rawIdx]
-			}
 		}
 
-		co2Value := 400.0
-		if nextRawPoint != nil && nextRawPoint.Time.Add(time.Minute).After(refTime) {
-			co2Value = nextRawPoint.Co2Value
+		sum, count := 0.0, 0
+		for j := rawIdx; j < len(rawPoints) && rawPoints[j].Time.After(refTime.Add(-time.Minute)); j++ {
+			sum += rawPoints[j].Co2Value
+			count++
+		}
+
+		co2Value := p.fillCo2
+		if count > 0 {
+			co2Value = sum / float64(count)
+		} else if rawIdx < len(rawPoints) && rawPoints[rawIdx].Time.Add(window).After(refTime) {
+			co2Value = rawPoints[rawIdx].Co2Value
 		}
 
 		p.pointData[i] = &models.SensorPoint{
@@ -20,7 +20,7 @@ func TestReduce2Hours(t *testing.T) {
 		&models.SensorPoint{Time: startTime.Add(-time.Minute * time.Duration(7)), Co2Value: 17},
 	}
 
-	reducedPoints := NewReducedSensorPoints(rawPoints, startTime)
+	reducedPoints := NewReducedSensorPoints(rawPoints, startTime, time.UTC, 400, time.Minute)
 
 	twoHours := reducedPoints.Last2Hours()
 
@@ -67,7 +67,7 @@ func TestReduce48Hours(t *testing.T) {
 		})
 	}
 
-	reducedPoints := NewReducedSensorPoints(rawPoints, startTime)
+	reducedPoints := NewReducedSensorPoints(rawPoints, startTime, time.UTC, 400, time.Minute)
 
 	fortyEightHours := reducedPoints.Last48Hours()
 
@@ -101,3 +101,69 @@ func TestReduce48Hours(t *testing.T) {
 		t.Error("unexpected mean", 11.5, mean)
 	}
 }
+
+func TestReduceAlignsToDisplayTimezone(t *testing.T) {
+	zone := time.FixedZone("half-hour-zone", 5*60*60+30*60)
+	startTime := time.Date(2018, 1, 1, 10, 15, 42, 0, zone)
+
+	rawPoints := make([]*models.SensorPoint, 0)
+	for i := 0; i < 240; i++ {
+		rawPoints = append(rawPoints, &models.SensorPoint{
+			Time:     startTime.Add(-time.Minute * time.Duration(i)),
+			Co2Value: float64(i),
+		})
+	}
+
+	reducedPoints := NewReducedSensorPoints(rawPoints, startTime, zone, 400, time.Minute)
+
+	sevenDays := reducedPoints.Last7Days()
+	if !sevenDays[0].Time.Equal(time.Date(2018, 1, 1, 10, 0, 0, 0, zone)) {
+		t.Error("expected hourly bucket on the local hour", sevenDays[0].Time.In(zone))
+	}
+
+	if sevenDays[0].Co2Value != 44.5 {
+		t.Error("unexpected first co2 value", 44.5, sevenDays[0].Co2Value)
+	}
+
+	fortyEightHours := reducedPoints.Last48Hours()
+	if !fortyEightHours[1].Time.Equal(time.Date(2018, 1, 1, 9, 30, 0, 0, zone)) {
+		t.Error("expected half hour bucket on the local half hour", fortyEightHours[1].Time.In(zone))
+	}
+
+	labelled := reducedPoints.Labelled(sevenDays[:1], "15:04")
+	if labelled[0].Label != "10:00" {
+		t.Error("unexpected label", "10:00", labelled[0].Label)
+	}
+
+	if labelled[0].Time != sevenDays[0].Time.Unix() {
+		t.Error("unexpected label time", sevenDays[0].Time.Unix(), labelled[0].Time)
+	}
+}
+
+func TestReducePollInterval(t *testing.T) {
+	startTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Every 5 minutes
+	rawPoints := []*models.SensorPoint{
+		&models.SensorPoint{Time: startTime, Co2Value: 10},
+		&models.SensorPoint{Time: startTime.Add(-5 * time.Minute), Co2Value: 15},
+	}
+
+	twoHours := NewReducedSensorPoints(rawPoints, startTime, time.UTC, 400, 5*time.Minute).Last2Hours()
+	if twoHours[0].Co2Value != 10 || twoHours[1].Co2Value != 15 || twoHours[5].Co2Value != 15 || twoHours[6].Co2Value != 400 {
+		t.Error("expected readings to be held until the next is due", twoHours[0].Co2Value, twoHours[1].Co2Value, twoHours[5].Co2Value, twoHours[6].Co2Value)
+	}
+
+	// Every 20 seconds
+	rawPoints = []*models.SensorPoint{
+		&models.SensorPoint{Time: startTime, Co2Value: 10},
+		&models.SensorPoint{Time: startTime.Add(-20 * time.Second), Co2Value: 20},
+		&models.SensorPoint{Time: startTime.Add(-40 * time.Second), Co2Value: 30},
+		&models.SensorPoint{Time: startTime.Add(-60 * time.Second), Co2Value: 40},
+	}
+
+	twoHours = NewReducedSensorPoints(rawPoints, startTime, time.UTC, 400, 20*time.Second).Last2Hours()
+	if twoHours[0].Co2Value != 20 || twoHours[1].Co2Value != 40 || twoHours[2].Co2Value != 400 {
+		t.Error("expected readings in a minute to be averaged", twoHours[0].Co2Value, twoHours[1].Co2Value, twoHours[2].Co2Value)
+	}
+}
@@ -0,0 +1,8 @@
+package models
+
+import "goairmon/business/services/session"
+
+type SessionsVm struct {
+	Sessions  []*session.Session
+	CurrentID string
+}
@@ -0,0 +1,14 @@
+package models
+
+import "html/template"
+
+type TwoFactorVm struct {
+	Enabled           bool
+	RecoveryCodesLeft int
+	// Set while enrolling
+	Secret string
+	URI    string
+	QRCode template.URL
+	// Only shown once, right after they're made
+	RecoveryCodes []string
+}
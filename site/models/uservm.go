@@ -0,0 +1,65 @@
+package models
+
+import (
+	"goairmon/business/data/context"
+	"goairmon/business/data/models"
+	"goairmon/business/services/loginlimit"
+	"goairmon/business/services/passpolicy"
+	"goairmon/business/services/useradmin"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+type UsersVm struct {
+	Users []*models.User
+	Roles []string
+	Form  *UserFormVm
+	// Failed logins by username, only for users that have any
+	Logins  map[string]*loginlimit.Status
+	Invites []*models.Invite
+}
+
+type UserFormVm struct {
+	Username        string
+	Password        string
+	ConfirmPassword string
+	Timezone        string
+	Role            string
+}
+
+func UnmarshalUserFormVm(c echo.Context) *UserFormVm {
+	return &UserFormVm{
+		Username:        c.FormValue("username"),
+		Password:        c.FormValue("password"),
+		ConfirmPassword: c.FormValue("confirm_password"),
+		Timezone:        c.FormValue("timezone"),
+		Role:            c.FormValue("role"),
+	}
+}
+
+func (v *UserFormVm) Validate(dbContext context.DbContext, policy *passpolicy.Policy) ErrorBag {
+	errs := ErrorBag{}
+
+	if err := useradmin.ValidateUsername(dbContext, v.Username); err != nil {
+		errs["username"] = err.Error()
+	}
+
+	if err := policy.Validate(&models.User{Username: v.Username}, v.Password); err != nil {
+		errs["password"] = err.Error()
+	} else if v.Password != v.ConfirmPassword {
+		errs["confirm_password"] = "passwords don't match"
+	}
+
+	if err := useradmin.ValidateRole(v.Role); err != nil {
+		errs["role"] = err.Error()
+	}
+
+	if v.Timezone != "" {
+		if _, err := time.LoadLocation(v.Timezone); err != nil {
+			errs["timezone"] = "unknown timezone"
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"goairmon/business/data/context"
+	datamodels "goairmon/business/data/models"
+	"goairmon/business/services/passpolicy"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestValidateUserForm(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goairmon_uservm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dbContext := context.NewMemDbContext(&context.MemDbConfig{StoragePath: dir, SensorPointCount: 10})
+	dbContext.CreateOrUpdateUser(&datamodels.User{Username: "existing-user"})
+
+	rows := []struct {
+		form   *UserFormVm
+		errors []string
+	}{
+		{&UserFormVm{Username: "new-user", Password: "air-quality", ConfirmPassword: "air-quality", Role: datamodels.RoleViewer}, nil},
+		{&UserFormVm{Username: "new-user", Password: "air-quality", ConfirmPassword: "air-quality", Timezone: "America/Vancouver", Role: datamodels.RoleAdmin}, nil},
+		{&UserFormVm{Username: "short", Password: "air-quality", ConfirmPassword: "air-quality", Role: datamodels.RoleViewer}, []string{"username"}},
+		{&UserFormVm{Username: "existing-user", Password: "air-quality", ConfirmPassword: "air-quality", Role: datamodels.RoleViewer}, []string{"username"}},
+		{&UserFormVm{Username: "new-user", Password: "short", ConfirmPassword: "short", Role: datamodels.RoleViewer}, []string{"password"}},
+		{&UserFormVm{Username: "new-user", Password: "password", ConfirmPassword: "password", Role: datamodels.RoleViewer}, []string{"password"}},
+		{&UserFormVm{Username: "new-user", Password: "air-quality", ConfirmPassword: "different", Role: datamodels.RoleViewer}, []string{"confirm_password"}},
+		{&UserFormVm{Username: "new-user", Password: "air-quality", ConfirmPassword: "air-quality", Timezone: "Not/AZone", Role: datamodels.RoleViewer}, []string{"timezone"}},
+		{&UserFormVm{Username: "new-user", Password: "air-quality", ConfirmPassword: "air-quality", Role: "superuser"}, []string{"role"}},
+		{&UserFormVm{}, []string{"username", "password", "role"}},
+	}
+
+	for _, row := range rows {
+		errs := row.form.Validate(dbContext, passpolicy.DefaultPolicy())
+		if len(errs) != len(row.errors) {
+			t.Error("unexpected errors", row.form, errs)
+		}
+
+		for _, field := range row.errors {
+			if !errs.HasErrors(field) {
+				t.Error("expected error for", field, row.form)
+			}
+		}
+	}
+}
@@ -1,41 +1,101 @@
 package site
 
 import (
+	stdContext "context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"goairmon/business/data/context"
+	"goairmon/business/services/applog"
+	"goairmon/business/services/archive"
+	"goairmon/business/services/audit"
+	"goairmon/business/services/backup"
+	"goairmon/business/services/export"
 	"goairmon/business/services/flash"
+	"goairmon/business/services/health"
 	"goairmon/business/services/identity"
+	"goairmon/business/services/invite"
+	"goairmon/business/services/loginlimit"
+	"goairmon/business/services/passpolicy"
 	"goairmon/business/services/poll"
 	"goairmon/business/services/provider"
+	"goairmon/business/services/proxy"
+	"goairmon/business/services/tlscert"
+	"goairmon/business/services/useradmin"
 	"goairmon/business/services/viewloader"
 	"goairmon/site/controllers"
 	"goairmon/site/helper"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo"
 	"github.com/labstack/echo/middleware"
-	echomiddleware "github.com/labstack/echo/middleware"
 )
 
-func EnvSiteConfig() *Config {
-	return &Config{
-		Address:               helper.MustGetEnv("SERVER_ADDRESS"),
-		AppCookieKey:          helper.MustGetEnv("APP_COOKIE_KEY"),
-		CookieStoreEncryption: helper.MustGetEnv("COOKIE_STORE_ENCRYPTION"),
-		StoragePath:           helper.MustGetEnv("STORAGE_PATH"),
-		SensorPointCount:      helper.MustGetEnvInt("SENSOR_POINT_COUNT"),
+func NewSite(cfg *Config) *Site {
+	logger, err := applog.NewLogger(&cfg.Log)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to parse LOG settings: %s", err))
 	}
-}
 
-func NewSite(cfg *Config) *Site {
-	identityService := identity.NewIdentityService(&identity.IdentityConfig{
+	proxyCfg := &proxy.Config{}
+	basePath, err := proxy.ParseBasePath(cfg.BasePath)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to parse BASE_PATH: %s", err))
+	}
+	proxyCfg.BasePath = basePath
+
+	if proxyCfg.TrustedProxies, err = identity.ParseNetworks(cfg.TrustedProxies); err != nil {
+		panic(fmt.Sprintf("Failed to parse TRUSTED_PROXIES: %s", err))
+	}
+
+	sameSite, err := identity.ParseSameSite(cfg.CookieSameSite)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to parse COOKIE_SAMESITE: %s", err))
+	}
+
+	identityCfg := &identity.IdentityConfig{
 		CookieStoreKeySession:    cfg.AppCookieKey,
 		CookieStoreEncryptionKey: cfg.CookieStoreEncryption,
-	})
+		SessionFile:              cfg.SessionFile,
+		SessionExpiry:            cfg.SessionExpiry,
+		SessionGCDelay:           cfg.SessionGCDelay,
+		CookiePath:               cookiePath(basePath),
+		CookieSecure:             cfg.CookieSecure || cfg.TLSEnabled,
+		CookieSameSite:           sameSite,
+		Logger:                   logger.Subsystem("auth"),
+	}
+
+	if cfg.PublicDashboard {
+		spec := cfg.PublicNetworks
+		if spec == "" {
+			spec = "0.0.0.0/0,::/0"
+		}
+
+		networks, err := identity.ParseNetworks(spec)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to parse PUBLIC_DASHBOARD_NETWORKS: %s", err))
+		}
+		identityCfg.PublicNetworks = networks
+	}
+
+	identityService := identity.NewIdentityService(identityCfg)
+
+	echoServer := echo.New()
+	echoServer.Logger = logger
+	echoServer.StdLogger = logger.Subsystem("http").StdLogger()
+	echoServer.HideBanner = true
+	echoServer.HidePort = true
 
 	site := Site{
-		echoServer:      echo.New(),
+		echoServer:      echoServer,
 		identityService: identityService,
+		proxyService:    proxy.NewProxyService(proxyCfg),
 		cfg:             cfg,
+		logger:          logger,
 	}
 
 	site.bindGlobalMiddleware(cfg)
@@ -46,8 +106,20 @@ func NewSite(cfg *Config) *Site {
 
 type Site struct {
 	echoServer      *echo.Echo
+	logger          *applog.Logger
 	identityService *identity.IdentityService
+	proxyService    *proxy.ProxyService
 	cfg             *Config
+	adminSocket     *useradmin.SocketServer
+	redirectServer  *http.Server
+	dbContext       context.DbContext
+	pollService     *poll.PollService
+	archiveService  *archive.ArchiveService
+	backupService   *backup.BackupService
+	inviteService   *invite.InviteService
+	auditLog        *audit.AuditLog
+	watchdog        *health.Watchdog
+	listener        net.Listener
 }
 
 type Config struct {
@@ -55,19 +127,203 @@ type Config struct {
 	CookieStoreEncryption string
 	Address               string
 	StoragePath           string
+	SessionFile           string
+	AdminSocket           string
 	SensorPointCount      int
 	EncodeReadible        bool
+	Location              *time.Location
+	PublicDashboard       bool
+	PublicNetworks        string
+	BasePath              string
+	TrustedProxies        string
+	CookieSecure          bool
+	CookieSameSite        string
+	TLSEnabled            bool
+	TLS                   tlscert.Config
+	RedirectAddress       string
+	BackupDir             string
+	BackupSchedule        string
+	BackupRetention       backup.Retention
+	LoginLimit            loginlimit.Config
+	PasswordPolicy        passpolicy.Config
+	Audit                 audit.Config
+	Log                   applog.Config
+	InviteExpiry          time.Duration
+	ShutdownTimeout       time.Duration
+	PollInterval          time.Duration
+	SensorReadDelay       time.Duration
+	SensorBaselineDelay   time.Duration
+	SessionExpiry         time.Duration
+	SessionGCDelay        time.Duration
+	// Charted for minutes without a reading
+	ChartFillCo2 float64
 }
 
 func (s *Site) Start() {
+	listener, err := net.Listen("tcp", s.cfg.Address)
+	if err != nil {
+		s.logger.Fatal(err)
+	}
+	s.listener = listener
+
+	if !s.cfg.TLSEnabled {
+		s.logger.Subsystem("http").Info("serving http on", s.cfg.Address)
+		s.echoServer.Listener = listener
+		go func() {
+			s.serveFatal(s.echoServer.StartServer(s.echoServer.Server))
+		}()
+		s.startWatchdog()
+		return
+	}
+
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		s.logger.Fatal(err)
+	}
+
+	s.logger.Subsystem("http").Info("serving https on", s.cfg.Address)
+	s.echoServer.TLSServer.TLSConfig = tlsConfig
+	s.echoServer.TLSListener = tls.NewListener(listener, tlsConfig)
 	go func() {
-		s.echoServer.Logger.Fatal(s.echoServer.Start(s.cfg.Address))
+		s.serveFatal(s.echoServer.StartServer(s.echoServer.TLSServer))
 	}()
+
+	if s.cfg.RedirectAddress != "" {
+		redirectListener, err := net.Listen("tcp", s.cfg.RedirectAddress)
+		if err != nil {
+			s.logger.Fatal(err)
+		}
+
+		s.redirectServer = &http.Server{
+			Addr:     s.cfg.RedirectAddress,
+			Handler:  tlscert.RedirectHandler(s.cfg.Address),
+			ErrorLog: s.logger.Subsystem("http").StdLogger(),
+		}
+		s.logger.Subsystem("http").Info("redirecting http on", s.cfg.RedirectAddress)
+
+		go func() {
+			s.serveFatal(s.redirectServer.Serve(redirectListener))
+		}()
+	}
+
+	s.startWatchdog()
 }
 
-func (s *Site) Cleanup() error {
-	fmt.Print("Running cleanup!\n")
-	return s.echoServer.Close()
+// The certificate is loaded up front so the listener can be bound before systemd is told we're ready.
+func (s *Site) tlsConfig() (*tls.Config, error) {
+	certFile, keyFile, err := tlscert.Files(&s.cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tls certificate: %s", err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2"},
+	}, nil
+}
+
+// Tells systemd the service is ready, when it was started with Type=notify. Only called once listening,
+// connections made before then queue until the server accepts them.
+func (s *Site) startWatchdog() {
+	if !s.watchdog.Enabled() {
+		return
+	}
+
+	if err := s.watchdog.Start(); err != nil {
+		s.logger.Subsystem("health").Error("failed to start watchdog", err)
+	}
+}
+
+// Requests /healthz through the listener, so the watchdog is only fed while the web server answers.
+func (s *Site) probeHTTP() error {
+	addr, ok := s.listener.Addr().(*net.TCPAddr)
+	if !ok {
+		return fmt.Errorf("unexpected listener address %s", s.listener.Addr())
+	}
+
+	host := addr.IP
+	if host.IsUnspecified() {
+		host = net.IPv4(127, 0, 0, 1)
+	}
+
+	scheme := "http"
+	if s.cfg.TLSEnabled {
+		scheme = "https"
+	}
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		// The certificate is for the site's hostname, not loopback
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	res, err := client.Get(fmt.Sprintf("%s://%s/healthz", scheme, net.JoinHostPort(host.String(), strconv.Itoa(addr.Port))))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("healthz responded %s", res.Status)
+	}
+
+	return nil
+}
+
+func (s *Site) serveFatal(err error) {
+	if err != http.ErrServerClosed {
+		s.logger.Fatal(err)
+	}
+}
+
+// Shutdown stops taking requests and waits up to Config.ShutdownTimeout for those in flight,
+// then stops the background services and sensor before flushing storage.
+func (s *Site) Shutdown() error {
+	ctx, cancel := stdContext.WithTimeout(stdContext.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+
+	s.watchdog.Stop()
+
+	errs := make([]string, 0)
+	if err := s.echoServer.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Sprintf("failed to drain requests: %s", err))
+		s.echoServer.Close()
+	}
+
+	if s.redirectServer != nil {
+		s.redirectServer.Shutdown(ctx)
+	}
+
+	if s.adminSocket != nil {
+		s.adminSocket.Close()
+	}
+
+	s.inviteService.Stop()
+	s.backupService.Stop()
+	s.archiveService.Stop()
+	s.pollService.Stop()
+
+	if err := s.identityService.SaveSessions(); err != nil {
+		errs = append(errs, fmt.Sprintf("failed to save sessions: %s", err))
+	}
+
+	if err := s.dbContext.Close(); err != nil {
+		errs = append(errs, fmt.Sprintf("failed to save storage: %s", err))
+	}
+
+	if err := s.auditLog.Close(); err != nil {
+		errs = append(errs, fmt.Sprintf("failed to close audit log: %s", err))
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
 }
 
 func (s *Site) bindGlobalMiddleware(cfg *Config) {
@@ -77,37 +333,130 @@ func (s *Site) bindGlobalMiddleware(cfg *Config) {
 		StoragePath:      cfg.StoragePath,
 		SensorPointCount: cfg.SensorPointCount,
 		EncodeReadible:   cfg.EncodeReadible,
-		Logger:           s.echoServer.Logger,
+		Logger:           s.logger.Subsystem("storage"),
 	})
 
 	pollCfg := &poll.Config{
-		PollDelayMillis: 60 * 1000,
-		Logger:          s.echoServer.Logger,
+		PollDelayMillis:      int(cfg.PollInterval / time.Millisecond),
+		Logger:               s.logger.Subsystem("poll"),
+		SensorLogger:         s.logger.Subsystem("sensor"),
+		SensorReadMillis:     int(cfg.SensorReadDelay / time.Millisecond),
+		BaselineDelaySeconds: int(cfg.SensorBaselineDelay / time.Second),
 	}
 	poll := poll.NewPollService(pollCfg, dbContext)
 	if err := poll.Start(); err != nil {
-		s.echoServer.Logger.Info("failed to start sensor poll", err.Error())
+		s.logger.Subsystem("poll").Warn("failed to start sensor poll:", err)
+	}
+
+	archiveService := archive.NewArchiveService(&archive.Config{
+		Location: cfg.Location,
+		Logger:   s.logger.Subsystem("storage"),
+	}, dbContext)
+	if err := archiveService.Start(); err != nil {
+		s.logger.Subsystem("storage").Warn("failed to start archive service:", err)
+	}
+
+	auditLog := audit.NewAuditLog(&cfg.Audit)
+
+	backupCfg := &backup.Config{
+		Dir:         cfg.BackupDir,
+		StoragePath: cfg.StoragePath,
+		Retention:   cfg.BackupRetention,
+		Location:    cfg.Location,
+		Logger:      s.logger.Subsystem("storage"),
+		Audit:       auditLog,
+	}
+	if cfg.BackupDir != "" {
+		schedule, err := backup.ParseSchedule(cfg.BackupSchedule)
+		if err != nil {
+			s.logger.Fatal(err)
+		}
+		backupCfg.Schedule = schedule
+	}
+
+	backupService := backup.NewBackupService(backupCfg, dbContext)
+	if cfg.BackupDir != "" {
+		if err := backupService.Start(); err != nil {
+			s.logger.Subsystem("storage").Warn("failed to start scheduled backups:", err)
+		}
+	}
+
+	inviteService := invite.NewInviteService(&invite.Config{
+		Key:    cfg.CookieStoreEncryption,
+		Expiry: cfg.InviteExpiry,
+		Logger: s.logger.Subsystem("auth"),
+	}, dbContext)
+	if err := inviteService.Start(); err != nil {
+		s.logger.Subsystem("auth").Warn("failed to start invite service:", err)
+	}
+
+	passwordPolicy, err := passpolicy.NewPolicy(&cfg.PasswordPolicy)
+	if err != nil {
+		s.logger.Fatal(err)
 	}
 
-	provider.Register(viewloader.CtxKey, &viewloader.ViewLoader{})
+	s.adminSocket = useradmin.NewSocketServer(cfg.AdminSocket, dbContext, s.identityService, passwordPolicy, auditLog, s.logger.Subsystem("admin"))
+	if err := s.adminSocket.Start(); err != nil {
+		s.logger.Subsystem("admin").Warn("failed to start admin socket:", err)
+	}
+
+	s.dbContext = dbContext
+	s.pollService = poll
+	s.archiveService = archiveService
+	s.backupService = backupService
+	s.inviteService = inviteService
+	s.auditLog = auditLog
+
+	healthService := health.NewHealthService(poll)
+	watchdogConfig := health.EnvWatchdogConfig(s.logger.Subsystem("health"))
+	watchdogConfig.Probe = s.probeHTTP
+	s.watchdog = health.NewWatchdog(watchdogConfig, healthService)
+
+	provider.Register(viewloader.CtxKey, &viewloader.ViewLoader{FillCo2: cfg.ChartFillCo2, PollInterval: cfg.PollInterval, SensorPointCount: cfg.SensorPointCount})
 	provider.Register(helper.CtxFlashServiceKey, flashService)
 	provider.Register(helper.CtxDbContext, dbContext)
 	provider.Register(helper.CtxSensorPoll, poll)
+	provider.Register(helper.CtxLocation, cfg.Location)
+	provider.Register(helper.CtxBackupService, backupService)
+	provider.Register(helper.CtxLoginLimiter, loginlimit.NewLoginLimiter(&cfg.LoginLimit))
+	provider.Register(helper.CtxPasswordPolicy, passwordPolicy)
+	provider.Register(helper.CtxAuditLog, auditLog)
+	provider.Register(helper.CtxInviteService, inviteService)
+	provider.Register(helper.CtxBasePath, s.proxyService.BasePath())
+	provider.Register(helper.CtxHealthService, healthService)
+	provider.Register(helper.CtxLogger, s.logger)
+	provider.Register(helper.CtxExporter, export.NewExporter(&export.Config{Location: cfg.Location}, dbContext))
 
-	s.echoServer.Use(echomiddleware.Logger())
+	s.echoServer.Pre(s.proxyService.Middleware())
+	s.echoServer.Use(s.logger.Subsystem("http").Middleware())
 	// s.echoServer.Use(echomiddleware.Recover())
 	s.echoServer.Use(provider.BindServices())
 	s.echoServer.Use(s.identityService.LoadCurrentSession())
 	s.echoServer.Use(flashService.PopToContext())
 	s.echoServer.Use(middleware.CSRFWithConfig(middleware.CSRFConfig{
-		TokenLookup: "form:_csrf-token",
+		TokenLookup:    "form:_csrf-token",
+		CookiePath:     cookiePath(s.proxyService.BasePath()),
+		CookieSecure:   cfg.CookieSecure || cfg.TLSEnabled,
+		CookieHTTPOnly: true,
 	}))
 }
 
+func cookiePath(basePath string) string {
+	if basePath == "" {
+		return "/"
+	}
+
+	return basePath
+}
+
 func (s *Site) bindActions() {
 	s.echoServer.Static("/static", "resources/assets")
 	s.echoServer.File("favicon.ico", "resources/assets/imgs/favicon.ico")
 
 	controllers.HomeController(s.echoServer, s.identityService)
+	controllers.HealthController(s.echoServer, s.identityService)
 	controllers.AuthController(s.echoServer, s.identityService)
+	controllers.TwoFactorController(s.echoServer, s.identityService)
+	controllers.ExportController(s.echoServer, s.identityService)
+	controllers.AdminController(s.echoServer, s.identityService)
 }
@@ -0,0 +1,122 @@
+package site
+
+import (
+	"goairmon/business/data/context"
+	"goairmon/business/data/models"
+	"goairmon/business/services/passpolicy"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+func _siteConfig(t *testing.T, dir string) *Config {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	return &Config{
+		AppCookieKey:          "test-cookie-key",
+		CookieStoreEncryption: "test-encryption-key",
+		Address:               address,
+		StoragePath:           dir,
+		SessionFile:           filepath.Join(dir, "goairmon_sessions.json"),
+		AdminSocket:           filepath.Join(dir, "goairmon.sock"),
+		SensorPointCount:      10,
+		Location:              time.UTC,
+		CookieSameSite:        "lax",
+		PasswordPolicy:        passpolicy.Config{BcryptCost: 4},
+		InviteExpiry:          time.Hour,
+		ShutdownTimeout:       5 * time.Second,
+		PollInterval:          time.Minute,
+		ChartFillCo2:          400,
+	}
+}
+
+func _waitForServer(t *testing.T, address string) {
+	for i := 0; i < 100; i++ {
+		if res, err := http.Get("http://" + address + "/auth/login"); err == nil {
+			res.Body.Close()
+			if res.StatusCode == http.StatusOK {
+				return
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	t.Fatal("server didn't start")
+}
+
+func TestStartShutdown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goairmon_site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := _siteConfig(t, dir)
+	site := NewSite(cfg)
+
+	inFlight := make(chan int)
+	site.echoServer.GET("/slow", func(c echo.Context) error {
+		close(inFlight)
+		time.Sleep(200 * time.Millisecond)
+		return c.String(http.StatusOK, "done")
+	})
+
+	site.Start()
+	_waitForServer(t, cfg.Address)
+
+	if err := site.dbContext.PushSensorPoint(&models.SensorPoint{Time: time.Now(), Co2Value: 412}); err != nil {
+		t.Fatal(err)
+	}
+
+	slowStatus := make(chan int)
+	go func() {
+		res, err := http.Get("http://" + cfg.Address + "/slow")
+		if err != nil {
+			slowStatus <- 0
+			return
+		}
+		res.Body.Close()
+		slowStatus <- res.StatusCode
+	}()
+
+	<-inFlight
+	if err := site.Shutdown(); err != nil {
+		t.Error(err)
+	}
+
+	if status := <-slowStatus; status != http.StatusOK {
+		t.Error("expected in flight request to finish", status)
+	}
+
+	if _, err := http.Get("http://" + cfg.Address + "/auth/login"); err == nil {
+		t.Error("expected server to be closed")
+	}
+
+	if err := site.pollService.Stop(); err == nil {
+		t.Error("expected sensor poll to be stopped")
+	}
+
+	if _, err := os.Stat(cfg.AdminSocket); !os.IsNotExist(err) {
+		t.Error("expected admin socket to be removed", err)
+	}
+
+	reloaded := context.NewMemDbContext(&context.MemDbConfig{StoragePath: dir, SensorPointCount: 10})
+	if points, _ := reloaded.GetSensorPoints(10); len(points) == 0 || points[0].Co2Value != 412 {
+		t.Error("expected sensor points to be saved", points)
+	}
+
+	if eCO2, tVOC, _ := reloaded.GetSensorBaseline(); eCO2 == 0 || tVOC == 0 {
+		t.Error("expected sensor baseline to be saved", eCO2, tVOC)
+	}
+}
@@ -3,6 +3,7 @@ package testhelpers
 import (
 	"io"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -62,7 +63,8 @@ func (c *FakeContext) Scheme() string {
 // RealIP returns the client's network address based on `X-Forwarded-For`
 // or `X-Real-IP` request header.
 func (c *FakeContext) RealIP() string {
-	panic("not implemented")
+	host, _, _ := net.SplitHostPort(c.Request().RemoteAddr)
+	return host
 }
 
 // Path returns the registered path for the handler.